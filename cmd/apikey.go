@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"authonomy/services"
+	"authonomy/store"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// apiKeyNameFlag and apiKeyScopesFlag describe the key to create.
+var (
+	apiKeyNameFlag   string
+	apiKeyScopesFlag []string
+)
+
+var apiKeyCmd = &cobra.Command{
+	Use:   "apikey",
+	Short: "Manage the admin API keys",
+	Long: "Manage the admin API keys. The badger database can only be opened by one process, " +
+		"stop the service first when using the badger backend.",
+}
+
+var apiKeyCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create an admin API key, the key is only printed once",
+	Run: func(cmd *cobra.Command, args []string) {
+		db := openStore()
+		defer db.Close()
+		key, err := services.CreateAPIKey(db, apiKeyNameFlag, apiKeyScopesFlag)
+		if err != nil {
+			log.Fatalf("Failed to create the API key: %v", err)
+		}
+		fmt.Printf("Created API key %s with scopes %s\n", key.KeyID, strings.Join(key.Scopes, ","))
+		fmt.Println(key.Key)
+	},
+}
+
+var apiKeyListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the admin API keys",
+	Run: func(cmd *cobra.Command, args []string) {
+		db := openStore()
+		defer db.Close()
+		keys, err := services.ListAPIKeys(db)
+		if err != nil {
+			log.Fatalf("Failed to list the API keys: %v", err)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "KEY ID\tNAME\tSCOPES\tCREATED\tREVOKED")
+		for _, key := range keys {
+			revoked := "-"
+			if key.RevokedAt != nil {
+				revoked = key.RevokedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", key.KeyID, key.Name, strings.Join(key.Scopes, ","),
+				key.CreatedAt.Format(time.RFC3339), revoked)
+		}
+		w.Flush()
+	},
+}
+
+var apiKeyRevokeCmd = &cobra.Command{
+	Use:   "revoke <key id>",
+	Short: "Revoke an admin API key",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		db := openStore()
+		defer db.Close()
+		if _, err := services.RevokeAPIKey(db, args[0]); err != nil {
+			log.Fatalf("Failed to revoke the API key: %v", err)
+		}
+		fmt.Printf("Revoked API key %s\n", args[0])
+	},
+}
+
+func init() {
+	apiKeyCmd.AddCommand(apiKeyCreateCmd, apiKeyListCmd, apiKeyRevokeCmd)
+	apiKeyCreateCmd.Flags().StringVar(&apiKeyNameFlag, "name", "", "Name of the key")
+	apiKeyCreateCmd.Flags().StringSliceVar(&apiKeyScopesFlag, "scope", nil, "Scope of the key, repeated or comma separated")
+	apiKeyCreateCmd.MarkFlagRequired("name")
+	apiKeyCreateCmd.MarkFlagRequired("scope")
+}
+
+// openStore opens the configured store, the in-memory store cannot be managed from another process.
+func openStore() store.Store {
+	backend, path := storeConfig()
+	if backend == store.BackendMemory {
+		log.Fatalf("The %s store backend cannot be managed from the command line", backend)
+	}
+	db, err := store.NewStore(backend, path, viper.GetString("service.db_encryption_key"))
+	if err != nil {
+		log.Fatalf("Failed to open the database: %v", err)
+	}
+	return db
+}
@@ -1,7 +1,10 @@
 package cmd
 
 import (
+	"authonomy/pkg/providers"
+	"authonomy/store"
 	"fmt"
+	"log"
 	"os"
 
 	"github.com/spf13/cobra"
@@ -19,6 +22,11 @@ func init() {
 	cobra.OnInitialize(getConfig)
 	rootCmd.AddCommand(startCmd)
 	startCmd.Flags().BoolVarP(&resetFlag, "reset", "r", false, "Reset the service")
+	rootCmd.AddCommand(rekeyCmd)
+	rekeyCmd.Flags().StringVar(&newKeyFlag, "new-key", "", "The new database encryption key")
+	rekeyCmd.MarkFlagRequired("new-key")
+	rootCmd.AddCommand(apiKeyCmd)
+	rootCmd.AddCommand(signingKeyCmd)
 }
 
 // getConfig read the configuration.
@@ -46,6 +54,16 @@ func servicePort() string {
 	return ":" + port
 }
 
+// issuerURL get the URL the service is reached at from the config else the local URL, it is the issuer of the
+// access tokens.
+func issuerURL() string {
+	issuer := viper.GetString("service.issuer_url")
+	if issuer == "" {
+		issuer = "http://localhost" + servicePort()
+	}
+	return issuer
+}
+
 // resetFlag the flag is to reset the database and imports the supported schema.
 var resetFlag bool
 
@@ -53,13 +71,70 @@ var startCmd = &cobra.Command{
 	Use:   "start",
 	Short: "Start the authonomy service",
 	Run: func(cmd *cobra.Command, args []string) {
-		dbPath := viper.GetString("service.badger_path")
+		storeBackend, dbPath := storeConfig()
 		secret := viper.GetString("service.db_encryption_key")
 		ssiUrl := viper.GetString("service.ssi_service_url")
-		Start(dbPath, secret, servicePort(), ssiUrl, resetFlag)
+		statusListTTL := viper.GetDuration("service.status_list_cache_ttl")
+		secretGracePeriod := viper.GetDuration("service.app_secret_grace_period")
+		if err := registerOIDCProviders(); err != nil {
+			log.Fatalf("Failed to register providers: %v", err)
+		}
+		Start(storeBackend, dbPath, secret, servicePort(), issuerURL(), ssiUrl, statusListTTL, secretGracePeriod, resetFlag)
 	},
 }
 
+// storeConfig returns the configured store backend and the path of its database.
+func storeConfig() (backend, path string) {
+	backend = viper.GetString("service.store_backend")
+	if backend == store.BackendSQLite {
+		return backend, viper.GetString("service.sqlite_path")
+	}
+	return backend, viper.GetString("service.badger_path")
+}
+
+// newKeyFlag the database encryption key the records are moved to.
+var newKeyFlag string
+
+var rekeyCmd = &cobra.Command{
+	Use:   "rekey",
+	Short: "Re-encrypt the badger or sqlite database under a new key",
+	Long: "Re-encrypt the badger or sqlite database under a new key. Stop the service first, then set " +
+		"service.db_encryption_key to the new key before starting it again.",
+	Run: func(cmd *cobra.Command, args []string) {
+		backend, path := storeConfig()
+		db, err := store.NewStore(backend, path, viper.GetString("service.db_encryption_key"))
+		if err != nil {
+			log.Fatalf("Failed to open the database: %v", err)
+		}
+		defer db.Close()
+		rekeyer, ok := db.(store.Rekeyer)
+		if !ok {
+			log.Fatalf("The %s store is not encrypted with service.db_encryption_key", backend)
+		}
+		count, err := rekeyer.Rekey(newKeyFlag)
+		if err != nil {
+			log.Fatalf("Failed to rekey the database: %v", err)
+		}
+		fmt.Printf("Re-encrypted %d records, set service.db_encryption_key to the new key\n", count)
+	},
+}
+
+// registerOIDCProviders registers the OpenID Connect providers listed under providers.oidc.
+func registerOIDCProviders() error {
+	var configs []providers.OIDCConfig
+	if err := viper.UnmarshalKey("providers.oidc", &configs); err != nil {
+		return err
+	}
+	for _, config := range configs {
+		provider, err := providers.NewOIDC(config, nil)
+		if err != nil {
+			return err
+		}
+		providers.Register(provider)
+	}
+	return nil
+}
+
 // Execute entrypoint for the service.
 func Execute() {
 	err := rootCmd.Execute()
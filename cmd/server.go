@@ -1,28 +1,30 @@
 package cmd
 
 import (
+	"authonomy/models"
 	"authonomy/pkg/handlers"
 	"authonomy/services"
 	"authonomy/store"
 	"fmt"
 	"log"
 	"net/http"
+	"time"
 
 	_ "authonomy/docs" // Swaggo generates docs in this package
 
-	"github.com/google/uuid"
 	httpSwagger "github.com/swaggo/http-swagger"
 )
 
-func Start(dbPath, secret, port, ssiUrl string, reset bool) {
+func Start(storeBackend, dbPath, secret, port, issuer, ssiUrl string, statusListTTL, secretGracePeriod time.Duration, reset bool) {
 	// Initialize the data store (e.g., database connection)
-	store, err := store.NewStore(dbPath, secret)
+	store, err := store.NewStore(storeBackend, dbPath, secret)
 	if err != nil {
 		log.Fatalf("Failed to initialize the database: %v", err)
 	}
 	defer store.Close()
 	// Initialize services with dependencies
 	ssiService := services.NewSsiClient(ssiUrl)
+	revocationChecker := services.NewRevocationChecker(ssiService, statusListTTL)
 	// clear db before start (for the demo) or use the reset flag
 	if reset {
 		err = store.ClearDB()
@@ -36,23 +38,46 @@ func Start(dbPath, secret, port, ssiUrl string, reset bool) {
 			log.Fatalf("Failed to create policies: %v", err)
 		}
 	}
-	// generate a new api key
-	apiKey := uuid.New().String()
-	fmt.Println("=======================")
-	fmt.Println("\033[32m", "------x-api-key------", "\033[0m")
-	fmt.Println("\033[32m", apiKey, "\033[0m")
-	fmt.Println("=======================")
+	err = services.SyncProviderSchemas(store)
+	if err != nil {
+		log.Fatalf("Failed to set provider schemas: %v", err)
+	}
+	err = services.VersionPolicies(store)
+	if err != nil {
+		log.Fatalf("Failed to version the policies: %v", err)
+	}
+	err = services.HashAppSecrets(store)
+	if err != nil {
+		log.Fatalf("Failed to hash the app secrets: %v", err)
+	}
+	// access tokens are signed with an Ed25519 key kept in the store
+	if _, err := services.EnsureSigningKey(store); err != nil {
+		log.Fatalf("Failed to create the signing key: %v", err)
+	}
+	// create an admin api key when there is none, it is only printed once
+	bootstrapKey, err := services.BootstrapAPIKey(store)
+	if err != nil {
+		log.Fatalf("Failed to create the bootstrap API key: %v", err)
+	}
+	if bootstrapKey != nil {
+		fmt.Println("=======================")
+		fmt.Println("\033[32m", "------x-api-key------", "\033[0m")
+		fmt.Println("\033[32m", bootstrapKey.Key, "\033[0m")
+		fmt.Println("=======================")
+	}
 	fmt.Println("=====Swagger=======")
 	fmt.Println("\033[32m", fmt.Sprintf("http://localhost%s/swagger", port), "\033[0m")
 	fmt.Println("=======================")
 	// Initialize handlers with services
-	m := handlers.NewMiddlewareService(apiKey)
-	appHandler := handlers.NewAppHandler(ssiService, store)
+	m := handlers.NewMiddlewareService(store)
+	appHandler := handlers.NewAppHandler(ssiService, store, secretGracePeriod)
 	authProviderHandler := handlers.NewAuthProviderHandler(ssiService, store)
 	policyHandler := handlers.NewPolicyHandler(ssiService, store)
-	callbackHandler := handlers.NewCallbackHandler()
-	credentialHandler := handlers.NewCredentialHandler(ssiService, store)
-	authHandler := handlers.NewAuthHandler(ssiService, store)
+	callbackHandler := handlers.NewCallbackHandler(store)
+	credentialHandler := handlers.NewCredentialHandler(ssiService, store, issuer)
+	authHandler := handlers.NewAuthHandler(ssiService, store, revocationChecker, issuer)
+	apiKeyHandler := handlers.NewAPIKeyHandler(store)
+	tokenHandler := handlers.NewTokenHandler(store, issuer)
 	// Swagger endpoint
 	url := httpSwagger.URL("http://localhost" + port + "/swagger/doc.json")
 	http.Handle("/swagger/", httpSwagger.Handler(
@@ -60,22 +85,40 @@ func Start(dbPath, secret, port, ssiUrl string, reset bool) {
 	))
 	// Set up routes
 	// application owner access
-	http.HandleFunc("/applications", m.ChainMiddleware(m.XApiKeyMiddleware, m.LoggingMiddleware)(appHandler.HandleApplications))
+	http.HandleFunc("/applications", m.ChainMiddleware(m.XApiKeyMiddleware(models.ScopeAppsRead, models.ScopeAppsWrite), m.LoggingMiddleware)(appHandler.HandleApplications))
+	http.HandleFunc("/application", m.ChainMiddleware(m.XApiKeyMiddleware(models.ScopeAppsRead, models.ScopeAppsWrite), m.LoggingMiddleware)(appHandler.HandleApplication))
+	http.HandleFunc("/applications/suspend", m.ChainMiddleware(m.XApiKeyMiddleware(models.ScopeAppsRead, models.ScopeAppsWrite), m.LoggingMiddleware)(appHandler.SuspendApplicationHandler))
+	http.HandleFunc("/applications/reactivate", m.ChainMiddleware(m.XApiKeyMiddleware(models.ScopeAppsRead, models.ScopeAppsWrite), m.LoggingMiddleware)(appHandler.ReactivateApplicationHandler))
+	http.HandleFunc("/applications/rotate-secret", m.ChainMiddleware(m.XApiKeyMiddleware(models.ScopeAppsRead, models.ScopeAppsWrite), m.LoggingMiddleware)(appHandler.RotateSecretHandler))
+
+	http.HandleFunc("/auth-provider", m.ChainMiddleware(m.XApiKeyMiddleware(models.ScopeProvidersRead, models.ScopeProvidersWrite), m.LoggingMiddleware)(authProviderHandler.GetAuthConnectorHandler))
+	http.HandleFunc("/auth-provider/link", m.ChainMiddleware(m.XApiKeyMiddleware(models.ScopeProvidersRead, models.ScopeProvidersWrite), m.LoggingMiddleware)(authProviderHandler.LinkAuthProviderHandler))
+	http.HandleFunc("/auth-provider/unlink", m.ChainMiddleware(m.XApiKeyMiddleware(models.ScopeProvidersRead, models.ScopeProvidersWrite), m.LoggingMiddleware)(authProviderHandler.UnLinkAuthProviderHandler))
 
-	http.HandleFunc("/auth-provider", m.ChainMiddleware(m.XApiKeyMiddleware, m.LoggingMiddleware)(authProviderHandler.GetAuthConnectorHandler))
-	http.HandleFunc("/auth-provider/link", m.ChainMiddleware(m.XApiKeyMiddleware, m.LoggingMiddleware)(authProviderHandler.LinkAuthProviderHandler))
-	http.HandleFunc("/auth-provider/unlink", m.ChainMiddleware(m.XApiKeyMiddleware, m.LoggingMiddleware)(authProviderHandler.UnLinkAuthProviderHandler))
+	http.HandleFunc("/policies", m.ChainMiddleware(m.XApiKeyMiddleware(models.ScopePoliciesRead, models.ScopePoliciesWrite), m.LoggingMiddleware)(policyHandler.GetPolicyHandler))
+	http.HandleFunc("/create-policy", m.ChainMiddleware(m.XApiKeyMiddleware(models.ScopePoliciesRead, models.ScopePoliciesWrite), m.LoggingMiddleware)(policyHandler.CreatePolicyHandler))
+	http.HandleFunc("/update-policy", m.ChainMiddleware(m.XApiKeyMiddleware(models.ScopePoliciesRead, models.ScopePoliciesWrite), m.LoggingMiddleware)(policyHandler.UpdatePolicyHandler))
+	http.HandleFunc("/deprecate-policy", m.ChainMiddleware(m.XApiKeyMiddleware(models.ScopePoliciesRead, models.ScopePoliciesWrite), m.LoggingMiddleware)(policyHandler.DeprecatePolicyHandler))
+	http.HandleFunc("/attach-policy", m.ChainMiddleware(m.XApiKeyMiddleware(models.ScopePoliciesRead, models.ScopePoliciesWrite), m.LoggingMiddleware)(policyHandler.AttachPolicyHandler))
+	http.HandleFunc("/attached-policies", m.ChainMiddleware(m.XApiKeyMiddleware(models.ScopePoliciesRead, models.ScopePoliciesWrite), m.LoggingMiddleware)(policyHandler.GetAttachedPoliciesHandler))
+	http.HandleFunc("/detach-policy", m.ChainMiddleware(m.XApiKeyMiddleware(models.ScopePoliciesRead, models.ScopePoliciesWrite), m.LoggingMiddleware)(policyHandler.DetachPolicyHandler))
 
-	http.HandleFunc("/policies", m.ChainMiddleware(m.XApiKeyMiddleware, m.LoggingMiddleware)(policyHandler.GetPolicyHandler))
-	http.HandleFunc("/create-policy", m.ChainMiddleware(m.XApiKeyMiddleware, m.LoggingMiddleware)(policyHandler.CreatePolicyHandler))
-	http.HandleFunc("/attach-policy", m.ChainMiddleware(m.XApiKeyMiddleware, m.LoggingMiddleware)(policyHandler.AttachPolicyHandler))
+	http.HandleFunc("/grant-access", m.ChainMiddleware(m.XApiKeyMiddleware(models.ScopeAccessRead, models.ScopeAccessWrite), m.LoggingMiddleware)(authHandler.GrandAccess))
+	http.HandleFunc("/revoke-access", m.ChainMiddleware(m.XApiKeyMiddleware(models.ScopeAccessRead, models.ScopeAccessWrite), m.LoggingMiddleware)(authHandler.RevokeAccess))
+	http.HandleFunc("/access-requests", m.ChainMiddleware(m.XApiKeyMiddleware(models.ScopeAccessRead, models.ScopeAccessWrite), m.LoggingMiddleware)(authHandler.ListAccessRequests))
+	http.HandleFunc("/access-requests/approve", m.ChainMiddleware(m.XApiKeyMiddleware(models.ScopeAccessRead, models.ScopeAccessWrite), m.LoggingMiddleware)(authHandler.ApproveAccessRequest))
+	http.HandleFunc("/access-requests/deny", m.ChainMiddleware(m.XApiKeyMiddleware(models.ScopeAccessRead, models.ScopeAccessWrite), m.LoggingMiddleware)(authHandler.DenyAccessRequest))
+	http.HandleFunc("/access-audit", m.ChainMiddleware(m.XApiKeyMiddleware(models.ScopeAccessRead, models.ScopeAccessWrite), m.LoggingMiddleware)(authHandler.GetAccessAudit))
+	http.HandleFunc("/revoke-credential", m.ChainMiddleware(m.XApiKeyMiddleware(models.ScopeCredentialsWrite, models.ScopeCredentialsWrite), m.LoggingMiddleware)(credentialHandler.RevokeOAuthCredential))
 
-	http.HandleFunc("/grant-access", m.ChainMiddleware(m.XApiKeyMiddleware, m.LoggingMiddleware)(authHandler.GrandAccess))
-	http.HandleFunc("/revoke-access", m.ChainMiddleware(m.XApiKeyMiddleware, m.LoggingMiddleware)(authHandler.RevokeAccess))
-	http.HandleFunc("/revoke-credential", m.ChainMiddleware(m.XApiKeyMiddleware, m.LoggingMiddleware)(credentialHandler.RevokeOAuthCredential))
+	http.HandleFunc("/api-keys", m.ChainMiddleware(m.XApiKeyMiddleware(models.ScopeKeysRead, models.ScopeKeysWrite), m.LoggingMiddleware)(apiKeyHandler.HandleAPIKeys))
+	http.HandleFunc("/api-keys/revoke", m.ChainMiddleware(m.XApiKeyMiddleware(models.ScopeKeysRead, models.ScopeKeysWrite), m.LoggingMiddleware)(apiKeyHandler.RevokeAPIKeyHandler))
+	http.HandleFunc("/signing-keys", m.ChainMiddleware(m.XApiKeyMiddleware(models.ScopeKeysRead, models.ScopeKeysWrite), m.LoggingMiddleware)(tokenHandler.SigningKeysHandler))
+	http.HandleFunc("/signing-keys/rotate", m.ChainMiddleware(m.XApiKeyMiddleware(models.ScopeKeysRead, models.ScopeKeysWrite), m.LoggingMiddleware)(tokenHandler.RotateSigningKeyHandler))
 
 	// application itself access
 	http.HandleFunc("/verify-access", m.ChainMiddleware(m.LoggingMiddleware)(authHandler.VerifyAccess))
+	http.HandleFunc("/introspect", m.ChainMiddleware(m.LoggingMiddleware)(authHandler.Introspect))
 	http.HandleFunc("/issue-credential", m.ChainMiddleware(m.EnableCORS, m.LoggingMiddleware)(credentialHandler.IssueOAuthCredential))
 	// application user access
 	http.HandleFunc("/callback/", m.ChainMiddleware(m.EnableCORS, m.LoggingMiddleware)(callbackHandler.HandleCallback))
@@ -83,8 +126,12 @@ func Start(dbPath, secret, port, ssiUrl string, reset bool) {
 	http.HandleFunc("/signup", m.ChainMiddleware(m.EnableCORS, m.LoggingMiddleware)(authHandler.SignUpHandler))
 
 	http.HandleFunc("/get-access-token", m.ChainMiddleware(m.EnableCORS, m.LoggingMiddleware)(authHandler.GetAccessToken))
+	http.HandleFunc("/refresh-token", m.ChainMiddleware(m.EnableCORS, m.LoggingMiddleware)(authHandler.RefreshAccessToken))
+	http.HandleFunc("/revoke-token", m.ChainMiddleware(m.EnableCORS, m.LoggingMiddleware)(tokenHandler.RevokeTokenHandler))
 	http.HandleFunc("/request-access", m.ChainMiddleware(m.EnableCORS, m.LoggingMiddleware)(authHandler.RequestAccess))
 	http.HandleFunc("/get-access-list", m.ChainMiddleware(m.EnableCORS, m.LoggingMiddleware)(authHandler.GetAccessList))
+	http.HandleFunc("/authorize", m.ChainMiddleware(m.EnableCORS, m.LoggingMiddleware)(authHandler.Authorize))
+	http.HandleFunc("/.well-known/jwks.json", m.ChainMiddleware(m.EnableCORS, m.LoggingMiddleware)(tokenHandler.JWKSHandler))
 	// static web page for access_token
 	fs := http.FileServer(http.Dir("web"))
 	http.Handle("/web/", http.StripPrefix("/web/", fs))
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"authonomy/services"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var signingKeyCmd = &cobra.Command{
+	Use:   "signing-key",
+	Short: "Manage the keys access tokens are signed with",
+	Long: "Manage the Ed25519 keys access tokens are signed with. The badger database can only be opened by one " +
+		"process, stop the service first when using the badger backend.",
+}
+
+var signingKeyRotateCmd = &cobra.Command{
+	Use:   "rotate",
+	Short: "Create a new signing key, it signs once the cached JWKS expired",
+	Run: func(cmd *cobra.Command, args []string) {
+		db := openStore()
+		defer db.Close()
+		key, err := services.RotateSigningKey(db)
+		if err != nil {
+			log.Fatalf("Failed to rotate the signing key: %v", err)
+		}
+		fmt.Printf("Created signing key %s, active from %s\n", key.KeyID, key.ActivatesAt.Format(time.RFC3339))
+	},
+}
+
+var signingKeyListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the signing keys",
+	Run: func(cmd *cobra.Command, args []string) {
+		db := openStore()
+		defer db.Close()
+		keys, err := services.ListSigningKeys(db)
+		if err != nil {
+			log.Fatalf("Failed to list the signing keys: %v", err)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "KID\tALG\tCREATED\tACTIVATES\tRETIRED")
+		for _, key := range keys {
+			retired := "-"
+			if key.RetiredAt != nil {
+				retired = key.RetiredAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", key.KeyID, key.Algorithm, key.CreatedAt.Format(time.RFC3339),
+				key.ActivatesAt.Format(time.RFC3339), retired)
+		}
+		w.Flush()
+	},
+}
+
+func init() {
+	signingKeyCmd.AddCommand(signingKeyRotateCmd, signingKeyListCmd)
+}
@@ -15,9 +15,35 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/applications": {
+        "/.well-known/jwks.json": {
             "get": {
-                "description": "Retrieves a list of all applications",
+                "description": "Publishes the public keys access tokens are verified with, as a JSON Web Key Set. It may be cached for 5 minutes, a new key is published that long before it signs. A retired key is published until the tokens it signed expired.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Token Management"
+                ],
+                "summary": "Get the access token signing keys",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.JWKS"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/access-audit": {
+            "get": {
+                "description": "Lists every grant and revoke of access on the application in chronological order.",
                 "consumes": [
                     "application/json"
                 ],
@@ -25,9 +51,9 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Application Management"
+                    "Permission Management"
                 ],
-                "summary": "Get all applications",
+                "summary": "Get the access audit log",
                 "parameters": [
                     {
                         "type": "string",
@@ -35,6 +61,13 @@ const docTemplate = `{
                         "name": "x-api-key",
                         "in": "header",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Application DID",
+                        "name": "app_did",
+                        "in": "query",
+                        "required": true
                     }
                 ],
                 "responses": {
@@ -43,20 +76,22 @@ const docTemplate = `{
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/models.ApplicationResponse"
+                                "$ref": "#/definitions/models.AuditEvent"
                             }
                         }
                     },
                     "500": {
-                        "description": "Internal Server Error",
+                        "description": "Internal server error",
                         "schema": {
                             "type": "string"
                         }
                     }
                 }
-            },
-            "post": {
-                "description": "Create a new application with the given details",
+            }
+        },
+        "/access-requests": {
+            "get": {
+                "description": "Lists the access requests made on an application, optionally filtered by status.",
                 "consumes": [
                     "application/json"
                 ],
@@ -64,42 +99,43 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Application Management"
+                    "Permission Management"
                 ],
-                "summary": "Create a new application",
+                "summary": "List access requests",
                 "parameters": [
-                    {
-                        "description": "Application to create",
-                        "name": "application",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/models.ApplicationRequest"
-                        }
-                    },
                     {
                         "type": "string",
                         "description": "API Key",
                         "name": "x-api-key",
                         "in": "header",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Application DID",
+                        "name": "app_did",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "pending, approved or denied",
+                        "name": "status",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.ApplicationResponse"
-                        }
-                    },
-                    "400": {
-                        "description": "Bad Request",
-                        "schema": {
-                            "type": "string"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.AccessRequest"
+                            }
                         }
                     },
                     "500": {
-                        "description": "Internal Server Error",
+                        "description": "Internal server error",
                         "schema": {
                             "type": "string"
                         }
@@ -107,9 +143,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/attach-policy": {
+        "/access-requests/approve": {
             "post": {
-                "description": "Attaches a policy to an application using the provided application and issuer DID, and schema ID",
+                "description": "Approves a pending access request and issues the policy credential of the user with the granted role. The role can be overridden, a request for a permission grants the first role of the application policy carrying it.",
                 "consumes": [
                     "application/json"
                 ],
@@ -117,9 +153,9 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Authorization Management"
+                    "Permission Management"
                 ],
-                "summary": "Attach policy to application",
+                "summary": "Approve an access request",
                 "parameters": [
                     {
                         "type": "string",
@@ -129,30 +165,42 @@ const docTemplate = `{
                         "required": true
                     },
                     {
-                        "description": "Application Policy Request",
-                        "name": "appPolicy",
+                        "description": "Decision",
+                        "name": "decision",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/models.ApplicationPolicyRequest"
+                            "$ref": "#/definitions/models.AccessRequestDecision"
                         }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Successfully attached policy",
+                        "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.ApplicationPolicyResponse"
+                            "$ref": "#/definitions/models.AccessRequest"
                         }
                     },
                     "400": {
-                        "description": "Bad Request",
+                        "description": "Bad request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Access request not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Access request already decided",
                         "schema": {
                             "type": "string"
                         }
                     },
                     "500": {
-                        "description": "Internal Server Error",
+                        "description": "Internal server error",
                         "schema": {
                             "type": "string"
                         }
@@ -160,9 +208,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/auth-provider": {
-            "get": {
-                "description": "Retrieves a list of all auth providers",
+        "/access-requests/deny": {
+            "post": {
+                "description": "Denies a pending access request.",
                 "consumes": [
                     "application/json"
                 ],
@@ -170,9 +218,9 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Authentication Management"
+                    "Permission Management"
                 ],
-                "summary": "Get available auth providers",
+                "summary": "Deny an access request",
                 "parameters": [
                     {
                         "type": "string",
@@ -180,20 +228,44 @@ const docTemplate = `{
                         "name": "x-api-key",
                         "in": "header",
                         "required": true
+                    },
+                    {
+                        "description": "Decision",
+                        "name": "decision",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.AccessRequestDecision"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/models.AvailableProvider"
-                            }
+                            "$ref": "#/definitions/models.AccessRequest"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Access request not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Access request already decided",
+                        "schema": {
+                            "type": "string"
                         }
                     },
                     "500": {
-                        "description": "Internal Server Error",
+                        "description": "Internal server error",
                         "schema": {
                             "type": "string"
                         }
@@ -201,9 +273,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/auth-provider/link": {
-            "post": {
-                "description": "Links an OAuth provider to an application by its DID",
+        "/api-keys": {
+            "get": {
+                "description": "Lists the admin API keys, including the revoked ones. The keys themselves are never returned.",
                 "consumes": [
                     "application/json"
                 ],
@@ -211,9 +283,9 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Authentication Management"
+                    "API Key Management"
                 ],
-                "summary": "Link Authentication Provider",
+                "summary": "List admin API keys",
                 "parameters": [
                     {
                         "type": "string",
@@ -221,28 +293,16 @@ const docTemplate = `{
                         "name": "x-api-key",
                         "in": "header",
                         "required": true
-                    },
-                    {
-                        "description": "Authentication Provider Details",
-                        "name": "provider",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/models.AuthProvider"
-                        }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Successfully linked provider",
-                        "schema": {
-                            "$ref": "#/definitions/models.AuthProvider"
-                        }
-                    },
-                    "400": {
-                        "description": "Bad Request",
+                        "description": "OK",
                         "schema": {
-                            "type": "string"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.APIKey"
+                            }
                         }
                     },
                     "500": {
@@ -252,11 +312,9 @@ const docTemplate = `{
                         }
                     }
                 }
-            }
-        },
-        "/auth-provider/unlink": {
+            },
             "post": {
-                "description": "Links an OAuth provider to an application by its DID",
+                "description": "Creates an admin API key with the given scopes. The key is only returned in this response. The calling key can only grant the scopes it holds, and \"*\" only when it holds \"*\".",
                 "consumes": [
                     "application/json"
                 ],
@@ -264,9 +322,9 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Authentication Management"
+                    "API Key Management"
                 ],
-                "summary": "UnLink Authentication Provider",
+                "summary": "Create an admin API key",
                 "parameters": [
                     {
                         "type": "string",
@@ -276,20 +334,20 @@ const docTemplate = `{
                         "required": true
                     },
                     {
-                        "description": "Authentication Provider Details",
-                        "name": "provider",
+                        "description": "API key to create",
+                        "name": "key",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "type": "object"
+                            "$ref": "#/definitions/models.CreateAPIKeyRequest"
                         }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Successfully linked provider",
+                        "description": "OK",
                         "schema": {
-                            "type": "object"
+                            "$ref": "#/definitions/models.CreateAPIKeyResponse"
                         }
                     },
                     "400": {
@@ -298,6 +356,18 @@ const docTemplate = `{
                             "type": "string"
                         }
                     },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Scope Not Held By The API Key",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -307,9 +377,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/create-policy": {
+        "/api-keys/revoke": {
             "post": {
-                "description": "Creates a new policy based on the provided schema",
+                "description": "Revokes an admin API key, it is rejected from then on.",
                 "consumes": [
                     "application/json"
                 ],
@@ -317,9 +387,9 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Authorization Management"
+                    "API Key Management"
                 ],
-                "summary": "Create a new policy",
+                "summary": "Revoke an admin API key",
                 "parameters": [
                     {
                         "type": "string",
@@ -329,24 +399,22 @@ const docTemplate = `{
                         "required": true
                     },
                     {
-                        "description": "Policy Schema",
-                        "name": "schema",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/models.PolicySchemaRequest"
-                        }
+                        "type": "string",
+                        "description": "API key ID",
+                        "name": "key_id",
+                        "in": "query",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Successfully created policy",
+                        "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.PolicySchemaResponse"
+                            "$ref": "#/definitions/models.APIKey"
                         }
                     },
-                    "400": {
-                        "description": "Bad Request",
+                    "404": {
+                        "description": "API Key Not Found",
                         "schema": {
                             "type": "string"
                         }
@@ -360,9 +428,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/get-access-list": {
+        "/application": {
             "get": {
-                "description": "List the access for the user on the resource.",
+                "description": "Retrieves the application with the given DID",
                 "consumes": [
                     "application/json"
                 ],
@@ -370,18 +438,10 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "User Access Management"
+                    "Application Management"
                 ],
-                "summary": "Get Access list on the resource",
+                "summary": "Get an application",
                 "parameters": [
-                    {
-                        "type": "string",
-                        "default": "Bearer YOUR_ACCESS_TOKEN",
-                        "description": "Authorization token",
-                        "name": "Authorization",
-                        "in": "header",
-                        "required": true
-                    },
                     {
                         "type": "string",
                         "description": "Application DID",
@@ -391,43 +451,29 @@ const docTemplate = `{
                     },
                     {
                         "type": "string",
-                        "description": "Application Secret",
-                        "name": "app_secret",
-                        "in": "query",
+                        "description": "API Key",
+                        "name": "x-api-key",
+                        "in": "header",
                         "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "success",
-                        "schema": {
-                            "type": "string"
-                        }
-                    },
-                    "400": {
-                        "description": "Bad request",
-                        "schema": {
-                            "type": "string"
-                        }
-                    },
-                    "401": {
-                        "description": "Unauthorized",
+                        "description": "OK",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/models.ApplicationResponse"
                         }
                     },
-                    "500": {
-                        "description": "Internal server error",
+                    "404": {
+                        "description": "Application Not Found",
                         "schema": {
                             "type": "string"
                         }
                     }
                 }
-            }
-        },
-        "/get-access-token": {
-            "post": {
-                "description": "Handles the sign-in process using application DID, credential JWT.",
+            },
+            "put": {
+                "description": "Replaces the name, details and token settings of the application with the given DID",
                 "consumes": [
                     "application/json"
                 ],
@@ -435,9 +481,9 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "User Access Management"
+                    "Application Management"
                 ],
-                "summary": "Sign in or get access token to an application",
+                "summary": "Update an application",
                 "parameters": [
                     {
                         "type": "string",
@@ -447,47 +493,51 @@ const docTemplate = `{
                         "required": true
                     },
                     {
-                        "type": "string",
-                        "description": "Application secret",
-                        "name": "app_secret",
-                        "in": "query",
-                        "required": true
-                    },
-                    {
-                        "description": "Application to create",
+                        "description": "Application details",
                         "name": "application",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/models.IssueOAuthCredential"
+                            "$ref": "#/definitions/models.ApplicationRequest"
                         }
-                    }
-                ],
-                "responses": {
-                    "200": {
-                        "description": "Access Token",
-                        "schema": {
-                            "$ref": "#/definitions/models.GetAccessTokenResponse"
+                    },
+                    {
+                        "type": "string",
+                        "description": "API Key",
+                        "name": "x-api-key",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.ApplicationResponse"
                         }
                     },
                     "400": {
-                        "description": "Bad request",
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Application Not Found",
                         "schema": {
                             "type": "string"
                         }
                     },
                     "500": {
-                        "description": "Internal server error",
+                        "description": "Internal Server Error",
                         "schema": {
                             "type": "string"
                         }
                     }
                 }
-            }
-        },
-        "/grant-access": {
-            "put": {
-                "description": "Grants access based on a valid request.",
+            },
+            "delete": {
+                "description": "Revokes the outstanding credentials issued by the application, then deletes it with its auth provider config, issued policy, user access and access requests. The credential records and the audit log are kept.",
                 "consumes": [
                     "application/json"
                 ],
@@ -495,9 +545,9 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Permission Management"
+                    "Application Management"
                 ],
-                "summary": "Grant access to a user",
+                "summary": "Delete an application",
                 "parameters": [
                     {
                         "type": "string",
@@ -508,21 +558,27 @@ const docTemplate = `{
                     },
                     {
                         "type": "string",
-                        "description": "Application secret",
-                        "name": "app_secret",
-                        "in": "query",
+                        "description": "API Key",
+                        "name": "x-api-key",
+                        "in": "header",
                         "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "implementation pending",
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.DeleteApplicationResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Application Not Found",
                         "schema": {
                             "type": "string"
                         }
                     },
-                    "405": {
-                        "description": "Only PUT method is allowed",
+                    "500": {
+                        "description": "Internal Server Error",
                         "schema": {
                             "type": "string"
                         }
@@ -530,9 +586,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/policies": {
+        "/applications": {
             "get": {
-                "description": "Retrieves a list of all policies",
+                "description": "Retrieves a list of all applications",
                 "consumes": [
                     "application/json"
                 ],
@@ -540,9 +596,9 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Authorization Management"
+                    "Application Management"
                 ],
-                "summary": "Get all policies",
+                "summary": "Get all applications",
                 "parameters": [
                     {
                         "type": "string",
@@ -558,7 +614,7 @@ const docTemplate = `{
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/models.PolicySchemaResponse"
+                                "$ref": "#/definitions/models.ApplicationResponse"
                             }
                         }
                     },
@@ -569,11 +625,62 @@ const docTemplate = `{
                         }
                     }
                 }
+            },
+            "post": {
+                "description": "Create a new application with the given details. The app secret is only returned in this response.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Application Management"
+                ],
+                "summary": "Create a new application",
+                "parameters": [
+                    {
+                        "description": "Application to create",
+                        "name": "application",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.ApplicationRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "API Key",
+                        "name": "x-api-key",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.ApplicationResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
             }
         },
-        "/request-access": {
+        "/applications/reactivate": {
             "post": {
-                "description": "Initiates a request for user access.",
+                "description": "Reactivates a suspended application.",
                 "consumes": [
                     "application/json"
                 ],
@@ -581,9 +688,9 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "User Access Management"
+                    "Application Management"
                 ],
-                "summary": "Request access for a user",
+                "summary": "Reactivate an application",
                 "parameters": [
                     {
                         "type": "string",
@@ -594,21 +701,27 @@ const docTemplate = `{
                     },
                     {
                         "type": "string",
-                        "description": "Application secret",
-                        "name": "app_secret",
-                        "in": "query",
+                        "description": "API Key",
+                        "name": "x-api-key",
+                        "in": "header",
                         "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "implementation pending",
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.ApplicationResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Application Not Found",
                         "schema": {
                             "type": "string"
                         }
                     },
-                    "405": {
-                        "description": "Only POST method is allowed",
+                    "500": {
+                        "description": "Internal Server Error",
                         "schema": {
                             "type": "string"
                         }
@@ -616,9 +729,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/revoke-access": {
-            "put": {
-                "description": "Revokes the access of a user.",
+        "/applications/rotate-secret": {
+            "post": {
+                "description": "Issues a new app secret, the previous one stays valid for the configured grace period. The new secret is only returned in this response.",
                 "consumes": [
                     "application/json"
                 ],
@@ -626,9 +739,9 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Permission Management"
+                    "Application Management"
                 ],
-                "summary": "Revoke access of a user",
+                "summary": "Rotate the app secret",
                 "parameters": [
                     {
                         "type": "string",
@@ -639,21 +752,33 @@ const docTemplate = `{
                     },
                     {
                         "type": "string",
-                        "description": "Application secret",
-                        "name": "app_secret",
-                        "in": "query",
+                        "description": "API Key",
+                        "name": "x-api-key",
+                        "in": "header",
                         "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "implementation pending",
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.RotateSecretResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
                         "schema": {
                             "type": "string"
                         }
                     },
-                    "405": {
-                        "description": "Only PUT method is allowed",
+                    "404": {
+                        "description": "Application Not Found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
                         "schema": {
                             "type": "string"
                         }
@@ -661,9 +786,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/revoke-credential": {
+        "/applications/suspend": {
             "post": {
-                "description": "Revoke an existing OAuth credential.",
+                "description": "Suspends the application, from then on it can not sign users in, issue access tokens or verify access until it is reactivated.",
                 "consumes": [
                     "application/json"
                 ],
@@ -671,35 +796,40 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Authentication Management"
+                    "Application Management"
                 ],
-                "summary": "Revoke OAuth Credential",
+                "summary": "Suspend an application",
                 "parameters": [
                     {
-                        "description": "Revoke Credential Request",
-                        "name": "revokeOAuthCredentialRequest",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "type": "object"
-                        }
+                        "type": "string",
+                        "description": "Application DID",
+                        "name": "app_did",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "API Key",
+                        "name": "x-api-key",
+                        "in": "header",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Credential successfully revoked",
+                        "description": "OK",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/models.ApplicationResponse"
                         }
                     },
-                    "400": {
-                        "description": "Bad request",
+                    "404": {
+                        "description": "Application Not Found",
                         "schema": {
                             "type": "string"
                         }
                     },
                     "500": {
-                        "description": "Internal server error",
+                        "description": "Internal Server Error",
                         "schema": {
                             "type": "string"
                         }
@@ -707,9 +837,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/signup": {
-            "get": {
-                "description": "Handles the sign-up process by providing a redirect URL for authentication.",
+        "/attach-policy": {
+            "post": {
+                "description": "Attaches a policy to an application using the provided application and issuer DID, and schema ID. Without a schema ID the latest version of the named policy is attached, deprecated versions are rejected. An application holds one RBAC and one ABAC policy, the type is taken from the credential when not given. Attaching another version of an attached policy upgrades the application and lists the credentials still issued against the replaced version. Roles of an RBAC policy may inherit other roles, undefined roles and inheritance cycles are rejected.",
                 "consumes": [
                     "application/json"
                 ],
@@ -717,43 +847,54 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "User Access Management"
+                    "Authorization Management"
                 ],
-                "summary": "Sign up for an application",
+                "summary": "Attach policy to application",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Application DID",
-                        "name": "app_did",
-                        "in": "query",
+                        "description": "API Key",
+                        "name": "x-api-key",
+                        "in": "header",
                         "required": true
                     },
                     {
-                        "type": "string",
-                        "description": "Application secret",
-                        "name": "app_secret",
-                        "in": "query",
-                        "required": true
+                        "description": "Application Policy Request",
+                        "name": "appPolicy",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.ApplicationPolicyRequest"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Redirect URL for sign-up",
+                        "description": "Successfully attached policy",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/models.AttachPolicyResponse"
                         }
                     },
                     "400": {
-                        "description": "Bad request",
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Policy Not Found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Another Policy Of The Type Is Attached",
                         "schema": {
                             "type": "string"
                         }
                     },
                     "500": {
-                        "description": "Internal server error",
+                        "description": "Internal Server Error",
                         "schema": {
                             "type": "string"
                         }
@@ -761,9 +902,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/verify-access": {
+        "/attached-policies": {
             "get": {
-                "description": "Verifies if a user has access to a specific resource based on their role.",
+                "description": "Lists the policies attached to an application, at most one RBAC and one ABAC policy",
                 "consumes": [
                     "application/json"
                 ],
@@ -771,15 +912,14 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "User Access Management"
+                    "Authorization Management"
                 ],
-                "summary": "Verify access to a resource",
+                "summary": "Get attached policies",
                 "parameters": [
                     {
                         "type": "string",
-                        "default": "Bearer YOUR_ACCESS_TOKEN",
-                        "description": "Authorization token",
-                        "name": "Authorization",
+                        "description": "API Key",
+                        "name": "x-api-key",
                         "in": "header",
                         "required": true
                     },
@@ -789,42 +929,1466 @@ const docTemplate = `{
                         "name": "app_did",
                         "in": "query",
                         "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "Application Secret",
-                        "name": "app_secret",
-                        "in": "query",
-                        "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "e.g.; Role to check access for",
-                        "name": "attribute",
-                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "success",
-                        "schema": {
-                            "type": "string"
-                        }
-                    },
-                    "400": {
-                        "description": "Bad request",
+                        "description": "OK",
                         "schema": {
-                            "type": "string"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.ApplicationPolicyResponse"
+                            }
                         }
                     },
-                    "401": {
-                        "description": "Unauthorized",
+                    "404": {
+                        "description": "Application Not Found",
                         "schema": {
                             "type": "string"
                         }
                     },
                     "500": {
-                        "description": "Internal server error",
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth-provider": {
+            "get": {
+                "description": "Retrieves a list of all auth providers",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Authentication Management"
+                ],
+                "summary": "Get available auth providers",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "API Key",
+                        "name": "x-api-key",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.AvailableProvider"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth-provider/link": {
+            "post": {
+                "description": "Links an OAuth provider to an application by its DID, linking the same provider again replaces its configuration",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Authentication Management"
+                ],
+                "summary": "Link Authentication Provider",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "API Key",
+                        "name": "x-api-key",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Authentication Provider Details",
+                        "name": "provider",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.AuthProvider"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successfully linked provider",
+                        "schema": {
+                            "$ref": "#/definitions/models.AuthProvider"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth-provider/unlink": {
+            "post": {
+                "description": "Unlinks an OAuth provider from an application, the other providers of the application stay linked",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Authentication Management"
+                ],
+                "summary": "UnLink Authentication Provider",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "API Key",
+                        "name": "x-api-key",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Provider to unlink",
+                        "name": "provider",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.UnlinkAuthProviderRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Providers still linked to the application",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.AuthProvider"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Provider Not Linked",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/authorize": {
+            "post": {
+                "description": "Evaluates the ABAC rules of the user's policy credential, or of the application policy when the credential carries none, against the subject attributes of the user and the action and resource attributes of the request. A matching deny rule overrides any permit rule and access is denied when no rule matches.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "User Access Management"
+                ],
+                "summary": "Authorize an action on a resource",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "default": "Bearer YOUR_ACCESS_TOKEN",
+                        "description": "Authorization token",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Proof of possession, required for bound tokens, each proof is accepted once",
+                        "name": "DPoP",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Application DID",
+                        "name": "app_did",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Application Secret",
+                        "name": "app_secret",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "description": "Action and resource attributes",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.AuthorizeRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Decision and the rule deciding it",
+                        "schema": {
+                            "$ref": "#/definitions/models.AuthorizeResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Credential revoked (credential_revoked), issued to another subject (subject_mismatch) or app suspended (app_suspended)",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/create-policy": {
+            "post": {
+                "description": "Creates a new policy based on the provided schema, it is version 1 of the policy. The name identifies the policy, it must not be taken.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Authorization Management"
+                ],
+                "summary": "Create a new policy",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "API Key",
+                        "name": "x-api-key",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Policy Schema",
+                        "name": "schema",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.PolicySchemaRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successfully created policy",
+                        "schema": {
+                            "$ref": "#/definitions/models.PolicySchemaResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Policy Already Exists",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/deprecate-policy": {
+            "post": {
+                "description": "Deprecates a policy version, it can no longer be attached. Applications and credentials already using it are left as they are.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Authorization Management"
+                ],
+                "summary": "Deprecate a policy version",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "API Key",
+                        "name": "x-api-key",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Schema ID of the version",
+                        "name": "schema_id",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Deprecated policy version",
+                        "schema": {
+                            "$ref": "#/definitions/models.PolicySchemaResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Policy Not Found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/detach-policy": {
+            "post": {
+                "description": "Detaches a policy from an application, the other attached policies are kept. Credentials already issued to users against it are listed, they stay valid until revoked.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Authorization Management"
+                ],
+                "summary": "Detach policy from application",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "API Key",
+                        "name": "x-api-key",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Policy to detach",
+                        "name": "policy",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.DetachPolicyRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Detached policy",
+                        "schema": {
+                            "$ref": "#/definitions/models.DetachPolicyResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Policy Not Attached",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/get-access-list": {
+            "get": {
+                "description": "List the access for the user on the resource.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "User Access Management"
+                ],
+                "summary": "Get Access list on the resource",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "default": "Bearer YOUR_ACCESS_TOKEN",
+                        "description": "Authorization token",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Proof of possession, required for bound tokens, each proof is accepted once",
+                        "name": "DPoP",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Application DID",
+                        "name": "app_did",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Application Secret",
+                        "name": "app_secret",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "success",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Credential revoked (credential_revoked), issued to another subject (subject_mismatch) or app suspended (app_suspended)",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/get-access-token": {
+            "post": {
+                "description": "Handles the sign-in process using application DID, credential JWT. The access token is signed with the active Ed25519 key, whose kid is in the token header and whose public key is published at /.well-known/jwks.json. An app set to the opaque format gets a short reference token instead, which is resolved server-side and can be checked at /introspect. The access token lives for the access token TTL of the app, 15 minutes by default, and comes with a refresh token for /refresh-token. A signed token names the service as its issuer, the app as its audience and the user DID as its subject. With a DPoP proof signed by a key of the user DID the tokens are bound to that key (cnf), every request made with them then needs a proof signed with the same key.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "User Access Management"
+                ],
+                "summary": "Sign in or get access token to an application",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Application DID",
+                        "name": "app_did",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Application secret",
+                        "name": "app_secret",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Proof of possession of a key of the user DID, binds the tokens to the key, each proof is accepted once",
+                        "name": "DPoP",
+                        "in": "header"
+                    },
+                    {
+                        "description": "Application to create",
+                        "name": "application",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.IssueOAuthCredential"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Access Token",
+                        "schema": {
+                            "$ref": "#/definitions/models.GetAccessTokenResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Credential revoked (credential_revoked), issued to another subject (subject_mismatch) or app suspended (app_suspended)",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/grant-access": {
+            "put": {
+                "description": "Grants a role defined in the application policy to a user. The policy credential of the user is re-issued with the new roles and the previous one is revoked. Granting a role the user already holds is a no-op.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Permission Management"
+                ],
+                "summary": "Grant access to a user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "API Key",
+                        "name": "x-api-key",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Access grant request",
+                        "name": "grant",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.AccessGrantRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.AccessGrantResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Application not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "405": {
+                        "description": "Only PUT method is allowed",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/introspect": {
+            "post": {
+                "description": "RFC 7662 token introspection of a signed or opaque access token. The app authenticates with its DID and secret, as form-urlencoded HTTP Basic credentials or as query parameters. The token is active while it is unexpired, issued for the app and its credentials are not revoked, the response then carries the issuer, the user DID, the app and the permissions of the user. A token bound to a key of the user DID carries the key in cnf, the resource server checks the DPoP proof of the request against it. Any other token is answered with active false only.",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Token Management"
+                ],
+                "summary": "Introspect an access token",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Application DID, unless sent as HTTP Basic credentials",
+                        "name": "app_did",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Application secret, unless sent as HTTP Basic credentials",
+                        "name": "app_secret",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Access token",
+                        "name": "token",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "access_token"
+                        ],
+                        "type": "string",
+                        "description": "Token type hint",
+                        "name": "token_type_hint",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.IntrospectionResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/policies": {
+            "get": {
+                "description": "Retrieves a list of all policies with their schema versions, oldest first",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Authorization Management"
+                ],
+                "summary": "Get all policies",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "API Key",
+                        "name": "x-api-key",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Policy"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/refresh-token": {
+            "post": {
+                "description": "Exchanges a refresh token for a new access token and a new refresh token, the presented refresh token is used up. Presenting a used refresh token again revokes every refresh token rotated out of the same sign in. The credentials are verified again, once one is revoked the user has to sign in again. A refresh token bound to a key of the user DID needs a DPoP proof signed with that key.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "User Access Management"
+                ],
+                "summary": "Refresh an access token",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Application DID",
+                        "name": "app_did",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Application secret",
+                        "name": "app_secret",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Proof of possession, required for bound tokens, each proof is accepted once",
+                        "name": "DPoP",
+                        "in": "header"
+                    },
+                    {
+                        "description": "Refresh token",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.RefreshTokenRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Access Token",
+                        "schema": {
+                            "$ref": "#/definitions/models.GetAccessTokenResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid or reused refresh token",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Credential revoked (credential_revoked), issued to another subject (subject_mismatch) or app suspended (app_suspended)",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/request-access": {
+            "get": {
+                "description": "POST asks for a role or a permission on the application and creates a pending access request for the owner to approve or deny. GET polls the status of a request.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "User Access Management"
+                ],
+                "summary": "Request access for a user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Application DID",
+                        "name": "app_did",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Application secret",
+                        "name": "app_secret",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Access request ID (GET only)",
+                        "name": "request_id",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Access request (POST only)",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/models.RequestAccessRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.AccessRequest"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Access request not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "POST asks for a role or a permission on the application and creates a pending access request for the owner to approve or deny. GET polls the status of a request.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "User Access Management"
+                ],
+                "summary": "Request access for a user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Application DID",
+                        "name": "app_did",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Application secret",
+                        "name": "app_secret",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Access request ID (GET only)",
+                        "name": "request_id",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Access request (POST only)",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/models.RequestAccessRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.AccessRequest"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Access request not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/revoke-access": {
+            "put": {
+                "description": "Removes a role from a user. The policy credential of the user is re-issued with the remaining roles and the previous one is revoked. Revoking a role the user does not hold is a no-op.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Permission Management"
+                ],
+                "summary": "Revoke access of a user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "API Key",
+                        "name": "x-api-key",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Access revoke request",
+                        "name": "revoke",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.AccessGrantRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.AccessGrantResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Application not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "405": {
+                        "description": "Only PUT method is allowed",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/revoke-credential": {
+            "post": {
+                "description": "Revoke an existing credential issued by the application by flipping its status list entry.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Authentication Management"
+                ],
+                "summary": "Revoke OAuth Credential",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "API Key",
+                        "name": "x-api-key",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Revoke Credential Request",
+                        "name": "revokeOAuthCredentialRequest",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.RevokeCredentialRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Credential successfully revoked",
+                        "schema": {
+                            "$ref": "#/definitions/models.CredentialRecord"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Credential not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/revoke-token": {
+            "post": {
+                "description": "RFC 7009 token revocation. An app authenticates with its DID and secret, as form-urlencoded HTTP Basic credentials or as query parameters, and can only revoke the tokens issued to it. A user logs out without app credentials, holding the token is enough. A revoked access token is denied until it expires, a revoked refresh token ends its token family. Unknown, expired and already revoked tokens are answered with 200 as well.",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "tags": [
+                    "Token Management"
+                ],
+                "summary": "Revoke an access token or a refresh token",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Application DID, unless sent as HTTP Basic credentials",
+                        "name": "app_did",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Application secret, unless sent as HTTP Basic credentials",
+                        "name": "app_secret",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Access token or refresh token",
+                        "name": "token",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "access_token",
+                            "refresh_token"
+                        ],
+                        "type": "string",
+                        "description": "Token type hint",
+                        "name": "token_type_hint",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Revoked",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid app credentials",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Token not issued to the app",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/signing-keys": {
+            "get": {
+                "description": "Lists the access token signing keys, newest first. The private keys are never returned.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Token Management"
+                ],
+                "summary": "List the access token signing keys",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "API Key",
+                        "name": "x-api-key",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.SigningKey"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/signing-keys/rotate": {
+            "post": {
+                "description": "Creates a new signing key for the access tokens. The key is published in the JWKS at once and signs from its activates_at on, once the cached JWKS expired. The previous key is retired then, it stays published until the tokens it signed expired.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Token Management"
+                ],
+                "summary": "Rotate the access token signing key",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "API Key",
+                        "name": "x-api-key",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "The new signing key",
+                        "schema": {
+                            "$ref": "#/definitions/models.SigningKey"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/signup": {
+            "get": {
+                "description": "Handles the sign-up process by providing a redirect URL for authentication.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "User Access Management"
+                ],
+                "summary": "Sign up for an application",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Application DID",
+                        "name": "app_did",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Application secret",
+                        "name": "app_secret",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Login URLs of the linked providers",
+                        "schema": {
+                            "$ref": "#/definitions/models.SignUpResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/update-policy": {
+            "post": {
+                "description": "Creates a new schema version of an existing policy. Attached applications keep their version until the new one is attached.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Authorization Management"
+                ],
+                "summary": "Add a policy version",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "API Key",
+                        "name": "x-api-key",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Policy Schema",
+                        "name": "schema",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.PolicySchemaRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successfully created policy version",
+                        "schema": {
+                            "$ref": "#/definitions/models.PolicySchemaResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Policy Not Found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/verify-access": {
+            "get": {
+                "description": "Verifies if a user has access to a specific resource based on their role and the permissions of all their roles, including the roles they inherit. When both a role and permissions are given, both must pass.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "User Access Management"
+                ],
+                "summary": "Verify access to a resource",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "default": "Bearer YOUR_ACCESS_TOKEN",
+                        "description": "Authorization token",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Proof of possession, required for bound tokens, each proof is accepted once",
+                        "name": "DPoP",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Application DID",
+                        "name": "app_did",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Application Secret",
+                        "name": "app_secret",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "e.g.; Role to check access for",
+                        "name": "attribute",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Permissions to check, repeated or comma separated",
+                        "name": "permission",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "any",
+                            "all"
+                        ],
+                        "type": "string",
+                        "description": "any (default) passes with one of the permissions, all requires every permission",
+                        "name": "mode",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "success",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Credential revoked (credential_revoked), issued to another subject (subject_mismatch) or app suspended (app_suspended)",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
                         "schema": {
                             "type": "string"
                         }
@@ -834,215 +2398,1001 @@ const docTemplate = `{
         }
     },
     "definitions": {
+        "models.APIKey": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "key_hash": {
+                    "type": "string"
+                },
+                "key_id": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "revoked": {
+                    "type": "boolean"
+                },
+                "revoked_at": {
+                    "type": "string"
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "models.AccessGrantRequest": {
+            "type": "object",
+            "required": [
+                "app_did",
+                "role_name",
+                "user_did"
+            ],
+            "properties": {
+                "app_did": {
+                    "type": "string"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "role_name": {
+                    "type": "string"
+                },
+                "user_did": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.AccessGrantResponse": {
+            "type": "object",
+            "properties": {
+                "access": {
+                    "$ref": "#/definitions/models.UserAccess"
+                },
+                "changed": {
+                    "type": "boolean"
+                },
+                "policy_credential": {
+                    "$ref": "#/definitions/models.CredentialResponse"
+                },
+                "revoked_credentials": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "models.AccessRequest": {
+            "type": "object",
+            "properties": {
+                "app_did": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "credential_id": {
+                    "type": "string"
+                },
+                "decision_reason": {
+                    "type": "string"
+                },
+                "granted_role": {
+                    "type": "string"
+                },
+                "permission": {
+                    "type": "string"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "request_id": {
+                    "type": "string"
+                },
+                "role_name": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "user_did": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.AccessRequestDecision": {
+            "type": "object",
+            "required": [
+                "request_id"
+            ],
+            "properties": {
+                "reason": {
+                    "type": "string"
+                },
+                "request_id": {
+                    "type": "string"
+                },
+                "role_name": {
+                    "type": "string"
+                }
+            }
+        },
         "models.AppDetails": {
             "type": "object",
             "required": [
-                "description",
-                "email"
+                "description",
+                "email"
+            ],
+            "properties": {
+                "description": {
+                    "type": "string",
+                    "maxLength": 500,
+                    "minLength": 10
+                },
+                "email": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.ApplicationPolicyRequest": {
+            "type": "object",
+            "required": [
+                "application_did",
+                "credential",
+                "issuer_did"
+            ],
+            "properties": {
+                "application_did": {
+                    "type": "string"
+                },
+                "credential": {
+                    "type": "object",
+                    "additionalProperties": true
+                },
+                "issuer_did": {
+                    "type": "string"
+                },
+                "policy_name": {
+                    "type": "string"
+                },
+                "policy_type": {
+                    "type": "string",
+                    "enum": [
+                        "rbac",
+                        "abac"
+                    ]
+                },
+                "schema_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.ApplicationPolicyResponse": {
+            "type": "object",
+            "properties": {
+                "application_did": {
+                    "type": "string"
+                },
+                "credential_id": {
+                    "type": "string"
+                },
+                "credential_subject": {},
+                "issuer_did": {
+                    "type": "string"
+                },
+                "policy_type": {
+                    "type": "string"
+                },
+                "schema_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.ApplicationRequest": {
+            "type": "object",
+            "required": [
+                "app_details",
+                "app_name"
+            ],
+            "properties": {
+                "access_token_format": {
+                    "description": "AccessTokenFormat is jwt (default) or opaque",
+                    "type": "string",
+                    "enum": [
+                        "jwt",
+                        "opaque"
+                    ]
+                },
+                "access_token_ttl": {
+                    "description": "token lifetimes in seconds, zero keeps the default. An access token lives at most 24 hours.",
+                    "type": "integer",
+                    "maximum": 86400,
+                    "minimum": 60
+                },
+                "app_details": {
+                    "$ref": "#/definitions/models.AppDetails"
+                },
+                "app_name": {
+                    "type": "string",
+                    "maxLength": 100,
+                    "minLength": 3
+                },
+                "refresh_token_ttl": {
+                    "type": "integer",
+                    "maximum": 7776000,
+                    "minimum": 3600
+                }
+            }
+        },
+        "models.ApplicationResponse": {
+            "type": "object",
+            "properties": {
+                "access_token_format": {
+                    "description": "AccessTokenFormat is jwt, a signed token carrying the credentials, or opaque, a reference to a token\nrecord kept by the service. Empty means jwt.",
+                    "type": "string"
+                },
+                "access_token_ttl": {
+                    "description": "AccessTokenTTL and RefreshTokenTTL are the token lifetimes in seconds, zero means the default",
+                    "type": "integer"
+                },
+                "app_details": {
+                    "$ref": "#/definitions/models.AppDetails"
+                },
+                "app_did": {
+                    "type": "string"
+                },
+                "app_name": {
+                    "type": "string"
+                },
+                "app_secret": {
+                    "type": "string"
+                },
+                "previous_secret_expires_at": {
+                    "type": "string"
+                },
+                "previous_secret_hash": {
+                    "type": "string"
+                },
+                "refresh_token_ttl": {
+                    "type": "integer"
+                },
+                "secret_hash": {
+                    "description": "only the hashes of the secrets are stored, the secret is shown once",
+                    "type": "string"
+                },
+                "status": {
+                    "description": "Status is active or suspended, a suspended app can not sign users in nor verify access",
+                    "type": "string"
+                },
+                "suspended_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.AttachPolicyResponse": {
+            "type": "object",
+            "properties": {
+                "application_did": {
+                    "type": "string"
+                },
+                "credential_id": {
+                    "type": "string"
+                },
+                "credential_subject": {},
+                "issuer_did": {
+                    "type": "string"
+                },
+                "policy_type": {
+                    "type": "string"
+                },
+                "previous_schema_id": {
+                    "type": "string"
+                },
+                "schema_id": {
+                    "type": "string"
+                },
+                "stale_credentials": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "models.AuditEvent": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "type": "string"
+                },
+                "app_did": {
+                    "type": "string"
+                },
+                "changed": {
+                    "type": "boolean"
+                },
+                "credential_id": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "revoked_credentials": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "role_name": {
+                    "type": "string"
+                },
+                "timestamp": {
+                    "type": "string"
+                },
+                "user_did": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.AuthProvider": {
+            "type": "object",
+            "required": [
+                "app_details",
+                "app_did",
+                "config"
+            ],
+            "properties": {
+                "app_details": {
+                    "$ref": "#/definitions/models.AvailableProvider"
+                },
+                "app_did": {
+                    "type": "string"
+                },
+                "config": {
+                    "$ref": "#/definitions/models.OAuthConfig"
+                }
+            }
+        },
+        "models.AuthorizeRequest": {
+            "type": "object",
+            "required": [
+                "action_attributes"
+            ],
+            "properties": {
+                "action_attributes": {
+                    "type": "object",
+                    "additionalProperties": true
+                },
+                "resource_attributes": {
+                    "type": "object",
+                    "additionalProperties": true
+                }
+            }
+        },
+        "models.AuthorizeResponse": {
+            "type": "object",
+            "properties": {
+                "decision": {
+                    "type": "string"
+                },
+                "rule_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.AvailableProvider": {
+            "type": "object",
+            "required": [
+                "provider_name",
+                "provider_protocol",
+                "provider_schema_id",
+                "provider_type"
+            ],
+            "properties": {
+                "provider_name": {
+                    "type": "string"
+                },
+                "provider_protocol": {
+                    "type": "string"
+                },
+                "provider_schema_id": {
+                    "type": "string"
+                },
+                "provider_type": {
+                    "description": "social, email, phone, etc",
+                    "type": "string"
+                }
+            }
+        },
+        "models.Confirmation": {
+            "type": "object",
+            "properties": {
+                "kid": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.CreateAPIKeyRequest": {
+            "type": "object",
+            "required": [
+                "name",
+                "scopes"
+            ],
+            "properties": {
+                "name": {
+                    "type": "string"
+                },
+                "scopes": {
+                    "type": "array",
+                    "minItems": 1,
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "models.CreateAPIKeyResponse": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "key": {
+                    "type": "string"
+                },
+                "key_hash": {
+                    "type": "string"
+                },
+                "key_id": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "revoked": {
+                    "type": "boolean"
+                },
+                "revoked_at": {
+                    "type": "string"
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "models.CredentialRecord": {
+            "type": "object",
+            "properties": {
+                "app_did": {
+                    "type": "string"
+                },
+                "credential_id": {
+                    "type": "string"
+                },
+                "issued_at": {
+                    "type": "string"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "revoked": {
+                    "type": "boolean"
+                },
+                "revoked_at": {
+                    "type": "string"
+                },
+                "schema_id": {
+                    "type": "string"
+                },
+                "subject_did": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.CredentialResponse": {
+            "type": "object",
+            "properties": {
+                "credential": {
+                    "type": "object"
+                },
+                "credentialJwt": {
+                    "type": "string"
+                },
+                "fullyQualifiedVerificationMethodId": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.DeleteApplicationResponse": {
+            "type": "object",
+            "properties": {
+                "app_did": {
+                    "type": "string"
+                },
+                "revoked_credentials": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "models.DetachPolicyRequest": {
+            "type": "object",
+            "required": [
+                "application_did",
+                "schema_id"
+            ],
+            "properties": {
+                "application_did": {
+                    "type": "string"
+                },
+                "schema_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.DetachPolicyResponse": {
+            "type": "object",
+            "properties": {
+                "application_did": {
+                    "type": "string"
+                },
+                "credential_id": {
+                    "type": "string"
+                },
+                "credential_subject": {},
+                "issuer_did": {
+                    "type": "string"
+                },
+                "policy_type": {
+                    "type": "string"
+                },
+                "schema_id": {
+                    "type": "string"
+                },
+                "stale_credentials": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "models.ErrorResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.GetAccessTokenResponse": {
+            "type": "object",
+            "properties": {
+                "access_token": {
+                    "type": "string"
+                },
+                "expires_in": {
+                    "description": "ExpiresIn and RefreshTokenExpiresIn are the token lifetimes in seconds",
+                    "type": "integer"
+                },
+                "refresh_token": {
+                    "type": "string"
+                },
+                "refresh_token_expires_in": {
+                    "type": "integer"
+                },
+                "token_type": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.IntrospectionResponse": {
+            "type": "object",
+            "properties": {
+                "active": {
+                    "type": "boolean"
+                },
+                "app_did": {
+                    "type": "string"
+                },
+                "aud": {
+                    "type": "string"
+                },
+                "client_id": {
+                    "type": "string"
+                },
+                "cnf": {
+                    "$ref": "#/definitions/models.Confirmation"
+                },
+                "exp": {
+                    "type": "integer"
+                },
+                "iat": {
+                    "type": "integer"
+                },
+                "iss": {
+                    "type": "string"
+                },
+                "jti": {
+                    "type": "string"
+                },
+                "nbf": {
+                    "type": "integer"
+                },
+                "permissions": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "roles": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "scope": {
+                    "type": "string"
+                },
+                "sub": {
+                    "type": "string"
+                },
+                "token_type": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.IssueOAuthCredential": {
+            "type": "object",
+            "required": [
+                "oauth_credential",
+                "policy_credential"
             ],
             "properties": {
-                "description": {
-                    "type": "string",
-                    "maxLength": 500,
-                    "minLength": 10
+                "oauth_credential": {},
+                "policy_credential": {}
+            }
+        },
+        "models.JWK": {
+            "type": "object",
+            "properties": {
+                "alg": {
+                    "type": "string"
                 },
-                "email": {
+                "crv": {
+                    "type": "string"
+                },
+                "kid": {
+                    "type": "string"
+                },
+                "kty": {
+                    "type": "string"
+                },
+                "use": {
+                    "type": "string"
+                },
+                "x": {
                     "type": "string"
                 }
             }
         },
-        "models.ApplicationPolicyRequest": {
+        "models.JWKS": {
             "type": "object",
-            "required": [
-                "application_did",
-                "credential",
-                "issuer_did",
-                "schema_id"
-            ],
             "properties": {
-                "application_did": {
+                "keys": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.JWK"
+                    }
+                }
+            }
+        },
+        "models.JsonSchema": {
+            "type": "object",
+            "properties": {
+                "$schema": {
                     "type": "string"
                 },
-                "credential": {
+                "properties": {
                     "type": "object",
                     "additionalProperties": true
                 },
-                "issuer_did": {
+                "required": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.LoginURL": {
+            "type": "object",
+            "properties": {
+                "provider": {
                     "type": "string"
                 },
-                "schema_id": {
+                "url": {
                     "type": "string"
                 }
             }
         },
-        "models.ApplicationPolicyResponse": {
+        "models.OAuthConfig": {
             "type": "object",
             "properties": {
-                "application_did": {
+                "auth_url": {
+                    "description": "overrides the authorization endpoint of the provider",
                     "type": "string"
                 },
-                "credential_id": {
+                "client_id": {
                     "type": "string"
                 },
-                "credential_subject": {},
-                "issuer_did": {
+                "client_secret": {
                     "type": "string"
                 },
-                "schema_id": {
+                "redirect_url": {
+                    "type": "string"
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "token_url": {
+                    "description": "overrides the token endpoint of the provider",
                     "type": "string"
                 }
             }
         },
-        "models.ApplicationRequest": {
+        "models.Policy": {
+            "type": "object",
+            "properties": {
+                "latest_schema_id": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "versions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.PolicySchemaResponse"
+                    }
+                }
+            }
+        },
+        "models.PolicySchemaRequest": {
             "type": "object",
             "required": [
-                "app_details",
-                "app_name"
+                "name"
             ],
             "properties": {
-                "app_details": {
-                    "$ref": "#/definitions/models.AppDetails"
+                "name": {
+                    "type": "string"
                 },
-                "app_name": {
-                    "type": "string",
-                    "maxLength": 100,
-                    "minLength": 3
+                "schema": {
+                    "$ref": "#/definitions/models.JsonSchema"
                 }
             }
         },
-        "models.ApplicationResponse": {
+        "models.PolicySchemaResponse": {
             "type": "object",
             "properties": {
-                "app_details": {
-                    "$ref": "#/definitions/models.AppDetails"
+                "deprecated": {
+                    "type": "boolean"
                 },
-                "app_did": {
+                "deprecated_at": {
                     "type": "string"
                 },
-                "app_name": {
+                "id": {
                     "type": "string"
                 },
-                "app_secret": {
+                "name": {
                     "type": "string"
+                },
+                "schema": {
+                    "$ref": "#/definitions/models.JsonSchema"
+                },
+                "version": {
+                    "type": "integer"
                 }
             }
         },
-        "models.AuthProvider": {
+        "models.RefreshTokenRequest": {
             "type": "object",
             "required": [
-                "app_details",
-                "app_did",
-                "config"
+                "refresh_token"
             ],
             "properties": {
-                "app_details": {
-                    "$ref": "#/definitions/models.AvailableProvider"
-                },
-                "app_did": {
+                "refresh_token": {
                     "type": "string"
-                },
-                "config": {
-                    "$ref": "#/definitions/models.OAuthConfig"
                 }
             }
         },
-        "models.AvailableProvider": {
+        "models.RequestAccessRequest": {
             "type": "object",
             "required": [
-                "provider_name",
-                "provider_protocol",
-                "provider_schema_id",
-                "provider_type"
+                "user_did"
             ],
             "properties": {
-                "provider_name": {
+                "permission": {
                     "type": "string"
                 },
-                "provider_protocol": {
+                "reason": {
                     "type": "string"
                 },
-                "provider_schema_id": {
+                "role_name": {
                     "type": "string"
                 },
-                "provider_type": {
-                    "description": "social, email, phone, etc",
+                "user_did": {
                     "type": "string"
                 }
             }
         },
-        "models.GetAccessTokenResponse": {
+        "models.RevokeCredentialRequest": {
             "type": "object",
+            "required": [
+                "app_did",
+                "credential_id"
+            ],
             "properties": {
-                "access_token": {
+                "app_did": {
+                    "type": "string"
+                },
+                "credential_id": {
+                    "type": "string"
+                },
+                "reason": {
                     "type": "string"
                 }
             }
         },
-        "models.IssueOAuthCredential": {
+        "models.Role": {
             "type": "object",
-            "required": [
-                "oauth_credential",
-                "policy_credential"
-            ],
             "properties": {
-                "oauth_credential": {},
-                "policy_credential": {}
+                "inherits": {
+                    "description": "roles whose permissions this role also carries",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "permissions": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "roleName": {
+                    "type": "string"
+                }
             }
         },
-        "models.JsonSchema": {
+        "models.RotateSecretResponse": {
             "type": "object",
             "properties": {
-                "$schema": {
+                "app_did": {
                     "type": "string"
                 },
-                "properties": {
-                    "type": "object",
-                    "additionalProperties": true
+                "app_secret": {
+                    "type": "string"
                 },
-                "required": {
+                "previous_secret_expires_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.SignUpResponse": {
+            "type": "object",
+            "properties": {
+                "app_did": {
+                    "type": "string"
+                },
+                "login_urls": {
                     "type": "array",
                     "items": {
-                        "type": "string"
+                        "$ref": "#/definitions/models.LoginURL"
                     }
                 },
-                "type": {
+                "redirect_url": {
                     "type": "string"
                 }
             }
         },
-        "models.OAuthConfig": {
+        "models.SigningKey": {
             "type": "object",
             "properties": {
-                "client_id": {
+                "activates_at": {
                     "type": "string"
                 },
-                "redirect_url": {
+                "alg": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "kid": {
+                    "type": "string"
+                },
+                "private_key": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "public_key": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "retired_at": {
                     "type": "string"
                 }
             }
         },
-        "models.PolicySchemaRequest": {
+        "models.UnlinkAuthProviderRequest": {
             "type": "object",
+            "required": [
+                "app_did",
+                "provider"
+            ],
             "properties": {
-                "name": {
+                "app_did": {
                     "type": "string"
                 },
-                "schema": {
-                    "$ref": "#/definitions/models.JsonSchema"
+                "provider": {
+                    "type": "string"
                 }
             }
         },
-        "models.PolicySchemaResponse": {
+        "models.UserAccess": {
             "type": "object",
             "properties": {
-                "id": {
+                "app_did": {
                     "type": "string"
                 },
-                "name": {
+                "policy_credential_id": {
                     "type": "string"
                 },
-                "schema": {
-                    "$ref": "#/definitions/models.JsonSchema"
+                "roles": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Role"
+                    }
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "user_did": {
+                    "type": "string"
                 }
             }
         }
@@ -2,6 +2,7 @@ package models
 
 import (
 	"encoding/json"
+	"time"
 
 	credsdk "github.com/TBD54566975/ssi-sdk/credential"
 	didsdk "github.com/TBD54566975/ssi-sdk/did"
@@ -10,6 +11,11 @@ import (
 type ApplicationRequest struct {
 	AppName    string     `json:"app_name" validate:"required,min=3,max=100"`
 	AppDetails AppDetails `json:"app_details" validate:"required,dive"`
+	// AccessTokenFormat is jwt (default) or opaque
+	AccessTokenFormat string `json:"access_token_format,omitempty" validate:"omitempty,oneof=jwt opaque"`
+	// token lifetimes in seconds, zero keeps the default. An access token lives at most 24 hours.
+	AccessTokenTTL  int64 `json:"access_token_ttl,omitempty" validate:"omitempty,min=60,max=86400"`
+	RefreshTokenTTL int64 `json:"refresh_token_ttl,omitempty" validate:"omitempty,min=3600,max=7776000"`
 }
 
 type AppDetails struct {
@@ -19,9 +25,43 @@ type AppDetails struct {
 
 type ApplicationResponse struct {
 	AppDID     string     `json:"app_did"`
-	AppSceret  string     `json:"app_secret"`
+	AppSceret  string     `json:"app_secret,omitempty"`
 	AppName    string     `json:"app_name"`
 	AppDetails AppDetails `json:"app_details"`
+	// Status is active or suspended, a suspended app can not sign users in nor verify access
+	Status      string     `json:"status,omitempty"`
+	SuspendedAt *time.Time `json:"suspended_at,omitempty"`
+	// only the hashes of the secrets are stored, the secret is shown once
+	SecretHash              string     `json:"secret_hash,omitempty"`
+	PreviousSecretHash      string     `json:"previous_secret_hash,omitempty"`
+	PreviousSecretExpiresAt *time.Time `json:"previous_secret_expires_at,omitempty"`
+	// AccessTokenFormat is jwt, a signed token carrying the credentials, or opaque, a reference to a token
+	// record kept by the service. Empty means jwt.
+	AccessTokenFormat string `json:"access_token_format,omitempty"`
+	// AccessTokenTTL and RefreshTokenTTL are the token lifetimes in seconds, zero means the default
+	AccessTokenTTL  int64 `json:"access_token_ttl,omitempty"`
+	RefreshTokenTTL int64 `json:"refresh_token_ttl,omitempty"`
+}
+
+const (
+	AppStatusActive    = "active"
+	AppStatusSuspended = "suspended"
+
+	AccessTokenFormatJWT    = "jwt"
+	AccessTokenFormatOpaque = "opaque"
+)
+
+// DeleteApplicationResponse lists the credentials revoked with the deleted application
+type DeleteApplicationResponse struct {
+	AppDID             string   `json:"app_did"`
+	RevokedCredentials []string `json:"revoked_credentials"`
+}
+
+// RotateSecretResponse carries the new app secret, the previous one stays valid until PreviousSecretExpiresAt
+type RotateSecretResponse struct {
+	AppDID                  string    `json:"app_did"`
+	AppSecret               string    `json:"app_secret"`
+	PreviousSecretExpiresAt time.Time `json:"previous_secret_expires_at"`
 }
 
 type DidCreationResponse struct {
@@ -29,13 +69,23 @@ type DidCreationResponse struct {
 }
 
 type PolicySchemaResponse struct {
-	ID     string     `json:"id"`
-	Name   string     `json:"name"`
-	Schema JsonSchema `json:"schema"`
+	ID           string     `json:"id"`
+	Name         string     `json:"name"`
+	Version      int        `json:"version"`
+	Deprecated   bool       `json:"deprecated"`
+	DeprecatedAt *time.Time `json:"deprecated_at,omitempty"`
+	Schema       JsonSchema `json:"schema"`
+}
+
+// Policy groups the schema versions sharing a policy name, LatestSchemaID is the newest version not deprecated
+type Policy struct {
+	Name           string                 `json:"name"`
+	LatestSchemaID string                 `json:"latest_schema_id,omitempty"`
+	Versions       []PolicySchemaResponse `json:"versions"`
 }
 
 type PolicySchemaRequest struct {
-	Name   string     `json:"name"`
+	Name   string     `json:"name" validate:"required"`
 	Schema JsonSchema `json:"schema"`
 }
 
@@ -48,40 +98,116 @@ type JsonSchema struct {
 
 type ApplicationPolicyRequest struct {
 	ApplicationDID string                 `json:"application_did" validate:"required"`
-	SchemaID       string                 `json:"schema_id" validate:"required"`
+	SchemaID       string                 `json:"schema_id" validate:"required_without=PolicyName"`
+	PolicyName     string                 `json:"policy_name,omitempty"`
+	PolicyType     string                 `json:"policy_type,omitempty" validate:"omitempty,oneof=rbac abac"`
 	IssuerDID      string                 `json:"issuer_did" validate:"required"`
 	Credential     map[string]interface{} `json:"credential" validate:"required"`
 }
 type ApplicationPolicyResponse struct {
 	ApplicationDID    string      `json:"application_did"`
 	SchemaID          string      `json:"schema_id"`
+	PolicyType        string      `json:"policy_type"`
 	IssuerDID         string      `json:"issuer_did"`
 	CredentialID      string      `json:"credential_id"`
 	CredentialSubject interface{} `json:"credential_subject"`
 }
 
+// An app attaches at most one policy of each type, the RBAC policy defines the roles of its users and the ABAC
+// policy the rules evaluated for them
+const (
+	PolicyTypeRBAC = "rbac"
+	PolicyTypeABAC = "abac"
+)
+
+// DetachPolicyRequest names the policy to detach from the application
+type DetachPolicyRequest struct {
+	ApplicationDID string `json:"application_did" validate:"required"`
+	SchemaID       string `json:"schema_id" validate:"required"`
+}
+
+// DetachPolicyResponse is the detached policy with the credentials still issued to users against it
+type DetachPolicyResponse struct {
+	ApplicationPolicyResponse
+	StaleCredentials []string `json:"stale_credentials"`
+}
+
+// AttachPolicyResponse is the attached policy, on an upgrade it lists the credentials still issued against the
+// schema it replaced
+type AttachPolicyResponse struct {
+	ApplicationPolicyResponse
+	PreviousSchemaID string   `json:"previous_schema_id,omitempty"`
+	StaleCredentials []string `json:"stale_credentials,omitempty"`
+}
+
 type CredentialRequest struct {
 	Issuer               string                 `json:"issuer"`
 	VerificationMethodID string                 `json:"verificationMethodId"`
 	Subject              string                 `json:"subject"`
 	SchemaID             string                 `json:"schemaId"`
 	Data                 map[string]interface{} `json:"data"`
-	// TODO:: add revoked
+	Revocable            bool                   `json:"revocable"`
 }
 
 type CredentialResponse struct {
 	ID                                 string                        `json:"id"`
 	FullyQualifiedVerificationMethodID string                        `json:"fullyQualifiedVerificationMethodId"`
-	Credential                         *credsdk.VerifiableCredential `json:"credential,omitempty"`
+	Credential                         *credsdk.VerifiableCredential `json:"credential,omitempty" swaggertype:"object"`
 	CredentialJwt                      string                        `json:"credentialJwt"`
 }
 
+type CredentialStatusRequest struct {
+	Revoked bool `json:"revoked"`
+}
+
+type CredentialStatusResponse struct {
+	Revoked   bool `json:"revoked"`
+	Suspended bool `json:"suspended"`
+}
+
+type RevokeCredentialRequest struct {
+	AppDID       string `json:"app_did" validate:"required"`
+	CredentialID string `json:"credential_id" validate:"required"`
+	Reason       string `json:"reason"`
+}
+
+// CredentialRecord tracks a credential issued on behalf of an application and its revocation state.
+type CredentialRecord struct {
+	CredentialID string     `json:"credential_id"`
+	AppDID       string     `json:"app_did"`
+	SubjectDID   string     `json:"subject_did"`
+	SchemaID     string     `json:"schema_id"`
+	IssuedAt     time.Time  `json:"issued_at"`
+	Revoked      bool       `json:"revoked"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+	Reason       string     `json:"reason,omitempty"`
+}
+
 type AuthProvider struct {
 	AppDID   string            `json:"app_did" validate:"required"`
 	Provider AvailableProvider `json:"app_details" validate:"required,dive"`
 	Config   OAuthConfig       `json:"config" validate:"required,dive"`
 }
 
+// UnlinkAuthProviderRequest names the provider to unlink from the application
+type UnlinkAuthProviderRequest struct {
+	AppDID   string `json:"app_did" validate:"required"`
+	Provider string `json:"provider" validate:"required"`
+}
+
+// LoginURL is the url the user signs in at with the provider
+type LoginURL struct {
+	Provider string `json:"provider"`
+	URL      string `json:"url"`
+}
+
+// SignUpResponse lists the login urls of every provider linked to the app, RedirectURL is the first of them
+type SignUpResponse struct {
+	AppDID      string     `json:"app_did"`
+	RedirectURL string     `json:"redirect_url"`
+	LoginURLs   []LoginURL `json:"login_urls"`
+}
+
 type AvailableProvider struct {
 	ProviderName     string `json:"provider_name" validate:"required"`
 	ProviderType     string `json:"provider_type" validate:"required"` // social, email, phone, etc
@@ -91,12 +217,32 @@ type AvailableProvider struct {
 
 // OAuthConfig holds the configuration for OAuth authentication
 type OAuthConfig struct {
-	ClientID    string `json:"client_id"`
-	RedirectURL string `json:"redirect_url"`
-	// ClientSecret string   `json:"clientSecret"`
-	// Scopes       []string `json:"scopes"`
-	// AuthURL      string   `json:"authUrl"`
-	// TokenURL     string   `json:"tokenUrl"`
+	ClientID     string   `json:"client_id"`
+	RedirectURL  string   `json:"redirect_url"`
+	ClientSecret string   `json:"client_secret,omitempty"`
+	Scopes       []string `json:"scopes,omitempty"`
+	AuthURL      string   `json:"auth_url,omitempty"`  // overrides the authorization endpoint of the provider
+	TokenURL     string   `json:"token_url,omitempty"` // overrides the token endpoint of the provider
+}
+
+// AuthSession binds a sign-in attempt to the browser that started it, it is consumed by the callback
+// of whichever of the providers the user signs in with
+type AuthSession struct {
+	State        string    `json:"state"`
+	AppDID       string    `json:"app_did"`
+	Providers    []string  `json:"providers"`
+	Nonce        string    `json:"nonce,omitempty"`
+	CodeVerifier string    `json:"code_verifier,omitempty"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// LoginSession holds the user info fetched server-side once the user signed in with the provider
+type LoginSession struct {
+	SessionID string    `json:"session_id"`
+	AppDID    string    `json:"app_did"`
+	Provider  string    `json:"provider"`
+	UserInfo  UserInfo  `json:"user_info"`
+	ExpiresAt time.Time `json:"expires_at"`
 }
 
 type ProviderSchema struct {
@@ -105,10 +251,9 @@ type ProviderSchema struct {
 }
 
 type IssueOAuthCredentialRequest struct {
-	AppDID      string `json:"app_did" validate:"required"`
-	Provider    string `json:"provider" validate:"required"`
-	AccessToken string `json:"access_token" validate:"required"`
-	UserDID     string `json:"user_did" validate:"required"`
+	AppDID   string `json:"app_did" validate:"required"`
+	Provider string `json:"provider" validate:"required"`
+	UserDID  string `json:"user_did" validate:"required"`
 }
 
 type IssueOAuthCredential struct {
@@ -125,6 +270,93 @@ type UserInfo struct {
 
 type GetAccessTokenResponse struct {
 	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	// ExpiresIn and RefreshTokenExpiresIn are the token lifetimes in seconds
+	ExpiresIn             int64  `json:"expires_in"`
+	RefreshToken          string `json:"refresh_token"`
+	RefreshTokenExpiresIn int64  `json:"refresh_token_expires_in"`
+}
+
+// ErrorResponse is the body of the errors a client has to tell apart, Error is one of the error codes below
+type ErrorResponse struct {
+	Error   string `json:"error"`
+	Message string `json:"message"`
+}
+
+// Error codes of the 403 responses of the user access endpoints.
+const (
+	ErrorCredentialRevoked = "credential_revoked"
+	ErrorSubjectMismatch   = "subject_mismatch"
+	ErrorAppSuspended      = "app_suspended"
+)
+
+// RevokedToken is the deny-list entry of a revoked access token, kept until the token expired
+type RevokedToken struct {
+	TokenID   string    `json:"jti"`
+	AppDID    string    `json:"app_did"`
+	RevokedAt time.Time `json:"revoked_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+// RefreshToken is the record of a refresh token, only the hash of the token is kept. A refresh token is used
+// once, the tokens rotated out of one sign in share a family.
+type RefreshToken struct {
+	TokenHash string `json:"token_hash"`
+	FamilyID  string `json:"family_id"`
+	TokenGrant
+	IssuedAt  time.Time  `json:"issued_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// TokenGrant is what the tokens of a sign in stand for: the app, the user with their credentials and the key of
+// the user DID the tokens are bound to, if any.
+type TokenGrant struct {
+	AppDID           string        `json:"app_did"`
+	SubjectDID       string        `json:"subject_did,omitempty"`
+	OAuthCredential  string        `json:"oauth_credential"`
+	PolicyCredential string        `json:"policy_credential"`
+	Confirmation     *Confirmation `json:"cnf,omitempty"`
+}
+
+// Confirmation binds a token to a key (RFC 7800), the kid is the DID URL of a key of the user DID. Every request
+// made with a bound token carries a proof signed with that key.
+type Confirmation struct {
+	KeyID string `json:"kid"`
+}
+
+// AccessToken is the record an access token stands for. Opaque tokens are stored, only the hash of the
+// token is kept, a signed token carries the same in its claims.
+type AccessToken struct {
+	TokenID   string `json:"jti"`
+	TokenHash string `json:"token_hash,omitempty"`
+	TokenGrant
+	IssuedAt  time.Time `json:"issued_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// IntrospectionResponse is the RFC 7662 introspection response, an inactive token only has active set
+type IntrospectionResponse struct {
+	Active       bool          `json:"active"`
+	TokenID      string        `json:"jti,omitempty"`
+	TokenType    string        `json:"token_type,omitempty"`
+	ClientID     string        `json:"client_id,omitempty"`
+	AppDID       string        `json:"app_did,omitempty"`
+	Issuer       string        `json:"iss,omitempty"`
+	Audience     string        `json:"aud,omitempty"`
+	Subject      string        `json:"sub,omitempty"`
+	Scope        string        `json:"scope,omitempty"`
+	Roles        []string      `json:"roles,omitempty"`
+	Permissions  []string      `json:"permissions,omitempty"`
+	IssuedAt     int64         `json:"iat,omitempty"`
+	NotBefore    int64         `json:"nbf,omitempty"`
+	ExpiresAt    int64         `json:"exp,omitempty"`
+	Confirmation *Confirmation `json:"cnf,omitempty"`
 }
 
 type VerifyAccessRequest struct {
@@ -160,13 +392,189 @@ type RBAC struct {
 type Role struct {
 	RoleName    string   `json:"roleName"`
 	Permissions []string `json:"permissions"`
+	Inherits    []string `json:"inherits,omitempty"` // roles whose permissions this role also carries
 }
 
 type RolesWrapper struct {
 	Roles []Role `json:"roles"`
 }
 
+const (
+	EffectPermit = "permit"
+	EffectDeny   = "deny"
+)
+
+// ABACRule grants or denies an action when the subject, action and resource attributes all match
+type ABACRule struct {
+	RuleID             string                 `json:"ruleId"`
+	SubjectAttributes  map[string]interface{} `json:"subjectAttributes"`
+	ActionAttributes   map[string]interface{} `json:"actionAttributes"`
+	ResourceAttributes map[string]interface{} `json:"resourceAttributes"`
+	Effect             string                 `json:"effect"`
+}
+
+type RulesWrapper struct {
+	Rules []ABACRule `json:"rules"`
+}
+
+// AuthorizeRequest carries the attributes of the action the user wants to perform on a resource
+type AuthorizeRequest struct {
+	ActionAttributes   map[string]interface{} `json:"action_attributes" validate:"required"`
+	ResourceAttributes map[string]interface{} `json:"resource_attributes"`
+}
+
+// AuthorizeResponse is the outcome of the ABAC evaluation and the rule deciding it
+type AuthorizeResponse struct {
+	Decision string `json:"decision"`
+	RuleID   string `json:"rule_id,omitempty"`
+}
+
+type AccessGrantRequest struct {
+	AppDID   string `json:"app_did" validate:"required"`
+	UserDID  string `json:"user_did" validate:"required"`
+	RoleName string `json:"role_name" validate:"required"`
+	Reason   string `json:"reason"`
+}
+
+// UserAccess holds the roles granted to a user on an application and the policy credential carrying them.
+type UserAccess struct {
+	AppDID             string    `json:"app_did"`
+	UserDID            string    `json:"user_did"`
+	Roles              []Role    `json:"roles"`
+	PolicyCredentialID string    `json:"policy_credential_id,omitempty"`
+	UpdatedAt          time.Time `json:"updated_at"`
+}
+
+type AccessGrantResponse struct {
+	Changed            bool                `json:"changed"`
+	Access             UserAccess          `json:"access"`
+	PolicyCredential   *CredentialResponse `json:"policy_credential,omitempty"`
+	RevokedCredentials []string            `json:"revoked_credentials,omitempty"`
+}
+
+// AuditEvent records a change made to the access of a user on an application.
+type AuditEvent struct {
+	ID                 string    `json:"id"`
+	AppDID             string    `json:"app_did"`
+	UserDID            string    `json:"user_did"`
+	Action             string    `json:"action"`
+	RoleName           string    `json:"role_name"`
+	Changed            bool      `json:"changed"`
+	CredentialID       string    `json:"credential_id,omitempty"`
+	RevokedCredentials []string  `json:"revoked_credentials,omitempty"`
+	Reason             string    `json:"reason,omitempty"`
+	Timestamp          time.Time `json:"timestamp"`
+}
+
+const (
+	AccessRequestPending  = "pending"
+	AccessRequestApproved = "approved"
+	AccessRequestDenied   = "denied"
+)
+
+type RequestAccessRequest struct {
+	UserDID    string `json:"user_did" validate:"required"`
+	RoleName   string `json:"role_name" validate:"required_without=Permission"`
+	Permission string `json:"permission" validate:"required_without=RoleName"`
+	Reason     string `json:"reason"`
+}
+
+// AccessRequest is a request of a user for a role or permission on an application, awaiting the owner decision.
+type AccessRequest struct {
+	RequestID      string    `json:"request_id"`
+	AppDID         string    `json:"app_did"`
+	UserDID        string    `json:"user_did"`
+	RoleName       string    `json:"role_name,omitempty"`
+	Permission     string    `json:"permission,omitempty"`
+	Reason         string    `json:"reason,omitempty"`
+	Status         string    `json:"status"`
+	GrantedRole    string    `json:"granted_role,omitempty"`
+	DecisionReason string    `json:"decision_reason,omitempty"`
+	CredentialID   string    `json:"credential_id,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+type AccessRequestDecision struct {
+	RequestID string `json:"request_id" validate:"required"`
+	RoleName  string `json:"role_name"`
+	Reason    string `json:"reason"`
+}
+
 type AccessList struct {
-	ApplicationPolicy interface{} `json:"application_policy"`
-	UserAccessList    interface{} `json:"user_access_list"`
+	ApplicationPolicy   interface{}            `json:"application_policy"`
+	ApplicationPolicies map[string]interface{} `json:"application_policies"`
+	UserAccessList      interface{}            `json:"user_access_list"`
+}
+
+// Scopes of the admin API keys, the read scope covers the GET requests of a route and the write scope the others.
+const (
+	ScopeAll              = "*"
+	ScopeAppsRead         = "apps:read"
+	ScopeAppsWrite        = "apps:write"
+	ScopeProvidersRead    = "providers:read"
+	ScopeProvidersWrite   = "providers:write"
+	ScopePoliciesRead     = "policies:read"
+	ScopePoliciesWrite    = "policies:write"
+	ScopeAccessRead       = "access:read"
+	ScopeAccessWrite      = "access:write"
+	ScopeCredentialsWrite = "credentials:write"
+	ScopeKeysRead         = "keys:read"
+	ScopeKeysWrite        = "keys:write"
+)
+
+// Scopes lists the scopes an admin API key can be granted.
+var Scopes = []string{
+	ScopeAll, ScopeAppsRead, ScopeAppsWrite, ScopeProvidersRead, ScopeProvidersWrite, ScopePoliciesRead,
+	ScopePoliciesWrite, ScopeAccessRead, ScopeAccessWrite, ScopeCredentialsWrite, ScopeKeysRead, ScopeKeysWrite,
+}
+
+// APIKey is an admin API key, only the hash of the key is stored.
+type APIKey struct {
+	KeyID     string     `json:"key_id"`
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	KeyHash   string     `json:"key_hash,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	Revoked   bool       `json:"revoked"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+type CreateAPIKeyRequest struct {
+	Name   string   `json:"name" validate:"required"`
+	Scopes []string `json:"scopes" validate:"required,min=1"`
+}
+
+// CreateAPIKeyResponse carries the new key, it is only shown once.
+type CreateAPIKeyResponse struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+// SigningKey is an Ed25519 key access tokens are signed with. A new key is published before it signs, from
+// ActivatesAt on. The active key has no RetiredAt, a retired key is still published until the tokens it signed
+// expired.
+type SigningKey struct {
+	KeyID       string     `json:"kid"`
+	Algorithm   string     `json:"alg"`
+	PublicKey   []byte     `json:"public_key"`
+	PrivateKey  []byte     `json:"private_key,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ActivatesAt time.Time  `json:"activates_at"`
+	RetiredAt   *time.Time `json:"retired_at,omitempty"`
+}
+
+// JWK is the public JSON Web Key of a signing key.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	Curve     string `json:"crv"`
+	X         string `json:"x"`
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+}
+
+// JWKS is the JSON Web Key Set relying services verify access tokens with.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
 }
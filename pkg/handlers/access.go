@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"authonomy/models"
+	"authonomy/pkg/utils"
+	"authonomy/services"
+	"authonomy/store"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/go-playground/validator"
+	"github.com/google/uuid"
+)
+
+const (
+	auditActionGrant  = "grant-access"
+	auditActionRevoke = "revoke-access"
+
+	// permission check modes of /verify-access
+	permissionModeAny = "any"
+	permissionModeAll = "all"
+)
+
+// defaultUserRole is the role of a user who has not been granted any access yet (hardcoded for the hackathon demo)
+var defaultUserRole = models.Role{
+	RoleName:    "user",
+	Permissions: []string{"view_content", "comment"},
+}
+
+// httpError is an error carrying the status code it should be answered with, and the error code of a
+// models.ErrorResponse when the client has to tell it apart from other errors with the same status
+type httpError struct {
+	status  int
+	code    string
+	message string
+}
+
+func (e *httpError) Error() string {
+	return e.message
+}
+
+// writeError responds with the status code of an httpError, or an internal server error otherwise. An httpError
+// with an error code is answered with a models.ErrorResponse.
+func writeError(w http.ResponseWriter, err error) {
+	var httpErr *httpError
+	if errors.As(err, &httpErr) && httpErr.code != "" {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.WriteHeader(httpErr.status)
+		json.NewEncoder(w).Encode(models.ErrorResponse{Error: httpErr.code, Message: httpErr.message})
+		return
+	}
+	if errors.As(err, &httpErr) {
+		http.Error(w, httpErr.message, httpErr.status)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+// changeAccess decodes an access grant request, applies it and writes the result.
+func (h *AuthHandler) changeAccess(w http.ResponseWriter, r *http.Request, action string) {
+	var validate = validator.New()
+	var accessReq models.AccessGrantRequest
+
+	err := json.NewDecoder(r.Body).Decode(&accessReq)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validate.Struct(accessReq); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	response, err := h.applyAccessChange(accessReq, action)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// applyAccessChange grants or revokes a role of a user, re-issues the policy credential when the roles changed
+// and records the change in the audit log. Repeating the same request is a no-op.
+func (h *AuthHandler) applyAccessChange(accessReq models.AccessGrantRequest, action string) (*models.AccessGrantResponse, error) {
+	if _, err := h.db.GetApp(accessReq.AppDID); err != nil {
+		return nil, &httpError{status: http.StatusNotFound, message: "app is invalid"}
+	}
+	policy, err := services.UserPolicy(h.db, accessReq.AppDID)
+	if err != nil {
+		return nil, &httpError{status: http.StatusBadRequest, message: "Failed to get application policy: " + err.Error()}
+	}
+	access, err := h.db.GetUserAccess(accessReq.AppDID, accessReq.UserDID)
+	if err != nil {
+		access = &models.UserAccess{AppDID: accessReq.AppDID, UserDID: accessReq.UserDID}
+	}
+
+	var response models.AccessGrantResponse
+	_, hasRole := utils.FindRole(access.Roles, accessReq.RoleName)
+	switch action {
+	case auditActionGrant:
+		appRoles, err := utils.RolesFromSubject(policy.CredentialSubject)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read application roles: %v", err)
+		}
+		role, ok := utils.FindRole(appRoles, accessReq.RoleName)
+		if !ok {
+			return nil, &httpError{status: http.StatusBadRequest, message: "role is not defined in the application policy: " + accessReq.RoleName}
+		}
+		if !hasRole {
+			access.Roles = append(access.Roles, role)
+			response.Changed = true
+		}
+	case auditActionRevoke:
+		if hasRole {
+			roles := make([]models.Role, 0, len(access.Roles))
+			for _, role := range access.Roles {
+				if role.RoleName != accessReq.RoleName {
+					roles = append(roles, role)
+				}
+			}
+			access.Roles = roles
+			response.Changed = true
+		}
+	}
+
+	if response.Changed {
+		response.PolicyCredential, response.RevokedCredentials, err = reissuePolicyCredential(h.ssiService, h.db, policy, access, action)
+		if err != nil {
+			return nil, fmt.Errorf("failed to re-issue policy credential: %v", err)
+		}
+	}
+	response.Access = *access
+
+	event := models.AuditEvent{
+		ID:                 uuid.New().String(),
+		AppDID:             accessReq.AppDID,
+		UserDID:            accessReq.UserDID,
+		Action:             action,
+		RoleName:           accessReq.RoleName,
+		Changed:            response.Changed,
+		RevokedCredentials: response.RevokedCredentials,
+		Reason:             accessReq.Reason,
+		Timestamp:          time.Now().UTC(),
+	}
+	if response.PolicyCredential != nil {
+		event.CredentialID = response.PolicyCredential.ID
+	}
+	if err := h.db.AddAuditEvent(event); err != nil {
+		return nil, fmt.Errorf("failed to save audit event: %v", err)
+	}
+	return &response, nil
+}
+
+// reissuePolicyCredential issues a policy credential carrying the current roles of the user against the
+// policy schema attached to the application, then revokes every policy credential the user held before.
+// No new credential is issued once the user has no roles left.
+func reissuePolicyCredential(ssiService *services.SsiClient, db store.Store, policy *models.ApplicationPolicyResponse, access *models.UserAccess, reason string) (*models.CredentialResponse, []string, error) {
+	var issued *models.CredentialResponse
+	if len(access.Roles) > 0 {
+		policyCredMap, err := models.StructToMap(models.RolesWrapper{Roles: access.Roles})
+		if err != nil {
+			return nil, nil, err
+		}
+		policyCredential, err := ssiService.IssueCredentialBySchemaID(access.AppDID, access.UserDID, policy.SchemaID, policyCredMap)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := recordCredential(db, policyCredential.ID, access.AppDID, access.UserDID, policy.SchemaID); err != nil {
+			return nil, nil, err
+		}
+		issued = &policyCredential
+	}
+
+	// credentials of the older versions of the policy are replaced as well
+	schemaIDs, err := services.PolicySchemaIDs(db, policy.SchemaID)
+	if err != nil {
+		return nil, nil, err
+	}
+	records, err := db.GetCredentialRecordsByApp(access.AppDID)
+	if err != nil {
+		return nil, nil, err
+	}
+	var revoked []string
+	for i := range records {
+		record := records[i]
+		if record.Revoked || record.SubjectDID != access.UserDID || !slices.Contains(schemaIDs, record.SchemaID) {
+			continue
+		}
+		if issued != nil && record.CredentialID == issued.ID {
+			continue
+		}
+		if err := revokeCredential(ssiService, db, &record, reason); err != nil {
+			return nil, nil, err
+		}
+		revoked = append(revoked, record.CredentialID)
+	}
+
+	access.PolicyCredentialID = ""
+	if issued != nil {
+		access.PolicyCredentialID = issued.ID
+	}
+	access.UpdatedAt = time.Now().UTC()
+	if err := db.SetUserAccess(*access); err != nil {
+		return nil, nil, err
+	}
+	return issued, revoked, nil
+}
@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"authonomy/models"
+	"authonomy/pkg/utils"
+	"authonomy/services"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/go-playground/validator"
+)
+
+// ListAccessRequests godoc
+// @Summary List access requests
+// @Description Lists the access requests made on an application, optionally filtered by status.
+// @Tags Permission Management
+// @Accept  json
+// @Produce  json
+// @Param x-api-key header string true "API Key"
+// @Param app_did query string true "Application DID"
+// @Param status query string false "pending, approved or denied"
+// @Success 200 {array} models.AccessRequest
+// @Failure 500 {string} string "Internal server error"
+// @Router /access-requests [get]
+func (h *AuthHandler) ListAccessRequests(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Only GET method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	queryParams := r.URL.Query()
+	requests, err := h.db.GetAccessRequestsByApp(queryParams.Get("app_did"))
+	if err != nil {
+		http.Error(w, "Failed to get access requests: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	status := queryParams.Get("status")
+	filtered := make([]models.AccessRequest, 0, len(requests))
+	for _, request := range requests {
+		if status == "" || request.Status == status {
+			filtered = append(filtered, request)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(filtered)
+}
+
+// ApproveAccessRequest godoc
+// @Summary Approve an access request
+// @Description Approves a pending access request and issues the policy credential of the user with the granted role. The role can be overridden, a request for a permission grants the first role of the application policy carrying it.
+// @Tags Permission Management
+// @Accept  json
+// @Produce  json
+// @Param x-api-key header string true "API Key"
+// @Param decision body models.AccessRequestDecision true "Decision"
+// @Success 200 {object} models.AccessRequest
+// @Failure 400 {string} string "Bad request"
+// @Failure 404 {string} string "Access request not found"
+// @Failure 409 {string} string "Access request already decided"
+// @Failure 500 {string} string "Internal server error"
+// @Router /access-requests/approve [post]
+func (h *AuthHandler) ApproveAccessRequest(w http.ResponseWriter, r *http.Request) {
+	h.decideAccessRequest(w, r, models.AccessRequestApproved)
+}
+
+// DenyAccessRequest godoc
+// @Summary Deny an access request
+// @Description Denies a pending access request.
+// @Tags Permission Management
+// @Accept  json
+// @Produce  json
+// @Param x-api-key header string true "API Key"
+// @Param decision body models.AccessRequestDecision true "Decision"
+// @Success 200 {object} models.AccessRequest
+// @Failure 400 {string} string "Bad request"
+// @Failure 404 {string} string "Access request not found"
+// @Failure 409 {string} string "Access request already decided"
+// @Failure 500 {string} string "Internal server error"
+// @Router /access-requests/deny [post]
+func (h *AuthHandler) DenyAccessRequest(w http.ResponseWriter, r *http.Request) {
+	h.decideAccessRequest(w, r, models.AccessRequestDenied)
+}
+
+// decideAccessRequest moves a pending access request to the given status. Repeating a decision is a no-op.
+func (h *AuthHandler) decideAccessRequest(w http.ResponseWriter, r *http.Request, status string) {
+	if r.Method != "POST" {
+		http.Error(w, "Only POST method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var validate = validator.New()
+	var decision models.AccessRequestDecision
+
+	err := json.NewDecoder(r.Body).Decode(&decision)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validate.Struct(decision); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	request, err := h.db.GetAccessRequest(decision.RequestID)
+	if err != nil {
+		http.Error(w, "access request not found", http.StatusNotFound)
+		return
+	}
+	if request.Status != models.AccessRequestPending {
+		if request.Status != status {
+			http.Error(w, "access request is already "+request.Status, http.StatusConflict)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(request)
+		return
+	}
+
+	if status == models.AccessRequestApproved {
+		roleName, err := h.requestedRole(request, decision.RoleName)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		response, err := h.applyAccessChange(models.AccessGrantRequest{
+			AppDID:   request.AppDID,
+			UserDID:  request.UserDID,
+			RoleName: roleName,
+			Reason:   "access request " + request.RequestID,
+		}, auditActionGrant)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		request.GrantedRole = roleName
+		request.CredentialID = response.Access.PolicyCredentialID
+	}
+	request.Status = status
+	request.DecisionReason = decision.Reason
+	request.UpdatedAt = time.Now().UTC()
+	if err := h.db.SetAccessRequest(*request); err != nil {
+		http.Error(w, "Failed to save access request: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(request)
+}
+
+// requestedRole resolves the role to grant for an access request: the owner override, the requested role,
+// or the first role of the application policy carrying the requested permission.
+func (h *AuthHandler) requestedRole(request *models.AccessRequest, override string) (string, error) {
+	if override != "" {
+		return override, nil
+	}
+	if request.RoleName != "" {
+		return request.RoleName, nil
+	}
+	policy, err := services.UserPolicy(h.db, request.AppDID)
+	if err != nil {
+		return "", &httpError{status: http.StatusBadRequest, message: "Failed to get application policy: " + err.Error()}
+	}
+	appRoles, err := utils.RolesFromSubject(policy.CredentialSubject)
+	if err != nil {
+		return "", err
+	}
+	for _, role := range appRoles {
+		if utils.IsPermissionDefined([]models.Role{role}, request.Permission) {
+			return role.RoleName, nil
+		}
+	}
+	return "", &httpError{status: http.StatusBadRequest, message: "no role of the application policy carries the permission: " + request.Permission}
+}
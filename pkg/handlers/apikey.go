@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"authonomy/models"
+	"authonomy/services"
+	"authonomy/store"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-playground/validator"
+)
+
+// APIKeyHandler handles the admin API keys
+type APIKeyHandler struct {
+	db store.Store
+}
+
+// NewAPIKeyHandler creates a new instance of APIKeyHandler
+func NewAPIKeyHandler(db store.Store) *APIKeyHandler {
+	return &APIKeyHandler{db: db}
+}
+
+// HandleAPIKeys routes the request to the appropriate function based on the HTTP method
+func (h *APIKeyHandler) HandleAPIKeys(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		h.listAPIKeys(w, r)
+	case "POST":
+		h.createAPIKey(w, r)
+	default:
+		http.Error(w, "Unsupported HTTP Method", http.StatusMethodNotAllowed)
+	}
+}
+
+// @Summary List admin API keys
+// @Description Lists the admin API keys, including the revoked ones. The keys themselves are never returned.
+// @Tags API Key Management
+// @Accept json
+// @Produce json
+// @Param x-api-key header string true "API Key"
+// @Success 200 {array} models.APIKey
+// @Failure 500 {object} string "Internal Server Error"
+// @Router /api-keys [get]
+func (h *APIKeyHandler) listAPIKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := services.ListAPIKeys(h.db)
+	if err != nil {
+		http.Error(w, "Failed to get API keys: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(keys)
+}
+
+// @Summary Create an admin API key
+// @Description Creates an admin API key with the given scopes. The key is only returned in this response. The calling key can only grant the scopes it holds, and "*" only when it holds "*".
+// @Tags API Key Management
+// @Accept json
+// @Produce json
+// @Param x-api-key header string true "API Key"
+// @Param key body models.CreateAPIKeyRequest true "API key to create"
+// @Success 200 {object} models.CreateAPIKeyResponse
+// @Failure 400 {object} string "Bad Request"
+// @Failure 401 {object} string "Unauthorized"
+// @Failure 403 {object} string "Scope Not Held By The API Key"
+// @Failure 500 {object} string "Internal Server Error"
+// @Router /api-keys [post]
+func (h *APIKeyHandler) createAPIKey(w http.ResponseWriter, r *http.Request) {
+	var validate = validator.New()
+	var keyReq models.CreateAPIKeyRequest
+
+	err := json.NewDecoder(r.Body).Decode(&keyReq)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validate.Struct(keyReq); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	caller := requestAPIKey(r)
+	if caller == nil {
+		http.Error(w, "Unauthorized: Invalid API key", http.StatusUnauthorized)
+		return
+	}
+	key, err := services.GrantAPIKey(h.db, caller, keyReq.Name, keyReq.Scopes)
+	if errors.Is(err, services.ErrScopeNotHeld) {
+		http.Error(w, "Forbidden: "+err.Error(), http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to create API key: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(key)
+}
+
+// RevokeAPIKeyHandler godoc
+// @Summary Revoke an admin API key
+// @Description Revokes an admin API key, it is rejected from then on.
+// @Tags API Key Management
+// @Accept json
+// @Produce json
+// @Param x-api-key header string true "API Key"
+// @Param key_id query string true "API key ID"
+// @Success 200 {object} models.APIKey
+// @Failure 404 {object} string "API Key Not Found"
+// @Failure 500 {object} string "Internal Server Error"
+// @Router /api-keys/revoke [post]
+func (h *APIKeyHandler) RevokeAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Only POST method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	key, err := services.RevokeAPIKey(h.db, r.URL.Query().Get("key_id"))
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "API key not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to revoke API key: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(key)
+}
@@ -2,24 +2,33 @@ package handlers
 
 import (
 	"authonomy/models"
+	"authonomy/pkg/utils"
 	"authonomy/services"
 	"authonomy/store"
 	"encoding/json"
 	"net/http"
+	"time"
 
 	"github.com/go-playground/validator"
 	"github.com/google/uuid"
 )
 
+// defaultSecretGracePeriod is used when no grace period is configured
+const defaultSecretGracePeriod = 24 * time.Hour
+
 // AppHandler handles application-related requests
 type AppHandler struct {
-	ssiService *services.SsiClient
-	db         *store.Store
+	ssiService        *services.SsiClient
+	db                store.Store
+	secretGracePeriod time.Duration
 }
 
-// NewAppHandler creates a new instance of AppHandler
-func NewAppHandler(ssiService *services.SsiClient, db *store.Store) *AppHandler {
-	return &AppHandler{ssiService: ssiService, db: db}
+// NewAppHandler creates a new instance of AppHandler, a rotated app secret stays valid for secretGracePeriod
+func NewAppHandler(ssiService *services.SsiClient, db store.Store, secretGracePeriod time.Duration) *AppHandler {
+	if secretGracePeriod <= 0 {
+		secretGracePeriod = defaultSecretGracePeriod
+	}
+	return &AppHandler{ssiService: ssiService, db: db, secretGracePeriod: secretGracePeriod}
 }
 
 // HandleApplications routes the request to the appropriate function based on the HTTP method
@@ -49,13 +58,16 @@ func (h *AppHandler) getApplications(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Failed to get applications: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	for i := range apps {
+		apps[i] = withoutSecrets(apps[i])
+	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(apps)
 }
 
 // createApplication handles POST requests to create a new application
 // @Summary Create a new application
-// @Description Create a new application with the given details
+// @Description Create a new application with the given details. The app secret is only returned in this response.
 // @Tags Application Management
 // @Accept json
 // @Produce json
@@ -83,18 +95,278 @@ func (h *AppHandler) createApplication(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Failed to create DID: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
-	response := models.ApplicationResponse{
-		AppDID:     did,
-		AppName:    appReq.AppName,
-		AppDetails: appReq.AppDetails,
-		AppSceret:  uuid.New().String(),
+	secret := uuid.New().String()
+	secretHash, err := utils.HashSecret(secret)
+	if err != nil {
+		http.Error(w, "Failed to hash the app secret: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	app := models.ApplicationResponse{
+		AppDID:            did,
+		AppName:           appReq.AppName,
+		AppDetails:        appReq.AppDetails,
+		Status:            models.AppStatusActive,
+		SecretHash:        secretHash,
+		AccessTokenFormat: appReq.AccessTokenFormat,
+		AccessTokenTTL:    appReq.AccessTokenTTL,
+		RefreshTokenTTL:   appReq.RefreshTokenTTL,
 	}
-	err = h.db.SetApp(response)
+	err = h.db.SetApp(app)
 	if err != nil {
 		http.Error(w, "Failed to save application: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	response := withoutSecrets(app)
+	response.AppSceret = secret
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
+
+// HandleApplication routes the request on a single application to the appropriate function based on the HTTP method
+func (h *AppHandler) HandleApplication(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		h.getApplication(w, r)
+	case "PUT":
+		h.updateApplication(w, r)
+	case "DELETE":
+		h.deleteApplication(w, r)
+	default:
+		http.Error(w, "Unsupported HTTP Method", http.StatusMethodNotAllowed)
+	}
+}
+
+// @Summary Get an application
+// @Description Retrieves the application with the given DID
+// @Tags Application Management
+// @Accept json
+// @Produce json
+// @Param app_did query string true "Application DID"
+// @Param x-api-key header string true "API Key"
+// @Success 200 {object} models.ApplicationResponse
+// @Failure 404 {object} string "Application Not Found"
+// @Router /application [get]
+func (h *AppHandler) getApplication(w http.ResponseWriter, r *http.Request) {
+	app, err := h.db.GetApp(r.URL.Query().Get("app_did"))
+	if err != nil {
+		http.Error(w, "Application not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(withoutSecrets(*app))
+}
+
+// @Summary Update an application
+// @Description Replaces the name, details and token settings of the application with the given DID
+// @Tags Application Management
+// @Accept json
+// @Produce json
+// @Param app_did query string true "Application DID"
+// @Param application body models.ApplicationRequest true "Application details"
+// @Param x-api-key header string true "API Key"
+// @Success 200 {object} models.ApplicationResponse
+// @Failure 400 {object} string "Bad Request"
+// @Failure 404 {object} string "Application Not Found"
+// @Failure 500 {object} string "Internal Server Error"
+// @Router /application [put]
+func (h *AppHandler) updateApplication(w http.ResponseWriter, r *http.Request) {
+	var validate = validator.New()
+	var appReq models.ApplicationRequest
+
+	err := json.NewDecoder(r.Body).Decode(&appReq)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validate.Struct(appReq); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	app, err := h.db.GetApp(r.URL.Query().Get("app_did"))
+	if err != nil {
+		http.Error(w, "Application not found", http.StatusNotFound)
+		return
+	}
+	app.AppName = appReq.AppName
+	app.AppDetails = appReq.AppDetails
+	app.AccessTokenFormat = appReq.AccessTokenFormat
+	app.AccessTokenTTL = appReq.AccessTokenTTL
+	app.RefreshTokenTTL = appReq.RefreshTokenTTL
+	if err := h.db.SetApp(*app); err != nil {
+		http.Error(w, "Failed to save application: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(withoutSecrets(*app))
+}
+
+// @Summary Delete an application
+// @Description Revokes the outstanding credentials issued by the application, then deletes it with its auth provider config, issued policy, user access and access requests. The credential records and the audit log are kept.
+// @Tags Application Management
+// @Accept json
+// @Produce json
+// @Param app_did query string true "Application DID"
+// @Param x-api-key header string true "API Key"
+// @Success 200 {object} models.DeleteApplicationResponse
+// @Failure 404 {object} string "Application Not Found"
+// @Failure 500 {object} string "Internal Server Error"
+// @Router /application [delete]
+func (h *AppHandler) deleteApplication(w http.ResponseWriter, r *http.Request) {
+	app, err := h.db.GetApp(r.URL.Query().Get("app_did"))
+	if err != nil {
+		http.Error(w, "Application not found", http.StatusNotFound)
+		return
+	}
+	records, err := h.db.GetCredentialRecordsByApp(app.AppDID)
+	if err != nil {
+		http.Error(w, "Failed to get the issued credentials: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// the app is only deleted once every credential is revoked, a failed delete can be retried
+	revoked := []string{}
+	for i := range records {
+		if records[i].Revoked {
+			continue
+		}
+		if err := revokeCredential(h.ssiService, h.db, &records[i], "application deleted"); err != nil {
+			http.Error(w, "Failed to revoke credential "+records[i].CredentialID+": "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		revoked = append(revoked, records[i].CredentialID)
+	}
+	if err := h.db.DeleteApp(app.AppDID); err != nil {
+		http.Error(w, "Failed to delete application: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.DeleteApplicationResponse{AppDID: app.AppDID, RevokedCredentials: revoked})
+}
+
+// SuspendApplicationHandler godoc
+// @Summary Suspend an application
+// @Description Suspends the application, from then on it can not sign users in, issue access tokens or verify access until it is reactivated.
+// @Tags Application Management
+// @Accept json
+// @Produce json
+// @Param app_did query string true "Application DID"
+// @Param x-api-key header string true "API Key"
+// @Success 200 {object} models.ApplicationResponse
+// @Failure 404 {object} string "Application Not Found"
+// @Failure 500 {object} string "Internal Server Error"
+// @Router /applications/suspend [post]
+func (h *AppHandler) SuspendApplicationHandler(w http.ResponseWriter, r *http.Request) {
+	h.setApplicationStatus(w, r, models.AppStatusSuspended)
+}
+
+// ReactivateApplicationHandler godoc
+// @Summary Reactivate an application
+// @Description Reactivates a suspended application.
+// @Tags Application Management
+// @Accept json
+// @Produce json
+// @Param app_did query string true "Application DID"
+// @Param x-api-key header string true "API Key"
+// @Success 200 {object} models.ApplicationResponse
+// @Failure 404 {object} string "Application Not Found"
+// @Failure 500 {object} string "Internal Server Error"
+// @Router /applications/reactivate [post]
+func (h *AppHandler) ReactivateApplicationHandler(w http.ResponseWriter, r *http.Request) {
+	h.setApplicationStatus(w, r, models.AppStatusActive)
+}
+
+// setApplicationStatus suspends or reactivates the application
+func (h *AppHandler) setApplicationStatus(w http.ResponseWriter, r *http.Request, status string) {
+	if r.Method != "POST" {
+		http.Error(w, "Only POST method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	app, err := h.db.GetApp(r.URL.Query().Get("app_did"))
+	if err != nil {
+		http.Error(w, "Application not found", http.StatusNotFound)
+		return
+	}
+	if app.Status != status {
+		app.Status = status
+		app.SuspendedAt = nil
+		if status == models.AppStatusSuspended {
+			now := time.Now().UTC()
+			app.SuspendedAt = &now
+		}
+		if err := h.db.SetApp(*app); err != nil {
+			http.Error(w, "Failed to save application: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(withoutSecrets(*app))
+}
+
+// RotateSecretHandler godoc
+// @Summary Rotate the app secret
+// @Description Issues a new app secret, the previous one stays valid for the configured grace period. The new secret is only returned in this response.
+// @Tags Application Management
+// @Accept json
+// @Produce json
+// @Param app_did query string true "Application DID"
+// @Param x-api-key header string true "API Key"
+// @Success 200 {object} models.RotateSecretResponse
+// @Failure 400 {object} string "Bad Request"
+// @Failure 404 {object} string "Application Not Found"
+// @Failure 500 {object} string "Internal Server Error"
+// @Router /applications/rotate-secret [post]
+func (h *AppHandler) RotateSecretHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Only POST method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	appDid := r.URL.Query().Get("app_did")
+	if appDid == "" {
+		http.Error(w, "app_did is required", http.StatusBadRequest)
+		return
+	}
+	app, err := h.db.GetApp(appDid)
+	if err != nil {
+		http.Error(w, "Application not found", http.StatusNotFound)
+		return
+	}
+	secret := uuid.New().String()
+	secretHash, err := utils.HashSecret(secret)
+	if err != nil {
+		http.Error(w, "Failed to hash the app secret: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	expiresAt := time.Now().UTC().Add(h.secretGracePeriod)
+	app.PreviousSecretHash = app.SecretHash
+	app.SecretHash = secretHash
+	app.PreviousSecretExpiresAt = &expiresAt
+	if err := h.db.SetApp(*app); err != nil {
+		http.Error(w, "Failed to save application: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.RotateSecretResponse{
+		AppDID:                  app.AppDID,
+		AppSecret:               secret,
+		PreviousSecretExpiresAt: expiresAt,
+	})
+}
+
+// validAppSecret checks the secret against the current secret of the app and, until the grace
+// period ends, the previous one
+func validAppSecret(app *models.ApplicationResponse, secret string) bool {
+	if utils.CheckSecret(app.SecretHash, secret) {
+		return true
+	}
+	return app.PreviousSecretExpiresAt != nil && time.Now().Before(*app.PreviousSecretExpiresAt) &&
+		utils.CheckSecret(app.PreviousSecretHash, secret)
+}
+
+// withoutSecrets removes the secret and its hashes from the app before it is returned
+func withoutSecrets(app models.ApplicationResponse) models.ApplicationResponse {
+	app.AppSceret = ""
+	app.SecretHash = ""
+	app.PreviousSecretHash = ""
+	return app
+}
@@ -7,22 +7,28 @@ import (
 	"authonomy/services"
 	"authonomy/store"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"net/http"
+	"net/url"
 	"strings"
+	"time"
 
+	"github.com/TBD54566975/ssi-sdk/credential"
 	"github.com/go-playground/validator"
+	"github.com/google/uuid"
 )
 
 // AuthHandler handles auth-related requests
 type AuthHandler struct {
 	ssiService *services.SsiClient
-	db         *store.Store
+	db         store.Store
+	revocation *services.RevocationChecker
+	issuer     string
 }
 
-// NewAuthHandler creates a new instance of AuthHandler
-func NewAuthHandler(ssiService *services.SsiClient, db *store.Store) *AuthHandler {
-	return &AuthHandler{ssiService: ssiService, db: db}
+// NewAuthHandler creates a new instance of AuthHandler, the issuer is the URL the service is reached at
+func NewAuthHandler(ssiService *services.SsiClient, db store.Store, revocation *services.RevocationChecker, issuer string) *AuthHandler {
+	return &AuthHandler{ssiService: ssiService, db: db, revocation: revocation, issuer: issuer}
 }
 
 // SignUpHandler godoc
@@ -33,7 +39,7 @@ func NewAuthHandler(ssiService *services.SsiClient, db *store.Store) *AuthHandle
 // @Produce  json
 // @Param app_did query string true "Application DID"
 // @Param app_secret query string true "Application secret"
-// @Success 200 {object} map[string]string "Redirect URL for sign-up"
+// @Success 200 {object} models.SignUpResponse "Login URLs of the linked providers"
 // @Failure 400 {string} string "Bad request"
 // @Failure 500 {string} string "Internal server error"
 // @Router /signup [get]
@@ -47,42 +53,78 @@ func (h *AuthHandler) SignUpHandler(w http.ResponseWriter, r *http.Request) {
 	queryParams := r.URL.Query()
 	appDid := queryParams.Get("app_did")
 	appSecret := queryParams.Get("app_secret")
-	fmt.Println("appDid", appDid)
-	fmt.Println("queryParams", queryParams)
 	appDetails, err := h.db.GetApp(appDid)
 	if err != nil {
 		http.Error(w, "app is invalid", http.StatusInternalServerError)
 		return
 	}
-	if appDetails.AppSceret != appSecret {
+	if !validAppSecret(appDetails, appSecret) {
 		http.Error(w, "app secret is invalid", http.StatusInternalServerError)
 		return
 	}
-	auth, err := h.db.GetAuthProvider(appDid)
-	if err != nil {
+	if appDetails.Status == models.AppStatusSuspended {
+		http.Error(w, "app is suspended", http.StatusForbidden)
+		return
+	}
+	auths, err := h.db.GetAuthProvidersByApp(appDid)
+	if err != nil || len(auths) == 0 {
 		http.Error(w, "app authentication is not configured yet", http.StatusInternalServerError)
 		return
 	}
-	// For demonstration, let's just send back these parameters
+	// one sign-in attempt covers every provider, it is consumed by the one the user picks
+	authReq, err := providers.NewAuthRequest()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	response := models.SignUpResponse{AppDID: appDid, LoginURLs: []models.LoginURL{}}
+	var linked []string
+	for _, auth := range auths {
+		provider, err := providers.Get(auth.Provider.ProviderName)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		loginURL, err := provider.LoginURL(auth.Config, authReq)
+		if err != nil {
+			http.Error(w, "Failed to build login url: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		response.LoginURLs = append(response.LoginURLs, models.LoginURL{Provider: provider.Name(), URL: loginURL})
+		linked = append(linked, provider.Name())
+	}
+	response.RedirectURL = response.LoginURLs[0].URL
+	// the state is bound to this browser, the callback only accepts it together with the cookie
+	authSession := models.AuthSession{
+		State:        authReq.State,
+		AppDID:       appDid,
+		Providers:    linked,
+		Nonce:        authReq.Nonce,
+		CodeVerifier: authReq.CodeVerifier,
+		ExpiresAt:    time.Now().UTC().Add(authSessionTTL),
+	}
+	if err := h.db.SetAuthSession(authSession); err != nil {
+		http.Error(w, "Failed to save the sign in attempt: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	setCookie(w, r, stateCookie, authSession.State, authSession.ExpiresAt)
 	w.Header().Set("Content-Type", "application/json")
-	response := map[string]string{
-		"app_did":      appDid,
-		"redirect_url": providers.GetLoginUrl(auth.Config.ClientID, auth.Config.RedirectURL),
-	}
 	json.NewEncoder(w).Encode(response)
 }
 
 // GetAccessToken godoc
 // @Summary Sign in or get access token to an application
-// @Description Handles the sign-in process using application DID, credential JWT.
+// @Description Handles the sign-in process using application DID, credential JWT. The access token is signed with the active Ed25519 key, whose kid is in the token header and whose public key is published at /.well-known/jwks.json. An app set to the opaque format gets a short reference token instead, which is resolved server-side and can be checked at /introspect. The access token lives for the access token TTL of the app, 15 minutes by default, and comes with a refresh token for /refresh-token. A signed token names the service as its issuer, the app as its audience and the user DID as its subject. With a DPoP proof signed by a key of the user DID the tokens are bound to that key (cnf), every request made with them then needs a proof signed with the same key.
 // @Tags User Access Management
 // @Accept json
 // @Produce json
 // @Param app_did query string true "Application DID"
 // @Param app_secret query string true "Application secret"
+// @Param DPoP header string false "Proof of possession of a key of the user DID, binds the tokens to the key, each proof is accepted once"
 // @Param application body models.IssueOAuthCredential true "Application to create"
 // @Success 200 {object} models.GetAccessTokenResponse "Access Token"
 // @Failure 400 {string} string "Bad request"
+// @Failure 403 {object} models.ErrorResponse "Credential revoked (credential_revoked), issued to another subject (subject_mismatch) or app suspended (app_suspended)"
 // @Failure 500 {string} string "Internal server error"
 // @Router /get-access-token [post]
 func (h *AuthHandler) GetAccessToken(w http.ResponseWriter, r *http.Request) {
@@ -101,10 +143,14 @@ func (h *AuthHandler) GetAccessToken(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "app is invalid", http.StatusInternalServerError)
 		return
 	}
-	if appDetails.AppSceret != appSecret {
+	if !validAppSecret(appDetails, appSecret) {
 		http.Error(w, "app secret is invalid", http.StatusInternalServerError)
 		return
 	}
+	if appDetails.Status == models.AppStatusSuspended {
+		writeError(w, errAppSuspended)
+		return
+	}
 
 	var validate = validator.New()
 	var appReq models.IssueOAuthCredential
@@ -119,7 +165,7 @@ func (h *AuthHandler) GetAccessToken(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, _, oauthCred, err := utils.ParseVerifiableCredentialFromJWT(appReq.OAuthCredential.(string))
+	_, _, oauthCred, err := utils.VerifyVerifiableCredentialFromJWT(appReq.OAuthCredential.(string))
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
@@ -129,7 +175,7 @@ func (h *AuthHandler) GetAccessToken(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, _, policyCred, err := utils.ParseVerifiableCredentialFromJWT(appReq.PolicyCredential.(string))
+	_, _, policyCred, err := utils.VerifyVerifiableCredentialFromJWT(appReq.PolicyCredential.(string))
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
@@ -138,96 +184,331 @@ func (h *AuthHandler) GetAccessToken(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "incorrect policy cred", http.StatusBadRequest)
 		return
 	}
+	// the roles of the policy credential only apply to the user the oauth credential was issued to
+	if policyCred.CredentialSubject.GetID() != oauthCred.CredentialSubject.GetID() {
+		writeError(w, &httpError{status: http.StatusForbidden, code: models.ErrorSubjectMismatch, message: "Forbidden: policy credential was issued to another subject"})
+		return
+	}
+	if !h.checkRevocation(w, oauthCred, policyCred) {
+		return
+	}
+
+	grant := models.TokenGrant{
+		SubjectDID:       oauthCred.CredentialSubject.GetID(),
+		OAuthCredential:  appReq.OAuthCredential.(string),
+		PolicyCredential: appReq.PolicyCredential.(string),
+	}
+	// a proof of possession binds the tokens to the key of the user DID it is signed with
+	if proof := r.Header.Get("DPoP"); proof != "" {
+		kid, err := services.VerifyHolderProof(h.db, proof, grant.SubjectDID, r.Method, requestURL(h.issuer, r), "")
+		if err != nil {
+			http.Error(w, "invalid DPoP proof: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		grant.Confirmation = &models.Confirmation{KeyID: kid}
+	}
+	response, err := services.IssueTokens(h.db, h.issuer, appDetails, grant)
+	if err != nil {
+		http.Error(w, "Failed to issue the access token: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(response)
+}
+
+// RefreshAccessToken godoc
+// @Summary Refresh an access token
+// @Description Exchanges a refresh token for a new access token and a new refresh token, the presented refresh token is used up. Presenting a used refresh token again revokes every refresh token rotated out of the same sign in. The credentials are verified again, once one is revoked the user has to sign in again. A refresh token bound to a key of the user DID needs a DPoP proof signed with that key.
+// @Tags User Access Management
+// @Accept json
+// @Produce json
+// @Param app_did query string true "Application DID"
+// @Param app_secret query string true "Application secret"
+// @Param DPoP header string false "Proof of possession, required for bound tokens, each proof is accepted once"
+// @Param request body models.RefreshTokenRequest true "Refresh token"
+// @Success 200 {object} models.GetAccessTokenResponse "Access Token"
+// @Failure 400 {string} string "Bad request"
+// @Failure 401 {string} string "Invalid or reused refresh token"
+// @Failure 403 {object} models.ErrorResponse "Credential revoked (credential_revoked), issued to another subject (subject_mismatch) or app suspended (app_suspended)"
+// @Failure 500 {string} string "Internal server error"
+// @Router /refresh-token [post]
+func (h *AuthHandler) RefreshAccessToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Only POST method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Extract query parameters
+	queryParams := r.URL.Query()
+	appDid := queryParams.Get("app_did")
+	appSecret := queryParams.Get("app_secret")
+
+	appDetails, err := h.db.GetApp(appDid)
+	if err != nil {
+		http.Error(w, "app is invalid", http.StatusInternalServerError)
+		return
+	}
+	if !validAppSecret(appDetails, appSecret) {
+		http.Error(w, "app secret is invalid", http.StatusInternalServerError)
+		return
+	}
+	if appDetails.Status == models.AppStatusSuspended {
+		writeError(w, errAppSuspended)
+		return
+	}
 
-	accessToken, err := utils.CreateAccessToken(appDid, models.IssueOAuthCredential{
-		OAuthCredential:  appReq.OAuthCredential,
-		PolicyCredential: appReq.PolicyCredential,
-	})
+	var validate = validator.New()
+	var refreshReq models.RefreshTokenRequest
+
+	err = json.NewDecoder(r.Body).Decode(&refreshReq)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	// For demonstration, let's just return a success message
+	if err := validate.Struct(refreshReq); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	refreshToken, err := services.GetRefreshToken(h.db, appDetails, refreshReq.RefreshToken)
+	if errors.Is(err, services.ErrInvalidRefreshToken) || errors.Is(err, services.ErrRefreshTokenReused) {
+		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to get the refresh token: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := h.checkHolderProof(r, refreshToken.TokenGrant, ""); err != nil {
+		writeError(w, err)
+		return
+	}
+	// the token is only used up once its credentials passed, a failed status check can be retried
+	if _, _, err := h.verifyCredentials(appDetails, refreshToken.OAuthCredential, refreshToken.PolicyCredential); err != nil {
+		writeError(w, err)
+		return
+	}
+	response, err := services.RotateRefreshToken(h.db, h.issuer, appDetails, refreshToken)
+	if errors.Is(err, services.ErrInvalidRefreshToken) || errors.Is(err, services.ErrRefreshTokenReused) {
+		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to issue the access token: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(models.GetAccessTokenResponse{AccessToken: accessToken})
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(response)
 }
 
 // RequestAccess godoc
 // @Summary Request access for a user
-// @Description Initiates a request for user access.
+// @Description POST asks for a role or a permission on the application and creates a pending access request for the owner to approve or deny. GET polls the status of a request.
 // @Tags User Access Management
 // @Accept  json
 // @Produce  json
 // @Param app_did query string true "Application DID"
 // @Param app_secret query string true "Application secret"
-// @Success 200 {string} string "implementation pending"
-// @Failure 405 {string} string "Only POST method is allowed"
+// @Param request_id query string false "Access request ID (GET only)"
+// @Param request body models.RequestAccessRequest false "Access request (POST only)"
+// @Success 200 {object} models.AccessRequest
+// @Failure 400 {string} string "Bad request"
+// @Failure 404 {string} string "Access request not found"
+// @Failure 500 {string} string "Internal server error"
 // @Router /request-access [post]
+// @Router /request-access [get]
 func (h *AuthHandler) RequestAccess(w http.ResponseWriter, r *http.Request) {
-	// request id based tracking and issurance
-	// need to better p2p storage?
-	// DIDComm exchange (data persistance?)
-	if r.Method != "POST" {
-		http.Error(w, "Only POST method is allowed", http.StatusMethodNotAllowed)
+	if r.Method != "POST" && r.Method != "GET" {
+		http.Error(w, "Only GET and POST methods are allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Extract query parameters
+	queryParams := r.URL.Query()
+	appDid := queryParams.Get("app_did")
+	appSecret := queryParams.Get("app_secret")
+
+	appDetails, err := h.db.GetApp(appDid)
+	if err != nil {
+		http.Error(w, "app is invalid", http.StatusInternalServerError)
+		return
+	}
+	if !validAppSecret(appDetails, appSecret) {
+		http.Error(w, "app secret is invalid", http.StatusInternalServerError)
+		return
+	}
+	if appDetails.Status == models.AppStatusSuspended {
+		http.Error(w, "app is suspended", http.StatusForbidden)
+		return
+	}
+
+	if r.Method == "GET" {
+		request, err := h.db.GetAccessRequest(queryParams.Get("request_id"))
+		if err != nil || request.AppDID != appDid {
+			http.Error(w, "access request not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(request)
+		return
+	}
+
+	var validate = validator.New()
+	var accessReq models.RequestAccessRequest
+
+	err = json.NewDecoder(r.Body).Decode(&accessReq)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validate.Struct(accessReq); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	policy, err := services.UserPolicy(h.db, appDid)
+	if err != nil {
+		http.Error(w, "Failed to get application policy: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	appRoles, err := utils.RolesFromSubject(policy.CredentialSubject)
+	if err != nil {
+		http.Error(w, "Failed to read application roles: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if accessReq.RoleName != "" {
+		if _, ok := utils.FindRole(appRoles, accessReq.RoleName); !ok {
+			http.Error(w, "role is not defined in the application policy: "+accessReq.RoleName, http.StatusBadRequest)
+			return
+		}
+	} else if !utils.IsPermissionDefined(appRoles, accessReq.Permission) {
+		http.Error(w, "permission is not defined in the application policy: "+accessReq.Permission, http.StatusBadRequest)
+		return
+	}
+
+	requests, err := h.db.GetAccessRequestsByApp(appDid)
+	if err != nil {
+		http.Error(w, "Failed to get access requests: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// the same pending request is only queued once
+	for _, request := range requests {
+		if request.Status == models.AccessRequestPending && request.UserDID == accessReq.UserDID &&
+			request.RoleName == accessReq.RoleName && request.Permission == accessReq.Permission {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(request)
+			return
+		}
+	}
+
+	now := time.Now().UTC()
+	request := models.AccessRequest{
+		RequestID:  uuid.New().String(),
+		AppDID:     appDid,
+		UserDID:    accessReq.UserDID,
+		RoleName:   accessReq.RoleName,
+		Permission: accessReq.Permission,
+		Reason:     accessReq.Reason,
+		Status:     models.AccessRequestPending,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if err := h.db.SetAccessRequest(request); err != nil {
+		http.Error(w, "Failed to save access request: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode("implementation pending")
+	json.NewEncoder(w).Encode(request)
 }
 
 // GrandAccess godoc
 // @Summary Grant access to a user
-// @Description Grants access based on a valid request.
+// @Description Grants a role defined in the application policy to a user. The policy credential of the user is re-issued with the new roles and the previous one is revoked. Granting a role the user already holds is a no-op.
 // @Tags Permission Management
 // @Accept  json
 // @Produce  json
-// @Param app_did query string true "Application DID"
-// @Param app_secret query string true "Application secret"
-// @Success 200 {string} string "implementation pending"
+// @Param x-api-key header string true "API Key"
+// @Param grant body models.AccessGrantRequest true "Access grant request"
+// @Success 200 {object} models.AccessGrantResponse
+// @Failure 400 {string} string "Bad request"
+// @Failure 404 {string} string "Application not found"
 // @Failure 405 {string} string "Only PUT method is allowed"
+// @Failure 500 {string} string "Internal server error"
 // @Router /grant-access [put]
 func (h *AuthHandler) GrandAccess(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "PUT" {
 		http.Error(w, "Only PUT method is allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode("implementation pending")
+	h.changeAccess(w, r, auditActionGrant)
 }
 
 // RevokeAccess godoc
 // @Summary Revoke access of a user
-// @Description Revokes the access of a user.
+// @Description Removes a role from a user. The policy credential of the user is re-issued with the remaining roles and the previous one is revoked. Revoking a role the user does not hold is a no-op.
 // @Tags Permission Management
 // @Accept  json
 // @Produce  json
-// @Param app_did query string true "Application DID"
-// @Param app_secret query string true "Application secret"
-// @Success 200 {string} string "implementation pending"
+// @Param x-api-key header string true "API Key"
+// @Param revoke body models.AccessGrantRequest true "Access revoke request"
+// @Success 200 {object} models.AccessGrantResponse
+// @Failure 400 {string} string "Bad request"
+// @Failure 404 {string} string "Application not found"
 // @Failure 405 {string} string "Only PUT method is allowed"
+// @Failure 500 {string} string "Internal server error"
 // @Router /revoke-access [put]
 func (h *AuthHandler) RevokeAccess(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "PUT" {
 		http.Error(w, "Only PUT method is allowed", http.StatusMethodNotAllowed)
 		return
 	}
+	h.changeAccess(w, r, auditActionRevoke)
+}
+
+// GetAccessAudit godoc
+// @Summary Get the access audit log
+// @Description Lists every grant and revoke of access on the application in chronological order.
+// @Tags Permission Management
+// @Accept  json
+// @Produce  json
+// @Param x-api-key header string true "API Key"
+// @Param app_did query string true "Application DID"
+// @Success 200 {array} models.AuditEvent
+// @Failure 500 {string} string "Internal server error"
+// @Router /access-audit [get]
+func (h *AuthHandler) GetAccessAudit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Only GET method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	events, err := h.db.GetAuditEvents(r.URL.Query().Get("app_did"))
+	if err != nil {
+		http.Error(w, "Failed to get audit log: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode("implementation pending")
+	json.NewEncoder(w).Encode(events)
 }
 
 // VerifyAccess godoc
 // @Summary Verify access to a resource
-// @Description Verifies if a user has access to a specific resource based on their role.
+// @Description Verifies if a user has access to a specific resource based on their role and the permissions of all their roles, including the roles they inherit. When both a role and permissions are given, both must pass.
 // @Tags User Access Management
 // @Accept json
 // @Produce json
 // @Param Authorization header string true "Authorization token" default(Bearer YOUR_ACCESS_TOKEN)
+// @Param DPoP header string false "Proof of possession, required for bound tokens, each proof is accepted once"
 // @Param app_did query string true "Application DID"
 // @Param app_secret query string true "Application Secret"
 // @Param attribute query string false "e.g.; Role to check access for"
+// @Param permission query []string false "Permissions to check, repeated or comma separated" collectionFormat(multi)
+// @Param mode query string false "any (default) passes with one of the permissions, all requires every permission" Enums(any, all)
 // @Success 200 {string} string "success"
 // @Failure 400 {string} string "Bad request"
 // @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Credential revoked (credential_revoked), issued to another subject (subject_mismatch) or app suspended (app_suspended)"
 // @Failure 500 {string} string "Internal server error"
 // @Router /verify-access [get]
 func (h *AuthHandler) VerifyAccess(w http.ResponseWriter, r *http.Request) {
@@ -236,64 +517,47 @@ func (h *AuthHandler) VerifyAccess(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Extract query parameters
+	// TODO:: hardcoded, should be based on policy schema ID, and application credential existence and user ownership
+	// the can be VP too
 	queryParams := r.URL.Query()
-	appDid := queryParams.Get("app_did")
-	appSecret := queryParams.Get("app_secret")
-
-	appDetails, err := h.db.GetApp(appDid)
-	if err != nil {
-		http.Error(w, "app is invalid", http.StatusInternalServerError)
-		return
+	role := queryParams.Get("attribute")
+	var permissions []string
+	for _, param := range queryParams["permission"] {
+		for _, permission := range strings.Split(param, ",") {
+			if permission = strings.TrimSpace(permission); permission != "" {
+				permissions = append(permissions, permission)
+			}
+		}
 	}
-	if appDetails.AppSceret != appSecret {
-		http.Error(w, "app secret is invalid", http.StatusInternalServerError)
-		return
+	mode := queryParams.Get("mode")
+	if mode == "" {
+		mode = permissionModeAny
 	}
-
-	authHeader := r.Header.Get("Authorization")
-	if authHeader == "" {
-		http.Error(w, "Unauthorized: No Authorization header provided", http.StatusUnauthorized)
+	if mode != permissionModeAny && mode != permissionModeAll {
+		http.Error(w, "mode must be any or all", http.StatusBadRequest)
 		return
 	}
-	// TODO:: hardcoded, should be based on policy schema ID, and application credential existence and user ownership
-	// the can be VP too
-	role := queryParams.Get("attribute") // warning: will work with role only
-	fmt.Println("role", role)
-	// Split the header to get the token part
-	headerParts := strings.Split(authHeader, " ")
-	if len(headerParts) != 2 || headerParts[0] != "Bearer" {
-		http.Error(w, "Unauthorized: Invalid Authorization header format", http.StatusUnauthorized)
+	if role == "" && len(permissions) == 0 {
+		http.Error(w, "attribute or permission is required", http.StatusBadRequest)
 		return
 	}
-	// headerParts[1] contains the actual token
-	token := headerParts[1]
 
-	claims, err := utils.ValidateAccessToken(token)
-	if err != nil {
-		http.Error(w, "Unauthorized: Invalid access token", http.StatusUnauthorized)
+	appDetails, _, policyCred, ok := h.authenticate(w, r)
+	if !ok {
 		return
 	}
-	// TODO:: revokable check
-	_, _, oauthCred, err := utils.ParseVerifiableCredentialFromJWT(claims.CredentialJWTs.OAuthCredential.(string))
+	roles, err := h.effectiveRoles(appDetails.AppDID, policyCred)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		http.Error(w, "Failed to read roles: "+err.Error(), http.StatusBadRequest)
 		return
 	}
-	if oauthCred.Issuer != appDetails.AppDID {
-		http.Error(w, "incorrect oauth cred", http.StatusBadRequest)
-		return
-	}
-	_, _, policyCred, err := utils.ParseVerifiableCredentialFromJWT(claims.CredentialJWTs.PolicyCredential.(string))
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
+	if role != "" {
+		if _, ok := utils.FindRole(roles, role); !ok {
+			http.Error(w, "false", http.StatusBadRequest)
+			return
+		}
 	}
-	if policyCred.Issuer != appDetails.AppDID {
-		http.Error(w, "incorrect policy cred", http.StatusBadRequest)
-		return
-	}
-	if !utils.IsRoleExists(policyCred.CredentialSubject, role) {
+	if len(permissions) > 0 && !utils.HasPermissions(roles, permissions, mode == permissionModeAll) {
 		http.Error(w, "false", http.StatusBadRequest)
 		return
 	}
@@ -308,11 +572,13 @@ func (h *AuthHandler) VerifyAccess(w http.ResponseWriter, r *http.Request) {
 // @Accept json
 // @Produce json
 // @Param Authorization header string true "Authorization token" default(Bearer YOUR_ACCESS_TOKEN)
+// @Param DPoP header string false "Proof of possession, required for bound tokens, each proof is accepted once"
 // @Param app_did query string true "Application DID"
 // @Param app_secret query string true "Application Secret"
 // @Success 200 {string} string "success"
 // @Failure 400 {string} string "Bad request"
 // @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Credential revoked (credential_revoked), issued to another subject (subject_mismatch) or app suspended (app_suspended)"
 // @Failure 500 {string} string "Internal server error"
 // @Router /get-access-list [get]
 func (h *AuthHandler) GetAccessList(w http.ResponseWriter, r *http.Request) {
@@ -321,6 +587,215 @@ func (h *AuthHandler) GetAccessList(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	appDetails, _, policyCred, ok := h.authenticate(w, r)
+	if !ok {
+		return
+	}
+	appPolicies, err := services.GetAttachedPolicies(h.db, appDetails.AppDID)
+	if err != nil {
+		http.Error(w, "Failed to get application policies: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	accessList := models.AccessList{
+		ApplicationPolicies: make(map[string]interface{}),
+		UserAccessList:      policyCred.CredentialSubject,
+	}
+	for _, appPolicy := range appPolicies {
+		accessList.ApplicationPolicies[appPolicy.PolicyType] = appPolicy.CredentialSubject
+	}
+	if userPolicy, err := services.UserPolicy(h.db, appDetails.AppDID); err == nil {
+		accessList.ApplicationPolicy = userPolicy.CredentialSubject
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(accessList)
+}
+
+// Authorize godoc
+// @Summary Authorize an action on a resource
+// @Description Evaluates the ABAC rules of the user's policy credential, or of the application policy when the credential carries none, against the subject attributes of the user and the action and resource attributes of the request. A matching deny rule overrides any permit rule and access is denied when no rule matches.
+// @Tags User Access Management
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Authorization token" default(Bearer YOUR_ACCESS_TOKEN)
+// @Param DPoP header string false "Proof of possession, required for bound tokens, each proof is accepted once"
+// @Param app_did query string true "Application DID"
+// @Param app_secret query string true "Application Secret"
+// @Param request body models.AuthorizeRequest true "Action and resource attributes"
+// @Success 200 {object} models.AuthorizeResponse "Decision and the rule deciding it"
+// @Failure 400 {string} string "Bad request"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Credential revoked (credential_revoked), issued to another subject (subject_mismatch) or app suspended (app_suspended)"
+// @Failure 500 {string} string "Internal server error"
+// @Router /authorize [post]
+func (h *AuthHandler) Authorize(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Only POST method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var validate = validator.New()
+	var authReq models.AuthorizeRequest
+
+	err := json.NewDecoder(r.Body).Decode(&authReq)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validate.Struct(authReq); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	appDetails, oauthCred, policyCred, ok := h.authenticate(w, r)
+	if !ok {
+		return
+	}
+	rules, err := utils.RulesFromSubject(policyCred.CredentialSubject)
+	if err != nil {
+		http.Error(w, "Failed to read policy rules: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(rules) == 0 {
+		// an app without an ABAC policy has no rules, access is denied
+		appPolicy, err := services.GetAttachedPolicy(h.db, appDetails.AppDID, models.PolicyTypeABAC)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "Failed to get application policy: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if appPolicy != nil {
+			if rules, err = utils.RulesFromSubject(appPolicy.CredentialSubject); err != nil {
+				http.Error(w, "Failed to read policy rules: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+	}
+
+	// the subject is described by the verified credentials only, never by the request
+	subject := make(map[string]interface{})
+	for name, value := range oauthCred.CredentialSubject {
+		subject[name] = value
+	}
+	if roles, err := h.effectiveRoles(appDetails.AppDID, policyCred); err == nil && len(roles) > 0 {
+		roleNames := make([]interface{}, 0, len(roles))
+		for _, role := range roles {
+			roleNames = append(roleNames, role.RoleName)
+		}
+		subject["roles"] = roleNames
+	}
+
+	decision := utils.EvaluateABAC(rules, subject, authReq.ActionAttributes, authReq.ResourceAttributes)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(decision)
+}
+
+// Introspect godoc
+// @Summary Introspect an access token
+// @Description RFC 7662 token introspection of a signed or opaque access token. The app authenticates with its DID and secret, as form-urlencoded HTTP Basic credentials or as query parameters. The token is active while it is unexpired, issued for the app and its credentials are not revoked, the response then carries the issuer, the user DID, the app and the permissions of the user. A token bound to a key of the user DID carries the key in cnf, the resource server checks the DPoP proof of the request against it. Any other token is answered with active false only.
+// @Tags Token Management
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param app_did query string false "Application DID, unless sent as HTTP Basic credentials"
+// @Param app_secret query string false "Application secret, unless sent as HTTP Basic credentials"
+// @Param token formData string true "Access token"
+// @Param token_type_hint formData string false "Token type hint" Enums(access_token)
+// @Success 200 {object} models.IntrospectionResponse
+// @Failure 400 {string} string "Bad request"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 500 {string} string "Internal server error"
+// @Router /introspect [post]
+func (h *AuthHandler) Introspect(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Only POST method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	token := r.PostForm.Get("token")
+	if token == "" {
+		http.Error(w, "token is required", http.StatusBadRequest)
+		return
+	}
+	appDid, appSecret, _ := appCredentials(r)
+	appDetails, err := h.db.GetApp(appDid)
+	if err != nil || !validAppSecret(appDetails, appSecret) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="authonomy"`)
+		http.Error(w, "Unauthorized: app credentials are invalid", http.StatusUnauthorized)
+		return
+	}
+
+	response := models.IntrospectionResponse{Active: false}
+	if appDetails.Status != models.AppStatusSuspended {
+		accessToken, oauthCred, policyCred, err := h.verifyAccessToken(appDetails, token)
+		var httpErr *httpError
+		if err != nil && (!errors.As(err, &httpErr) || httpErr.status >= http.StatusInternalServerError) {
+			// the token can not be judged, it is not reported inactive
+			writeError(w, err)
+			return
+		}
+		if err == nil {
+			response = h.introspection(appDetails.AppDID, accessToken, oauthCred)
+			if roles, err := h.effectiveRoles(appDetails.AppDID, policyCred); err == nil {
+				for _, role := range roles {
+					response.Roles = append(response.Roles, role.RoleName)
+				}
+				response.Permissions = utils.Permissions(roles)
+				response.Scope = strings.Join(response.Permissions, " ")
+			}
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(response)
+}
+
+// appCredentials returns the app DID and secret of a token endpoint request, sent as HTTP Basic credentials or
+// as query parameters. It reports whether any were sent.
+func appCredentials(r *http.Request) (string, string, bool) {
+	if appDid, appSecret, ok := r.BasicAuth(); ok {
+		// the DID has colons, both parts are form-urlencoded (RFC 6749 section 2.3.1)
+		appDid, _ = url.QueryUnescape(appDid)
+		appSecret, _ = url.QueryUnescape(appSecret)
+		return appDid, appSecret, true
+	}
+	queryParams := r.URL.Query()
+	return queryParams.Get("app_did"), queryParams.Get("app_secret"), queryParams.Has("app_did")
+}
+
+// requestURL is the URL of the request as the client reached it, the htu a DPoP proof is made for
+func requestURL(issuer string, r *http.Request) string {
+	return strings.TrimSuffix(issuer, "/") + r.URL.Path
+}
+
+// introspection describes an active access token
+func (h *AuthHandler) introspection(appDID string, accessToken *models.AccessToken, oauthCred *credential.VerifiableCredential) models.IntrospectionResponse {
+	response := models.IntrospectionResponse{
+		Active:       true,
+		TokenID:      accessToken.TokenID,
+		TokenType:    services.TokenTypeBearer,
+		ClientID:     appDID,
+		AppDID:       appDID,
+		Issuer:       h.issuer,
+		Audience:     appDID,
+		Subject:      oauthCred.CredentialSubject.GetID(),
+		ExpiresAt:    accessToken.ExpiresAt.Unix(),
+		Confirmation: accessToken.Confirmation,
+	}
+	if accessToken.Confirmation != nil {
+		response.TokenType = services.TokenTypeDPoP
+	}
+	if !accessToken.IssuedAt.IsZero() {
+		response.IssuedAt = accessToken.IssuedAt.Unix()
+		response.NotBefore = accessToken.IssuedAt.Unix()
+	}
+	return response
+}
+
+// authenticate checks the app secret and the access token of the request, a token bound to a key of the
+// user DID needs a DPoP proof as well. The credentials embedded in the token are verified against the
+// application and their status lists, on failure the error response is written and false is returned.
+func (h *AuthHandler) authenticate(w http.ResponseWriter, r *http.Request) (*models.ApplicationResponse, *credential.VerifiableCredential, *credential.VerifiableCredential, bool) {
 	// Extract query parameters
 	queryParams := r.URL.Query()
 	appDid := queryParams.Get("app_did")
@@ -329,55 +804,147 @@ func (h *AuthHandler) GetAccessList(w http.ResponseWriter, r *http.Request) {
 	appDetails, err := h.db.GetApp(appDid)
 	if err != nil {
 		http.Error(w, "app is invalid", http.StatusInternalServerError)
-		return
+		return nil, nil, nil, false
 	}
-	if appDetails.AppSceret != appSecret {
+	if !validAppSecret(appDetails, appSecret) {
 		http.Error(w, "app secret is invalid", http.StatusInternalServerError)
-		return
+		return nil, nil, nil, false
+	}
+	if appDetails.Status == models.AppStatusSuspended {
+		writeError(w, errAppSuspended)
+		return nil, nil, nil, false
 	}
 
 	authHeader := r.Header.Get("Authorization")
 	if authHeader == "" {
 		http.Error(w, "Unauthorized: No Authorization header provided", http.StatusUnauthorized)
-		return
+		return nil, nil, nil, false
 	}
 	// Split the header to get the token part
 	headerParts := strings.Split(authHeader, " ")
-	if len(headerParts) != 2 || headerParts[0] != "Bearer" {
+	if len(headerParts) != 2 || (headerParts[0] != services.TokenTypeBearer && headerParts[0] != services.TokenTypeDPoP) {
 		http.Error(w, "Unauthorized: Invalid Authorization header format", http.StatusUnauthorized)
-		return
+		return nil, nil, nil, false
 	}
 	// headerParts[1] contains the actual token
 	token := headerParts[1]
 
-	claims, err := utils.ValidateAccessToken(token)
+	accessToken, oauthCred, policyCred, err := h.verifyAccessToken(appDetails, token)
+	if err == nil {
+		err = h.checkHolderProof(r, accessToken.TokenGrant, token)
+	}
 	if err != nil {
-		http.Error(w, "Unauthorized: Invalid access token", http.StatusUnauthorized)
-		return
+		writeError(w, err)
+		return nil, nil, nil, false
+	}
+	return appDetails, oauthCred, policyCred, true
+}
+
+// verifyAccessToken resolves the access token issued for the app, its audience, and verifies the credentials
+// it carries against the application and their status lists. Failures are httpErrors.
+func (h *AuthHandler) verifyAccessToken(app *models.ApplicationResponse, token string) (*models.AccessToken, *credential.VerifiableCredential, *credential.VerifiableCredential, error) {
+	accessToken, err := services.ResolveAccessToken(h.db, h.issuer, token)
+	if errors.Is(err, services.ErrInvalidAccessToken) || (err == nil && accessToken.AppDID != app.AppDID) {
+		return nil, nil, nil, &httpError{status: http.StatusUnauthorized, message: "Unauthorized: Invalid access token"}
 	}
-	// TODO:: revokable check
-	_, _, oauthCred, err := utils.ParseVerifiableCredentialFromJWT(claims.CredentialJWTs.OAuthCredential.(string))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
+		return nil, nil, nil, err
 	}
-	if oauthCred.Issuer != appDetails.AppDID {
-		http.Error(w, "incorrect oauth cred", http.StatusBadRequest)
-		return
+	oauthCred, policyCred, err := h.verifyCredentials(app, accessToken.OAuthCredential, accessToken.PolicyCredential)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	// the subject of the token is the user the credentials were issued to
+	if accessToken.SubjectDID != "" && accessToken.SubjectDID != oauthCred.CredentialSubject.GetID() {
+		return nil, nil, nil, &httpError{status: http.StatusUnauthorized, message: "Unauthorized: Invalid access token"}
+	}
+	return accessToken, oauthCred, policyCred, nil
+}
+
+// checkHolderProof checks the DPoP proof of a request made with tokens bound to a key of the user DID, it must
+// be signed with the bound key. Requests with unbound tokens pass. Failures are httpErrors.
+func (h *AuthHandler) checkHolderProof(r *http.Request, grant models.TokenGrant, accessToken string) error {
+	if grant.Confirmation == nil {
+		return nil
 	}
-	_, _, policyCred, err := utils.ParseVerifiableCredentialFromJWT(claims.CredentialJWTs.PolicyCredential.(string))
+	proof := r.Header.Get("DPoP")
+	if proof == "" {
+		return &httpError{status: http.StatusUnauthorized, message: "Unauthorized: the token is bound to a key, a DPoP proof is required"}
+	}
+	kid, err := services.VerifyHolderProof(h.db, proof, grant.SubjectDID, r.Method, requestURL(h.issuer, r), accessToken)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
+		return &httpError{status: http.StatusUnauthorized, message: "Unauthorized: invalid DPoP proof: " + err.Error()}
 	}
-	if policyCred.Issuer != appDetails.AppDID {
-		http.Error(w, "incorrect policy cred", http.StatusBadRequest)
-		return
+	if kid != grant.Confirmation.KeyID {
+		return &httpError{status: http.StatusUnauthorized, message: "Unauthorized: the DPoP proof is signed with another key"}
 	}
-	appPolicy, _ := h.db.GetIssuedPolicy(appDid)
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(models.AccessList{
-		ApplicationPolicy: appPolicy.CredentialSubject,
-		UserAccessList:    policyCred.CredentialSubject,
-	})
+	return nil
+}
+
+// verifyCredentials verifies the OAuth and policy credential JWTs against the application and their status
+// lists. Failures are httpErrors.
+func (h *AuthHandler) verifyCredentials(app *models.ApplicationResponse, oauthCredential, policyCredential string) (*credential.VerifiableCredential, *credential.VerifiableCredential, error) {
+	_, _, oauthCred, err := utils.VerifyVerifiableCredentialFromJWT(oauthCredential)
+	if err != nil {
+		return nil, nil, &httpError{status: http.StatusBadRequest, message: err.Error()}
+	}
+	if oauthCred.Issuer != app.AppDID {
+		return nil, nil, &httpError{status: http.StatusBadRequest, message: "incorrect oauth cred"}
+	}
+	_, _, policyCred, err := utils.VerifyVerifiableCredentialFromJWT(policyCredential)
+	if err != nil {
+		return nil, nil, &httpError{status: http.StatusBadRequest, message: err.Error()}
+	}
+	if policyCred.Issuer != app.AppDID {
+		return nil, nil, &httpError{status: http.StatusBadRequest, message: "incorrect policy cred"}
+	}
+	if policyCred.CredentialSubject.GetID() != oauthCred.CredentialSubject.GetID() {
+		return nil, nil, &httpError{status: http.StatusForbidden, code: models.ErrorSubjectMismatch, message: "Forbidden: policy credential was issued to another subject"}
+	}
+	if err := h.credentialStatus(oauthCred, policyCred); err != nil {
+		return nil, nil, err
+	}
+	return oauthCred, policyCred, nil
+}
+
+// effectiveRoles returns the roles of the policy credential together with the roles they inherit,
+// the hierarchy is declared in the policy attached to the application.
+func (h *AuthHandler) effectiveRoles(appDID string, policyCred *credential.VerifiableCredential) ([]models.Role, error) {
+	userRoles, err := utils.RolesFromSubject(policyCred.CredentialSubject)
+	if err != nil {
+		return nil, err
+	}
+	var appRoles []models.Role
+	if appPolicy, err := services.UserPolicy(h.db, appDID); err == nil {
+		// an unreadable application policy only means nothing is inherited
+		appRoles, _ = utils.RolesFromSubject(appPolicy.CredentialSubject)
+	}
+	return utils.EffectiveRoles(userRoles, appRoles), nil
+}
+
+// errAppSuspended answers the requests of the users of a suspended app
+var errAppSuspended = &httpError{status: http.StatusForbidden, code: models.ErrorAppSuspended, message: "app is suspended"}
+
+// checkRevocation resolves the status list of each credential and writes a 403 response if any of them is revoked.
+func (h *AuthHandler) checkRevocation(w http.ResponseWriter, creds ...*credential.VerifiableCredential) bool {
+	if err := h.credentialStatus(creds...); err != nil {
+		writeError(w, err)
+		return false
+	}
+	return true
+}
+
+// credentialStatus resolves the status list of each credential, a revoked credential is a 403 httpError with the
+// credential_revoked error code.
+func (h *AuthHandler) credentialStatus(creds ...*credential.VerifiableCredential) error {
+	for _, cred := range creds {
+		err := h.revocation.CheckRevocation(cred)
+		if errors.Is(err, services.ErrCredentialRevoked) {
+			return &httpError{status: http.StatusForbidden, code: models.ErrorCredentialRevoked, message: "Forbidden: " + err.Error()}
+		}
+		if err != nil {
+			return &httpError{status: http.StatusInternalServerError, message: "Failed to check credential status: " + err.Error()}
+		}
+	}
+	return nil
 }
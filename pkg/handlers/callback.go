@@ -1,23 +1,32 @@
 package handlers
 
 import (
+	"authonomy/models"
 	"authonomy/pkg/providers"
+	"authonomy/store"
+	"crypto/subtle"
 	"encoding/json"
-	"fmt"
 	"net/http"
+	"net/url"
+	"slices"
 	"strings"
+	"time"
+
+	"github.com/google/uuid"
 )
 
 // Assuming CallbackHandler is defined elsewhere in your package
 type CallbackHandler struct {
+	db store.Store
 }
 
 // NewCallbackHandler creates a new instance of AppHandler
-func NewCallbackHandler() *CallbackHandler {
-	return &CallbackHandler{}
+func NewCallbackHandler(db store.Store) *CallbackHandler {
+	return &CallbackHandler{db: db}
 }
 
 // HandleCallback handles the callback route
+// The authorization code is exchanged server-side, so the provider tokens never reach the browser.
 func (r *CallbackHandler) HandleCallback(w http.ResponseWriter, req *http.Request) {
 	// Split the URL path to get the parameters
 	pathSegments := strings.Split(req.URL.Path, "/")
@@ -29,34 +38,91 @@ func (r *CallbackHandler) HandleCallback(w http.ResponseWriter, req *http.Reques
 	provider := pathSegments[2]
 	did := pathSegments[3]
 
-	fmt.Println("did", did)
-	fmt.Println("provider", provider)
+	query := req.URL.Query()
+	if providerErr := query.Get("error"); providerErr != "" {
+		http.Error(w, "sign in was not completed: "+providerErr, http.StatusBadRequest)
+		return
+	}
+	code := query.Get("code")
+	state := query.Get("state")
+	if code == "" || state == "" {
+		http.Error(w, "code and state are required", http.StatusBadRequest)
+		return
+	}
 
-	// Redirect to the web page with query parameters
-	redirectURL := "/web/index.html?provider=" + provider + "&did=" + did
-	http.Redirect(w, req, redirectURL, http.StatusMovedPermanently)
-}
+	// the state must be the one of the sign-in attempt started by this browser
+	cookie, err := req.Cookie(stateCookie)
+	if err != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) != 1 {
+		http.Error(w, "Forbidden: state does not match the sign in session", http.StatusForbidden)
+		return
+	}
+	clearCookie(w, req, stateCookie)
+	authSession, err := r.db.TakeAuthSession(state)
+	if err != nil || time.Now().After(authSession.ExpiresAt) {
+		http.Error(w, "Forbidden: sign in attempt is unknown or expired", http.StatusForbidden)
+		return
+	}
+	if authSession.AppDID != did || !slices.Contains(authSession.Providers, provider) {
+		http.Error(w, "Forbidden: sign in attempt was started for another application", http.StatusForbidden)
+		return
+	}
 
-func (r *CallbackHandler) HandleMe(w http.ResponseWriter, req *http.Request) {
-	// Split the URL path to get the parameters
-	pathSegments := strings.Split(req.URL.Path, "/")
-	if len(pathSegments) < 4 {
-		http.Error(w, "Invalid request", http.StatusBadRequest)
+	auth, err := r.db.GetAuthProvider(did, provider)
+	if err != nil {
+		http.Error(w, "provider is not linked to the application: "+provider, http.StatusBadRequest)
+		return
+	}
+	p, err := providers.Get(provider)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	authReq := providers.AuthRequest{State: authSession.State, Nonce: authSession.Nonce, CodeVerifier: authSession.CodeVerifier}
+	token, err := p.Exchange(auth.Config, code, authReq)
+	if err != nil {
+		http.Error(w, "Failed to exchange the authorization code: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	userInfo, err := providers.GetUserInfo(provider, auth.Config, token, authReq)
+	if err != nil {
+		http.Error(w, "Failed to get user info: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	provider := pathSegments[2]
-	accessToken := pathSegments[3]
+	session := models.LoginSession{
+		SessionID: uuid.NewString(),
+		AppDID:    did,
+		Provider:  provider,
+		UserInfo:  *userInfo,
+		ExpiresAt: time.Now().UTC().Add(loginSessionTTL),
+	}
+	if err := r.db.SetLoginSession(session); err != nil {
+		http.Error(w, "Failed to save the sign in session: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	setCookie(w, req, sessionCookie, session.SessionID, session.ExpiresAt)
 
-	fmt.Println("provider", provider)
-	fmt.Println("accessToken", accessToken)
+	// Redirect to the web page with query parameters
+	params := url.Values{}
+	params.Set("provider", provider)
+	params.Set("did", did)
+	http.Redirect(w, req, "/web/index.html?"+params.Encode(), http.StatusFound)
+}
 
-	userInfo, err := providers.GetUserInfo(provider, accessToken)
+// HandleMe returns the user info of the signed in user of the session
+func (r *CallbackHandler) HandleMe(w http.ResponseWriter, req *http.Request) {
+	session, err := loginSession(r.db, req)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+	// the provider is optional in the path, /me/{provider}
+	pathSegments := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	if len(pathSegments) > 1 && pathSegments[1] != session.Provider {
+		http.Error(w, "Unauthorized: no sign in session for provider "+pathSegments[1], http.StatusUnauthorized)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(userInfo)
+	json.NewEncoder(w).Encode(session.UserInfo)
 }
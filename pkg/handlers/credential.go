@@ -2,11 +2,12 @@ package handlers
 
 import (
 	"authonomy/models"
-	"authonomy/pkg/providers"
 	"authonomy/services"
 	"authonomy/store"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"time"
 
 	"github.com/go-playground/validator"
 )
@@ -14,12 +15,13 @@ import (
 // CredentialHandler handles credential-related requests
 type CredentialHandler struct {
 	ssiService *services.SsiClient
-	db         *store.Store
+	db         store.Store
+	issuer     string
 }
 
-// NewCredentialHandler creates a new instance of CredentialHandler
-func NewCredentialHandler(ssiService *services.SsiClient, db *store.Store) *CredentialHandler {
-	return &CredentialHandler{ssiService: ssiService, db: db}
+// NewCredentialHandler creates a new instance of CredentialHandler, the issuer is the URL the service is reached at
+func NewCredentialHandler(ssiService *services.SsiClient, db store.Store, issuer string) *CredentialHandler {
+	return &CredentialHandler{ssiService: ssiService, db: db, issuer: issuer}
 }
 
 func (h *CredentialHandler) IssueOAuthCredential(w http.ResponseWriter, r *http.Request) {
@@ -40,8 +42,28 @@ func (h *CredentialHandler) IssueOAuthCredential(w http.ResponseWriter, r *http.
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	// the user info comes from the sign in session, it was fetched server-side at the callback
+	session, err := loginSession(h.db, r)
+	if err != nil {
+		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if session.AppDID != credReq.AppDID || session.Provider != credReq.Provider {
+		http.Error(w, "Unauthorized: sign in session is for another application", http.StatusUnauthorized)
+		return
+	}
+	// the roles granted to the user DID are only issued to its holder, the sign in alone does not prove it
+	proof := r.Header.Get("DPoP")
+	if proof == "" {
+		http.Error(w, "Unauthorized: a DPoP proof of a key of the user DID is required", http.StatusUnauthorized)
+		return
+	}
+	if _, err := services.VerifyHolderProof(h.db, proof, credReq.UserDID, r.Method, requestURL(h.issuer, r), ""); err != nil {
+		http.Error(w, "Unauthorized: invalid DPoP proof: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
 
-	schema, err := h.db.GetProviderSchema("facebook")
+	schema, err := h.db.GetProviderSchema(credReq.Provider)
 	if err != nil {
 		http.Error(w, "Failed to get schema ID: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -57,8 +79,16 @@ func (h *CredentialHandler) IssueOAuthCredential(w http.ResponseWriter, r *http.
 		http.Error(w, "Failed to get application DID: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if app.Status == models.AppStatusSuspended {
+		http.Error(w, "app is suspended", http.StatusForbidden)
+		return
+	}
 
-	policy, err := h.db.GetIssuedPolicy(app.AppDID)
+	policy, err := services.UserPolicy(h.db, app.AppDID)
+	if errors.Is(err, services.ErrNoRBACPolicy) {
+		http.Error(w, "Failed to get application policy: "+err.Error(), http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		http.Error(w, "Failed to get application policy: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -69,28 +99,31 @@ func (h *CredentialHandler) IssueOAuthCredential(w http.ResponseWriter, r *http.
 		http.Error(w, "Failed to get application DID: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
-	userInfo, err := providers.GetUserInfo("facebook", credReq.AccessToken)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if _, err := h.db.GetAuthProvider(app.AppDID, credReq.Provider); err != nil {
+		http.Error(w, "provider is not linked to the application: "+credReq.Provider, http.StatusBadRequest)
 		return
 	}
-	userCredMap, err := models.StructToMap(models.UserInfo{UserID: userInfo.ID, Name: userInfo.Name})
+	userCredMap, err := models.StructToMap(session.UserInfo)
 	if err != nil {
 		http.Error(w, "Failed to convert to map: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
-	// TODO:: revokable
 	userCredential, err := h.ssiService.IssueCredentialBySchemaID(app.AppDID, credReq.UserDID, schema.SchemaID, userCredMap)
 	if err != nil {
 		http.Error(w, "Failed to issue userCredential: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
-	// default role as user (hardcoded for the hackathon demo)
-	userRole := models.Role{
-		RoleName:    "user",
-		Permissions: []string{"view_content", "comment"},
+	err = recordCredential(h.db, userCredential.ID, app.AppDID, credReq.UserDID, schema.SchemaID)
+	if err != nil {
+		http.Error(w, "Failed to save userCredential: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// roles granted by the application owner, the default role otherwise
+	access, err := h.db.GetUserAccess(app.AppDID, credReq.UserDID)
+	if err != nil {
+		access = &models.UserAccess{AppDID: app.AppDID, UserDID: credReq.UserDID, Roles: []models.Role{defaultUserRole}}
 	}
-	policyCredMap, err := models.StructToMap(models.RolesWrapper{Roles: []models.Role{userRole}})
+	policyCredMap, err := models.StructToMap(models.RolesWrapper{Roles: access.Roles})
 	if err != nil {
 		http.Error(w, "Failed to convert to map: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -100,19 +133,39 @@ func (h *CredentialHandler) IssueOAuthCredential(w http.ResponseWriter, r *http.
 		http.Error(w, "Failed to issue policyCredential: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	err = recordCredential(h.db, policyCredential.ID, app.AppDID, credReq.UserDID, policy.SchemaID)
+	if err != nil {
+		http.Error(w, "Failed to save policyCredential: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	access.PolicyCredentialID = policyCredential.ID
+	access.UpdatedAt = time.Now().UTC()
+	err = h.db.SetUserAccess(*access)
+	if err != nil {
+		http.Error(w, "Failed to save user access: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// the session is only good for a single issuance
+	if err := h.db.DeleteLoginSession(session.SessionID); err != nil {
+		http.Error(w, "Failed to end the sign in session: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	clearCookie(w, r, sessionCookie)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(models.IssueOAuthCredential{OAuthCredential: userCredential, PolicyCredential: policyCredential})
 }
 
 // RevokeOAuthCredential godoc
 // @Summary Revoke OAuth Credential
-// @Description Revoke an existing OAuth credential.
+// @Description Revoke an existing credential issued by the application by flipping its status list entry.
 // @Tags Authentication Management
 // @Accept  json
 // @Produce  json
-// @Param revokeOAuthCredentialRequest body interface{} true "Revoke Credential Request"
-// @Success 200 {string} string "Credential successfully revoked"
+// @Param x-api-key header string true "API Key"
+// @Param revokeOAuthCredentialRequest body models.RevokeCredentialRequest true "Revoke Credential Request"
+// @Success 200 {object} models.CredentialRecord "Credential successfully revoked"
 // @Failure 400 {string} string "Bad request"
+// @Failure 404 {string} string "Credential not found"
 // @Failure 500 {string} string "Internal server error"
 // @Router /revoke-credential [post]
 func (h *CredentialHandler) RevokeOAuthCredential(w http.ResponseWriter, r *http.Request) {
@@ -120,6 +173,55 @@ func (h *CredentialHandler) RevokeOAuthCredential(w http.ResponseWriter, r *http
 		http.Error(w, "Only POST method is allowed", http.StatusMethodNotAllowed)
 		return
 	}
+
+	var validate = validator.New()
+	var revokeReq models.RevokeCredentialRequest
+
+	err := json.NewDecoder(r.Body).Decode(&revokeReq)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validate.Struct(revokeReq); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	record, err := h.db.GetCredentialRecord(revokeReq.CredentialID)
+	if err != nil || record.AppDID != revokeReq.AppDID {
+		http.Error(w, "credential not found for the application", http.StatusNotFound)
+		return
+	}
+	// already revoked, nothing to flip
+	if !record.Revoked {
+		if err := revokeCredential(h.ssiService, h.db, record, revokeReq.Reason); err != nil {
+			http.Error(w, "Failed to revoke credential: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode("implementation pending")
+	json.NewEncoder(w).Encode(record)
+}
+
+// recordCredential keeps track of an issued credential so it can be revoked later.
+func recordCredential(db store.Store, credentialID, appDID, subjectDID, schemaID string) error {
+	return db.SetCredentialRecord(models.CredentialRecord{
+		CredentialID: credentialID,
+		AppDID:       appDID,
+		SubjectDID:   subjectDID,
+		SchemaID:     schemaID,
+		IssuedAt:     time.Now().UTC(),
+	})
+}
+
+// revokeCredential revokes the credential through the ssi service and records the revocation.
+func revokeCredential(ssiService *services.SsiClient, db store.Store, record *models.CredentialRecord, reason string) error {
+	if _, err := ssiService.RevokeCredential(record.CredentialID); err != nil {
+		return err
+	}
+	revokedAt := time.Now().UTC()
+	record.Revoked = true
+	record.RevokedAt = &revokedAt
+	record.Reason = reason
+	return db.SetCredentialRecord(*record)
 }
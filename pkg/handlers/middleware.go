@@ -1,17 +1,26 @@
 package handlers
 
 import (
+	"authonomy/models"
+	"authonomy/services"
+	"authonomy/store"
+	"context"
 	"log"
 	"net/http"
 )
 
+type contextKey string
+
+// apiKeyContextKey holds the API key verified by XApiKeyMiddleware in the request context
+const apiKeyContextKey contextKey = "api-key"
+
 type MiddlewareService struct {
-	apikey string
+	db store.Store
 }
 
-// NewAppHandler creates a new instance of AppHandler
-func NewMiddlewareService(apikey string) *MiddlewareService {
-	return &MiddlewareService{apikey: apikey}
+// NewMiddlewareService creates a new instance of MiddlewareService, the admin API keys are read from db
+func NewMiddlewareService(db store.Store) *MiddlewareService {
+	return &MiddlewareService{db: db}
 }
 
 // Middleware type defines a function that wraps an http.HandlerFunc
@@ -23,7 +32,7 @@ func (m MiddlewareService) EnableCORS(next http.HandlerFunc) http.HandlerFunc {
 		// Set CORS headers
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, DPoP")
 
 		// Handle preflight requests
 		if r.Method == "OPTIONS" {
@@ -36,18 +45,35 @@ func (m MiddlewareService) EnableCORS(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
-// XApiKeyMiddleware checks for a valid x-api-key in the request headers
-func (m MiddlewareService) XApiKeyMiddleware(next http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		providedApiKey := r.Header.Get("x-api-key")
-		if providedApiKey != m.apikey {
-			http.Error(w, "Unauthorized: Invalid API key", http.StatusUnauthorized)
-			return
+// XApiKeyMiddleware checks for a valid x-api-key in the request headers, the key must carry
+// readScope for GET requests and writeScope for the others. The verified key is passed on in the request context.
+func (m MiddlewareService) XApiKeyMiddleware(readScope, writeScope string) Middleware {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			apiKey, err := services.VerifyAPIKey(m.db, r.Header.Get("x-api-key"))
+			if err != nil {
+				http.Error(w, "Unauthorized: Invalid API key", http.StatusUnauthorized)
+				return
+			}
+			scope := writeScope
+			if r.Method == "GET" || r.Method == "HEAD" {
+				scope = readScope
+			}
+			if !services.HasScope(apiKey, scope) {
+				http.Error(w, "Forbidden: API key lacks the scope "+scope, http.StatusForbidden)
+				return
+			}
+			next(w, r.WithContext(context.WithValue(r.Context(), apiKeyContextKey, apiKey)))
 		}
-		next(w, r)
 	}
 }
 
+// requestAPIKey returns the API key verified by XApiKeyMiddleware, nil when the request went through none
+func requestAPIKey(r *http.Request) *models.APIKey {
+	apiKey, _ := r.Context().Value(apiKeyContextKey).(*models.APIKey)
+	return apiKey
+}
+
 // LoggingMiddleware logs each request
 func (m MiddlewareService) LoggingMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
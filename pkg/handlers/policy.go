@@ -2,31 +2,36 @@ package handlers
 
 import (
 	"authonomy/models"
+	"authonomy/pkg/utils"
 	"authonomy/services"
 	"authonomy/store"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"slices"
+
+	"github.com/go-playground/validator"
 )
 
 // PolicyHandler handles policy-related requests
 type PolicyHandler struct {
 	ssiService *services.SsiClient
-	db         *store.Store
+	db         store.Store
 }
 
 // NewPolicyHandler creates a new instance of PolicyHandler
-func NewPolicyHandler(ssiService *services.SsiClient, db *store.Store) *PolicyHandler {
+func NewPolicyHandler(ssiService *services.SsiClient, db store.Store) *PolicyHandler {
 	return &PolicyHandler{ssiService: ssiService, db: db}
 }
 
 // GetPolicyHandler retrieves all policies
 // @Summary Get all policies
-// @Description Retrieves a list of all policies
+// @Description Retrieves a list of all policies with their schema versions, oldest first
 // @Tags Authorization Management
 // @Accept json
 // @Produce json
 // @Param x-api-key header string true "API Key"
-// @Success 200 {array} models.PolicySchemaResponse
+// @Success 200 {array} models.Policy
 // @Failure 500 {string} string "Internal Server Error"
 // @Router /policies [get]
 func (h *PolicyHandler) GetPolicyHandler(w http.ResponseWriter, r *http.Request) {
@@ -34,7 +39,7 @@ func (h *PolicyHandler) GetPolicyHandler(w http.ResponseWriter, r *http.Request)
 		http.Error(w, "Only GET method is allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	policies, err := h.db.GetAllPolicies()
+	policies, err := services.GetPolicies(h.db)
 	if err != nil {
 		http.Error(w, "Failed to get policy: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -45,7 +50,7 @@ func (h *PolicyHandler) GetPolicyHandler(w http.ResponseWriter, r *http.Request)
 
 // CreatePolicyHandler creates a new policy
 // @Summary Create a new policy
-// @Description Creates a new policy based on the provided schema
+// @Description Creates a new policy based on the provided schema, it is version 1 of the policy. The name identifies the policy, it must not be taken.
 // @Tags Authorization Management
 // @Accept json
 // @Produce json
@@ -53,6 +58,7 @@ func (h *PolicyHandler) GetPolicyHandler(w http.ResponseWriter, r *http.Request)
 // @Param schema body models.PolicySchemaRequest true "Policy Schema"
 // @Success 200 {object} models.PolicySchemaResponse "Successfully created policy"
 // @Failure 400 {string} string "Bad Request"
+// @Failure 409 {string} string "Policy Already Exists"
 // @Failure 500 {string} string "Internal Server Error"
 // @Router /create-policy [post]
 func (h *PolicyHandler) CreatePolicyHandler(w http.ResponseWriter, r *http.Request) {
@@ -60,17 +66,113 @@ func (h *PolicyHandler) CreatePolicyHandler(w http.ResponseWriter, r *http.Reque
 		http.Error(w, "Only POST method is allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	var schema models.PolicySchemaRequest
-	err := json.NewDecoder(r.Body).Decode(&schema)
+	schema, ok := decodePolicySchema(w, r)
+	if !ok {
+		return
+	}
+	_, err := services.GetPolicyVersions(h.db, schema.Name)
+	if err == nil {
+		http.Error(w, "policy already exists, use /update-policy to add a version: "+schema.Name, http.StatusConflict)
+		return
+	}
+	if !errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Failed to get policy: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.createPolicyVersion(w, schema, 1)
+}
+
+// UpdatePolicyHandler adds a version to a policy
+// @Summary Add a policy version
+// @Description Creates a new schema version of an existing policy. Attached applications keep their version until the new one is attached.
+// @Tags Authorization Management
+// @Accept json
+// @Produce json
+// @Param x-api-key header string true "API Key"
+// @Param schema body models.PolicySchemaRequest true "Policy Schema"
+// @Success 200 {object} models.PolicySchemaResponse "Successfully created policy version"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 404 {string} string "Policy Not Found"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /update-policy [post]
+func (h *PolicyHandler) UpdatePolicyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Only POST method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	schema, ok := decodePolicySchema(w, r)
+	if !ok {
+		return
+	}
+	if _, err := services.GetPolicyVersions(h.db, schema.Name); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "policy not found: "+schema.Name, http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to get policy: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	version, err := services.NextPolicyVersion(h.db, schema.Name)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		http.Error(w, "Failed to get policy: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	h.createPolicyVersion(w, schema, version)
+}
+
+// DeprecatePolicyHandler deprecates a policy version
+// @Summary Deprecate a policy version
+// @Description Deprecates a policy version, it can no longer be attached. Applications and credentials already using it are left as they are.
+// @Tags Authorization Management
+// @Accept json
+// @Produce json
+// @Param x-api-key header string true "API Key"
+// @Param schema_id query string true "Schema ID of the version"
+// @Success 200 {object} models.PolicySchemaResponse "Deprecated policy version"
+// @Failure 404 {string} string "Policy Not Found"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /deprecate-policy [post]
+func (h *PolicyHandler) DeprecatePolicyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Only POST method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	policy, err := services.DeprecatePolicy(h.db, r.URL.Query().Get("schema_id"))
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "policy not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to deprecate policy: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(policy)
+}
+
+func decodePolicySchema(w http.ResponseWriter, r *http.Request) (models.PolicySchemaRequest, bool) {
+	var validate = validator.New()
+	var schema models.PolicySchemaRequest
+	if err := json.NewDecoder(r.Body).Decode(&schema); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return schema, false
+	}
+	if err := validate.Struct(schema); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return schema, false
+	}
+	return schema, true
+}
+
+// createPolicyVersion creates the schema on the SSI service and stores it as the given version of the policy
+func (h *PolicyHandler) createPolicyVersion(w http.ResponseWriter, schema models.PolicySchemaRequest, version int) {
 	respSchema, err := h.ssiService.CreatePolicy(schema)
 	if err != nil {
 		http.Error(w, "Failed to create policy: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	respSchema.Name = schema.Name
+	respSchema.Version = version
 	err = h.db.SetPolicy(respSchema)
 	if err != nil {
 		http.Error(w, "Failed to store policy: "+err.Error(), http.StatusInternalServerError)
@@ -82,14 +184,16 @@ func (h *PolicyHandler) CreatePolicyHandler(w http.ResponseWriter, r *http.Reque
 
 // AttachPolicyHandler attaches a policy to an application
 // @Summary Attach policy to application
-// @Description Attaches a policy to an application using the provided application and issuer DID, and schema ID
+// @Description Attaches a policy to an application using the provided application and issuer DID, and schema ID. Without a schema ID the latest version of the named policy is attached, deprecated versions are rejected. An application holds one RBAC and one ABAC policy, the type is taken from the credential when not given. Attaching another version of an attached policy upgrades the application and lists the credentials still issued against the replaced version. Roles of an RBAC policy may inherit other roles, undefined roles and inheritance cycles are rejected.
 // @Tags Authorization Management
 // @Accept json
 // @Produce json
 // @Param x-api-key header string true "API Key"
 // @Param appPolicy body models.ApplicationPolicyRequest true "Application Policy Request"
-// @Success 200 {object} models.ApplicationPolicyResponse "Successfully attached policy"
+// @Success 200 {object} models.AttachPolicyResponse "Successfully attached policy"
 // @Failure 400 {string} string "Bad Request"
+// @Failure 404 {string} string "Policy Not Found"
+// @Failure 409 {string} string "Another Policy Of The Type Is Attached"
 // @Failure 500 {string} string "Internal Server Error"
 // @Router /attach-policy [post]
 func (h *PolicyHandler) AttachPolicyHandler(w http.ResponseWriter, r *http.Request) {
@@ -97,12 +201,71 @@ func (h *PolicyHandler) AttachPolicyHandler(w http.ResponseWriter, r *http.Reque
 		http.Error(w, "Only POST method is allowed", http.StatusMethodNotAllowed)
 		return
 	}
+	var validate = validator.New()
 	var appPolicy models.ApplicationPolicyRequest
 	err := json.NewDecoder(r.Body).Decode(&appPolicy)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if err := validate.Struct(appPolicy); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	policy, err := services.ResolvePolicySchema(h.db, appPolicy.SchemaID, appPolicy.PolicyName)
+	switch {
+	case err == nil:
+		appPolicy.SchemaID = policy.ID
+	case errors.Is(err, store.ErrNotFound) && appPolicy.SchemaID != "":
+		// the schema was not created through the service, it is attached unversioned
+	case errors.Is(err, store.ErrNotFound):
+		http.Error(w, "policy not found: "+appPolicy.PolicyName, http.StatusNotFound)
+		return
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if appPolicy.PolicyType == "" {
+		appPolicy.PolicyType = utils.PolicyTypeFromSubject(appPolicy.Credential)
+	}
+	switch appPolicy.PolicyType {
+	case models.PolicyTypeRBAC:
+		// RBAC policies declare the role hierarchy, it is resolved when access is verified
+		roles, err := utils.RolesFromSubject(appPolicy.Credential)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := utils.ValidateRoleHierarchy(roles); err != nil {
+			http.Error(w, "Invalid role hierarchy: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	case models.PolicyTypeABAC:
+		if _, err := utils.RulesFromSubject(appPolicy.Credential); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, "policy_type is required when the credential declares neither roles nor rules", http.StatusBadRequest)
+		return
+	}
+	// an app holds one policy of each type, attaching another version of it is an upgrade
+	previous, err := services.GetAttachedPolicy(h.db, appPolicy.ApplicationDID, appPolicy.PolicyType)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Failed to get application policy: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if previous != nil && previous.SchemaID != appPolicy.SchemaID {
+		versions, err := services.PolicySchemaIDs(h.db, appPolicy.SchemaID)
+		if err != nil {
+			http.Error(w, "Failed to get policy: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !slices.Contains(versions, previous.SchemaID) {
+			http.Error(w, "another "+appPolicy.PolicyType+" policy is attached to the app, detach it first: "+previous.SchemaID, http.StatusConflict)
+			return
+		}
+	}
 	isExist := h.ssiService.IsSchemaExists(appPolicy.SchemaID)
 	if !isExist {
 		http.Error(w, "Schema does not exists id: "+appPolicy.SchemaID, http.StatusInternalServerError)
@@ -124,9 +287,15 @@ func (h *PolicyHandler) AttachPolicyHandler(w http.ResponseWriter, r *http.Reque
 		http.Error(w, "Failed to issue credential: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	err = recordCredential(h.db, respSchema.ID, appPolicy.ApplicationDID, appPolicy.ApplicationDID, appPolicy.SchemaID)
+	if err != nil {
+		http.Error(w, "Failed to save credential: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
 	policyResponse := models.ApplicationPolicyResponse{
 		ApplicationDID:    appPolicy.ApplicationDID,
 		SchemaID:          appPolicy.SchemaID,
+		PolicyType:        appPolicy.PolicyType,
 		IssuerDID:         appPolicy.IssuerDID,
 		CredentialID:      respSchema.ID,
 		CredentialSubject: respSchema.Credential.CredentialSubject,
@@ -137,6 +306,124 @@ func (h *PolicyHandler) AttachPolicyHandler(w http.ResponseWriter, r *http.Reque
 		http.Error(w, "Failed to save application policy: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	response := models.AttachPolicyResponse{ApplicationPolicyResponse: policyResponse}
+	if previous != nil && previous.SchemaID != policyResponse.SchemaID {
+		if err := h.db.DeleteIssuedPolicy(previous.ApplicationDID, previous.SchemaID); err != nil {
+			http.Error(w, "Failed to detach the previous policy: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		response.PreviousSchemaID = previous.SchemaID
+		response.StaleCredentials, err = staleCredentials(h.db, appPolicy.ApplicationDID, previous.SchemaID)
+		if err != nil {
+			http.Error(w, "Failed to get credentials: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetAttachedPoliciesHandler lists the policies attached to an application
+// @Summary Get attached policies
+// @Description Lists the policies attached to an application, at most one RBAC and one ABAC policy
+// @Tags Authorization Management
+// @Accept json
+// @Produce json
+// @Param x-api-key header string true "API Key"
+// @Param app_did query string true "Application DID"
+// @Success 200 {array} models.ApplicationPolicyResponse
+// @Failure 404 {string} string "Application Not Found"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /attached-policies [get]
+func (h *PolicyHandler) GetAttachedPoliciesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Only GET method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	appDID := r.URL.Query().Get("app_did")
+	if _, err := h.db.GetApp(appDID); err != nil {
+		http.Error(w, "app is invalid", http.StatusNotFound)
+		return
+	}
+	policies, err := services.GetAttachedPolicies(h.db, appDID)
+	if err != nil {
+		http.Error(w, "Failed to get application policies: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if policies == nil {
+		policies = []models.ApplicationPolicyResponse{}
+	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(policyResponse)
+	json.NewEncoder(w).Encode(policies)
+}
+
+// DetachPolicyHandler detaches a policy from an application
+// @Summary Detach policy from application
+// @Description Detaches a policy from an application, the other attached policies are kept. Credentials already issued to users against it are listed, they stay valid until revoked.
+// @Tags Authorization Management
+// @Accept json
+// @Produce json
+// @Param x-api-key header string true "API Key"
+// @Param policy body models.DetachPolicyRequest true "Policy to detach"
+// @Success 200 {object} models.DetachPolicyResponse "Detached policy"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 404 {string} string "Policy Not Attached"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /detach-policy [post]
+func (h *PolicyHandler) DetachPolicyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Only POST method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var validate = validator.New()
+	var detachReq models.DetachPolicyRequest
+	if err := json.NewDecoder(r.Body).Decode(&detachReq); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validate.Struct(detachReq); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	policy, err := h.db.GetIssuedPolicy(detachReq.ApplicationDID, detachReq.SchemaID)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "policy is not attached to the app", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to get application policy: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := h.db.DeleteIssuedPolicy(detachReq.ApplicationDID, detachReq.SchemaID); err != nil {
+		http.Error(w, "Failed to detach policy: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if policy.PolicyType == "" {
+		policy.PolicyType = utils.PolicyTypeFromSubject(policy.CredentialSubject)
+	}
+	response := models.DetachPolicyResponse{ApplicationPolicyResponse: *policy}
+	response.StaleCredentials, err = staleCredentials(h.db, detachReq.ApplicationDID, detachReq.SchemaID)
+	if err != nil {
+		http.Error(w, "Failed to get credentials: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// staleCredentials returns the IDs of the credentials the app issued to its users against the schema, which are
+// not revoked yet
+func staleCredentials(db store.Store, appDID, schemaID string) ([]string, error) {
+	records, err := db.GetCredentialRecordsByApp(appDID)
+	if err != nil {
+		return nil, err
+	}
+	stale := []string{}
+	for _, record := range records {
+		if record.Revoked || record.SubjectDID == appDID || record.SchemaID != schemaID {
+			continue
+		}
+		stale = append(stale, record.CredentialID)
+	}
+	return stale, nil
 }
@@ -2,21 +2,25 @@ package handlers
 
 import (
 	"authonomy/models"
+	"authonomy/pkg/providers"
 	"authonomy/services"
 	"authonomy/store"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+
+	"github.com/go-playground/validator"
 )
 
 // AuthProviderHandler handles auth-related requests
 type AuthProviderHandler struct {
 	ssiService *services.SsiClient
-	db         *store.Store
+	db         store.Store
 }
 
 // NewAuthProviderHandler creates a new instance of AuthProviderHandler
-func NewAuthProviderHandler(ssiService *services.SsiClient, db *store.Store) *AuthProviderHandler {
+func NewAuthProviderHandler(ssiService *services.SsiClient, db store.Store) *AuthProviderHandler {
 	return &AuthProviderHandler{ssiService: ssiService, db: db}
 }
 
@@ -35,19 +39,14 @@ func (h *AuthProviderHandler) GetAuthConnectorHandler(w http.ResponseWriter, r *
 		return
 	}
 
-	providerSchema, err := h.db.GetProviderSchema("facebook")
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	// hardcoded connector can be build to supported various mediums.
-	connectors := []models.AvailableProvider{
-		{
-			ProviderName:     "facebook",
-			ProviderType:     "social",
-			ProviderProtocol: "oauth2",
-			ProviderSchemaID: providerSchema.SchemaID,
-		},
+	connectors := []models.AvailableProvider{}
+	for _, provider := range providers.List() {
+		providerSchema, err := h.db.GetProviderSchema(provider.Name())
+		if err != nil {
+			http.Error(w, "Failed to get schema of provider "+provider.Name()+": "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		connectors = append(connectors, providers.Describe(provider, providerSchema.SchemaID))
 	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(connectors)
@@ -55,7 +54,7 @@ func (h *AuthProviderHandler) GetAuthConnectorHandler(w http.ResponseWriter, r *
 
 // LinkAuthProviderHandler links an authentication provider to an application
 // @Summary Link Authentication Provider
-// @Description Links an OAuth provider to an application by its DID
+// @Description Links an OAuth provider to an application by its DID, linking the same provider again replaces its configuration
 // @Tags Authentication Management
 // @Accept json
 // @Produce json
@@ -82,9 +81,8 @@ func (h *AuthProviderHandler) LinkAuthProviderHandler(w http.ResponseWriter, r *
 		http.Error(w, "app DID does not exists id: "+provider.AppDID, http.StatusInternalServerError)
 		return
 	}
-	// hardcoded for the hackathon
-	if provider.Provider.ProviderName != "facebook" {
-		http.Error(w, "currently only facebook as a provider supported ", http.StatusInternalServerError)
+	if _, err := providers.Get(provider.Provider.ProviderName); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 	provider.Config.RedirectURL = getCallbackUrl(r, provider.AppDID, provider.Provider.ProviderName)
@@ -94,21 +92,24 @@ func (h *AuthProviderHandler) LinkAuthProviderHandler(w http.ResponseWriter, r *
 		http.Error(w, "Failed to save provide details "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	// the client secret is only used server-side to exchange the authorization code
+	provider.Config.ClientSecret = ""
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(provider)
 }
 
-// LinkAuthProviderHandler links an authentication provider to an application
+// UnLinkAuthProviderHandler unlinks an authentication provider from an application
 // @Summary UnLink Authentication Provider
-// @Description Links an OAuth provider to an application by its DID
+// @Description Unlinks an OAuth provider from an application, the other providers of the application stay linked
 // @Tags Authentication Management
 // @Accept json
 // @Produce json
 // @Param x-api-key header string true "API Key"
-// @Param provider body interface{} true "Authentication Provider Details"
-// @Success 200 {object} interface{} "Successfully linked provider"
+// @Param provider body models.UnlinkAuthProviderRequest true "Provider to unlink"
+// @Success 200 {array} models.AuthProvider "Providers still linked to the application"
 // @Failure 400 {string} string "Bad Request"
+// @Failure 404 {string} string "Provider Not Linked"
 // @Failure 500 {string} string "Internal Server Error"
 // @Router /auth-provider/unlink [post]
 func (h *AuthProviderHandler) UnLinkAuthProviderHandler(w http.ResponseWriter, r *http.Request) {
@@ -116,8 +117,35 @@ func (h *AuthProviderHandler) UnLinkAuthProviderHandler(w http.ResponseWriter, r
 		http.Error(w, "Only POST method is allowed", http.StatusMethodNotAllowed)
 		return
 	}
+	var validate = validator.New()
+	var unlinkReq models.UnlinkAuthProviderRequest
+	if err := json.NewDecoder(r.Body).Decode(&unlinkReq); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validate.Struct(unlinkReq); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	err := h.db.DeleteAuthProvider(unlinkReq.AppDID, unlinkReq.Provider)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "provider is not linked to the app", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to unlink provider: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	linked, err := h.db.GetAuthProvidersByApp(unlinkReq.AppDID)
+	if err != nil {
+		http.Error(w, "Failed to get linked providers: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for i := range linked {
+		linked[i].Config.ClientSecret = ""
+	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode("implementation pending")
+	json.NewEncoder(w).Encode(linked)
 }
 
 func getCallbackUrl(r *http.Request, did, provider string) string {
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"authonomy/models"
+	"authonomy/store"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	// stateCookie binds the state of a sign-in attempt to the browser that started it
+	stateCookie = "authonomy_state"
+	// sessionCookie identifies the signed in user until the credential is issued
+	sessionCookie = "authonomy_session"
+	// authSessionTTL is how long the user has to sign in with the provider
+	authSessionTTL = 10 * time.Minute
+	// loginSessionTTL is how long the signed in user has to request the credential
+	loginSessionTTL = 15 * time.Minute
+)
+
+// setCookie sets an http only cookie, it is only sent over https when the request came over https
+func setCookie(w http.ResponseWriter, r *http.Request, name, value string, expiresAt time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		Expires:  expiresAt,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// clearCookie removes the cookie from the browser
+func clearCookie(w http.ResponseWriter, r *http.Request, name string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// loginSession returns the signed in user of the session cookie
+func loginSession(db store.Store, r *http.Request) (*models.LoginSession, error) {
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil || cookie.Value == "" {
+		return nil, fmt.Errorf("no sign in session")
+	}
+	session, err := db.GetLoginSession(cookie.Value)
+	if err != nil || time.Now().After(session.ExpiresAt) {
+		return nil, fmt.Errorf("sign in session is unknown or expired")
+	}
+	return session, nil
+}
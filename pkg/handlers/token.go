@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"authonomy/services"
+	"authonomy/store"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+// TokenHandler publishes and manages the keys access tokens are signed with
+type TokenHandler struct {
+	db     store.Store
+	issuer string
+}
+
+// NewTokenHandler creates a new instance of TokenHandler, the issuer is the URL the service is reached at
+func NewTokenHandler(db store.Store, issuer string) *TokenHandler {
+	return &TokenHandler{db: db, issuer: issuer}
+}
+
+// JWKSHandler godoc
+// @Summary Get the access token signing keys
+// @Description Publishes the public keys access tokens are verified with, as a JSON Web Key Set. It may be cached for 5 minutes, a new key is published that long before it signs. A retired key is published until the tokens it signed expired.
+// @Tags Token Management
+// @Produce json
+// @Success 200 {object} models.JWKS
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /.well-known/jwks.json [get]
+func (h *TokenHandler) JWKSHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Only GET method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	jwks, err := services.GetJWKS(h.db)
+	if err != nil {
+		http.Error(w, "Failed to get signing keys: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(int(services.JWKSMaxAge.Seconds())))
+	json.NewEncoder(w).Encode(jwks)
+}
+
+// SigningKeysHandler godoc
+// @Summary List the access token signing keys
+// @Description Lists the access token signing keys, newest first. The private keys are never returned.
+// @Tags Token Management
+// @Produce json
+// @Param x-api-key header string true "API Key"
+// @Success 200 {array} models.SigningKey
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /signing-keys [get]
+func (h *TokenHandler) SigningKeysHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Only GET method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	keys, err := services.ListSigningKeys(h.db)
+	if err != nil {
+		http.Error(w, "Failed to get signing keys: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(keys)
+}
+
+// RotateSigningKeyHandler godoc
+// @Summary Rotate the access token signing key
+// @Description Creates a new signing key for the access tokens. The key is published in the JWKS at once and signs from its activates_at on, once the cached JWKS expired. The previous key is retired then, it stays published until the tokens it signed expired.
+// @Tags Token Management
+// @Produce json
+// @Param x-api-key header string true "API Key"
+// @Success 200 {object} models.SigningKey "The new signing key"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /signing-keys/rotate [post]
+func (h *TokenHandler) RotateSigningKeyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Only POST method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	key, err := services.RotateSigningKey(h.db)
+	if err != nil {
+		http.Error(w, "Failed to rotate the signing key: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(key)
+}
+
+// RevokeTokenHandler godoc
+// @Summary Revoke an access token or a refresh token
+// @Description RFC 7009 token revocation. An app authenticates with its DID and secret, as form-urlencoded HTTP Basic credentials or as query parameters, and can only revoke the tokens issued to it. A user logs out without app credentials, holding the token is enough. A revoked access token is denied until it expires, a revoked refresh token ends its token family. Unknown, expired and already revoked tokens are answered with 200 as well.
+// @Tags Token Management
+// @Accept x-www-form-urlencoded
+// @Param app_did query string false "Application DID, unless sent as HTTP Basic credentials"
+// @Param app_secret query string false "Application secret, unless sent as HTTP Basic credentials"
+// @Param token formData string true "Access token or refresh token"
+// @Param token_type_hint formData string false "Token type hint" Enums(access_token, refresh_token)
+// @Success 200 {string} string "Revoked"
+// @Failure 400 {string} string "Bad request"
+// @Failure 401 {string} string "Invalid app credentials"
+// @Failure 403 {string} string "Token not issued to the app"
+// @Failure 500 {string} string "Internal server error"
+// @Router /revoke-token [post]
+func (h *TokenHandler) RevokeTokenHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Only POST method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	token := r.PostForm.Get("token")
+	if token == "" {
+		http.Error(w, "token is required", http.StatusBadRequest)
+		return
+	}
+	var appDID string
+	if appDid, appSecret, ok := appCredentials(r); ok {
+		app, err := h.db.GetApp(appDid)
+		if err != nil || !validAppSecret(app, appSecret) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="authonomy"`)
+			http.Error(w, "Unauthorized: app credentials are invalid", http.StatusUnauthorized)
+			return
+		}
+		appDID = app.AppDID
+	}
+	err := services.RevokeToken(h.db, h.issuer, appDID, token, r.PostForm.Get("token_type_hint"))
+	if errors.Is(err, services.ErrTokenNotIssuedToApp) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to revoke the token: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+}
@@ -1,53 +1,112 @@
 package providers
 
 import (
-	"authonomy/pkg/utils"
+	"authonomy/models"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
 )
 
-type FacebookUserInfo struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
+const (
+	facebookGraphURL = "https://graph.facebook.com/v14.0"
+	facebookAuthURL  = "https://www.facebook.com/v14.0/dialog/oauth"
+	facebookTokenURL = facebookGraphURL + "/oauth/access_token"
+)
+
+var facebookClient = &http.Client{Timeout: 10 * time.Second}
+
+func init() {
+	Register(&Facebook{})
 }
 
-// GetUserInfo gets the user info from the facebook provided access token.
-func GetUserInfo(provider, accessToken string) (*FacebookUserInfo, error) {
-	if provider != "facebook" {
-		// Handle other providers or return an error
-		return nil, fmt.Errorf("unsupported provider: %s", provider)
-	}
-	url := fmt.Sprintf("https://graph.facebook.com/v14.0/me?access_token=%s", accessToken)
-	fmt.Println("url is", url)
-	fmt.Println("provider is", provider)
-	fmt.Println("accessToken is", accessToken)
-	// Use SendHTTPRequest to make the API call
-	httpResponse, err := utils.SendHTTPRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
+// Facebook is the facebook OAuth2 social login provider
+type Facebook struct{}
+
+func (*Facebook) Name() string     { return "facebook" }
+func (*Facebook) Type() string     { return "social" }
+func (*Facebook) Protocol() string { return "oauth2" }
+
+// LoginURL builds the facebook login dialog url requesting an authorization code.
+func (*Facebook) LoginURL(config models.OAuthConfig, req AuthRequest) (string, error) {
+	params := url.Values{}
+	params.Set("client_id", config.ClientID)
+	params.Set("redirect_uri", config.RedirectURL)
+	params.Set("display", "popup")
+	params.Set("response_type", "code")
+	params.Set("auth_type", "reauthenticate")
+	if len(config.Scopes) > 0 {
+		params.Set("scope", strings.Join(config.Scopes, ","))
 	}
-	if httpResponse.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API request failed with status code: %d", httpResponse.StatusCode)
+	if req.State != "" {
+		params.Set("state", req.State)
 	}
-	responseMap, ok := httpResponse.Body.(map[string]interface{})
-	if !ok {
-		return nil, fmt.Errorf("invalid response format")
+	return endpoint(config.AuthURL, facebookAuthURL) + "?" + params.Encode(), nil
+}
+
+// Exchange trades an authorization code for a facebook access token. The client secret and the code are
+// posted in the form body, never in the url where they would end up in access logs.
+func (*Facebook) Exchange(config models.OAuthConfig, code string, _ AuthRequest) (*Token, error) {
+	form := url.Values{}
+	form.Set("client_id", config.ClientID)
+	form.Set("client_secret", config.ClientSecret)
+	form.Set("redirect_uri", config.RedirectURL)
+	form.Set("code", code)
+	httpReq, err := http.NewRequest("POST", endpoint(config.TokenURL, facebookTokenURL), strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
 	}
-	// Marshal map back to JSON bytes
-	jsonBytes, err := json.Marshal(responseMap)
+	httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	body, err := facebookJSON(httpReq)
 	if err != nil {
-		return nil, fmt.Errorf("error marshaling response: %s", err)
+		return nil, err
 	}
-	// Unmarshal JSON bytes into FacebookUserInfo struct
-	var userInfo FacebookUserInfo
-	err = json.Unmarshal(jsonBytes, &userInfo)
+	accessToken, ok := body["access_token"].(string)
+	if !ok || accessToken == "" {
+		return nil, fmt.Errorf("no access token in facebook response")
+	}
+	return &Token{AccessToken: accessToken}, nil
+}
+
+// UserInfo gets the user claims from the facebook graph api, the access token is sent in the Authorization header.
+func (*Facebook) UserInfo(_ models.OAuthConfig, token *Token, _ AuthRequest) (map[string]interface{}, error) {
+	params := url.Values{}
+	params.Set("fields", "id,name,email")
+	httpReq, err := http.NewRequest("GET", facebookGraphURL+"/me?"+params.Encode(), nil)
 	if err != nil {
-		return nil, fmt.Errorf("error unmarshaling response to struct: %s", err)
+		return nil, err
 	}
+	httpReq.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	return facebookJSON(httpReq)
+}
 
-	return &userInfo, nil
+// MapClaims maps the facebook user claims to the user info.
+func (*Facebook) MapClaims(claims map[string]interface{}) (*models.UserInfo, error) {
+	id, _ := claims["id"].(string)
+	name, _ := claims["name"].(string)
+	email, _ := claims["email"].(string)
+	if id == "" {
+		return nil, fmt.Errorf("no user id in facebook claims")
+	}
+	return &models.UserInfo{UserID: id, Name: name, Email: email}, nil
 }
-func GetLoginUrl(clientID, redirectUrl string) string {
-	return fmt.Sprintf("https://www.facebook.com/v14.0/dialog/oauth?client_id=%s&redirect_uri=%s&display=popup&response_type=token&auth_type=reauthenticate", clientID, redirectUrl)
+
+// facebookJSON sends the request and returns the JSON object of a successful response.
+func facebookJSON(req *http.Request) (map[string]interface{}, error) {
+	req.Header.Set("Accept", "application/json")
+	resp, err := facebookClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API request failed with status code: %d", resp.StatusCode)
+	}
+	var responseMap map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&responseMap); err != nil {
+		return nil, fmt.Errorf("invalid response format")
+	}
+	return responseMap, nil
 }
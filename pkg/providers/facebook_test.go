@@ -0,0 +1,49 @@
+package providers
+
+import (
+	"authonomy/models"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFacebookExchange(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			t.Errorf("token request method = %s, want POST", r.Method)
+		}
+		if r.URL.RawQuery != "" {
+			t.Errorf("token request query = %q, want none", r.URL.RawQuery)
+		}
+		if err := r.ParseForm(); err != nil {
+			t.Fatal(err)
+		}
+		if r.PostForm.Get("client_secret") != "secret" || r.PostForm.Get("code") != "code" {
+			t.Errorf("token request form = %v", r.PostForm)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "token"})
+	}))
+	defer server.Close()
+
+	config := models.OAuthConfig{ClientID: testClientID, ClientSecret: "secret", TokenURL: server.URL}
+	token, err := (&Facebook{}).Exchange(config, "code", AuthRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.AccessToken != "token" {
+		t.Errorf("access token = %q, want token", token.AccessToken)
+	}
+}
+
+func TestFacebookExchangeFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid code", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	config := models.OAuthConfig{ClientID: testClientID, ClientSecret: "secret", TokenURL: server.URL}
+	if _, err := (&Facebook{}).Exchange(config, "code", AuthRequest{}); err == nil {
+		t.Fatal("exchange succeeded with a failed token request")
+	}
+}
@@ -0,0 +1,294 @@
+package providers
+
+import (
+	"authonomy/models"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jws"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+)
+
+const (
+	oidcDiscoveryPath = "/.well-known/openid-configuration"
+	// oidcKeysTTL is how long the JWKS of the issuer is used before it is fetched again
+	oidcKeysTTL = time.Hour
+	// oidcClockSkew is the leeway accepted when validating the ID token
+	oidcClockSkew = 30 * time.Second
+)
+
+var oidcDefaultScopes = []string{"openid", "profile", "email"}
+
+// OIDCConfig configures a generic OpenID Connect provider.
+type OIDCConfig struct {
+	Name   string `mapstructure:"name"`
+	Type   string `mapstructure:"type"`
+	Issuer string `mapstructure:"issuer"`
+}
+
+// OIDCDiscovery is the subset of the openid configuration document used by the provider.
+type OIDCDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	JwksURI               string `json:"jwks_uri"`
+}
+
+// OIDC is a generic OpenID Connect provider using the authorization code flow with PKCE.
+// The endpoints are discovered from the issuer on first use.
+type OIDC struct {
+	config     OIDCConfig
+	httpClient *http.Client
+
+	mu            sync.Mutex
+	discovery     *OIDCDiscovery
+	keys          jwk.Set
+	keysFetchedAt time.Time
+}
+
+// NewOIDC creates a new OpenID Connect provider for the issuer.
+func NewOIDC(config OIDCConfig, httpClient *http.Client) (*OIDC, error) {
+	if config.Name == "" || config.Issuer == "" {
+		return nil, fmt.Errorf("oidc provider requires a name and an issuer")
+	}
+	if config.Type == "" {
+		config.Type = "social"
+	}
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	config.Issuer = strings.TrimRight(config.Issuer, "/")
+	return &OIDC{config: config, httpClient: httpClient}, nil
+}
+
+func (p *OIDC) Name() string     { return p.config.Name }
+func (p *OIDC) Type() string     { return p.config.Type }
+func (p *OIDC) Protocol() string { return "oidc" }
+
+// LoginURL builds the authorization endpoint url with the state, nonce and PKCE challenge.
+func (p *OIDC) LoginURL(config models.OAuthConfig, req AuthRequest) (string, error) {
+	discovery, err := p.Discover()
+	if err != nil {
+		return "", err
+	}
+	scopes := oidcScopes(config)
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", config.ClientID)
+	params.Set("redirect_uri", config.RedirectURL)
+	params.Set("scope", strings.Join(scopes, " "))
+	params.Set("state", req.State)
+	if req.Nonce != "" {
+		params.Set("nonce", req.Nonce)
+	}
+	if req.CodeVerifier != "" {
+		params.Set("code_challenge", req.CodeChallenge())
+		params.Set("code_challenge_method", "S256")
+	}
+	return endpoint(config.AuthURL, discovery.AuthorizationEndpoint) + "?" + params.Encode(), nil
+}
+
+// Exchange trades the authorization code for the tokens at the token endpoint.
+func (p *OIDC) Exchange(config models.OAuthConfig, code string, req AuthRequest) (*Token, error) {
+	discovery, err := p.Discover()
+	if err != nil {
+		return nil, err
+	}
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", config.RedirectURL)
+	form.Set("client_id", config.ClientID)
+	if config.ClientSecret != "" {
+		form.Set("client_secret", config.ClientSecret)
+	}
+	if req.CodeVerifier != "" {
+		form.Set("code_verifier", req.CodeVerifier)
+	}
+	resp, err := p.httpClient.PostForm(endpoint(config.TokenURL, discovery.TokenEndpoint), form)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token request failed with status code: %d", resp.StatusCode)
+	}
+	var token Token
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return nil, err
+	}
+	if token.AccessToken == "" && token.IDToken == "" {
+		return nil, fmt.Errorf("no token in %s response", p.config.Name)
+	}
+	return &token, nil
+}
+
+// UserInfo validates the ID token and merges its claims with the claims of the userinfo endpoint. An ID token
+// is required when the openid scope was requested, the user is never identified by the userinfo endpoint alone.
+func (p *OIDC) UserInfo(config models.OAuthConfig, token *Token, req AuthRequest) (map[string]interface{}, error) {
+	discovery, err := p.Discover()
+	if err != nil {
+		return nil, err
+	}
+	if token.IDToken == "" && slices.Contains(oidcScopes(config), "openid") {
+		return nil, fmt.Errorf("no ID token in %s response", p.config.Name)
+	}
+	claims := make(map[string]interface{})
+	if token.IDToken != "" {
+		if claims, err = p.ValidateIDToken(token.IDToken, config.ClientID, req.Nonce); err != nil {
+			return nil, err
+		}
+	}
+	if discovery.UserinfoEndpoint == "" || token.AccessToken == "" {
+		if len(claims) == 0 {
+			return nil, fmt.Errorf("no user claims available from %s", p.config.Name)
+		}
+		return claims, nil
+	}
+
+	httpReq, err := http.NewRequest("GET", discovery.UserinfoEndpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	var userinfo map[string]interface{}
+	if err := p.getJSON(httpReq, &userinfo); err != nil {
+		return nil, err
+	}
+	// the userinfo response must be about the user of the ID token
+	if sub, ok := claims["sub"]; ok && userinfo["sub"] != sub {
+		return nil, fmt.Errorf("userinfo subject does not match the ID token subject")
+	}
+	for key, value := range userinfo {
+		if _, ok := claims[key]; !ok {
+			claims[key] = value
+		}
+	}
+	return claims, nil
+}
+
+// MapClaims maps the standard OpenID Connect claims to the user info.
+func (p *OIDC) MapClaims(claims map[string]interface{}) (*models.UserInfo, error) {
+	sub, _ := claims["sub"].(string)
+	if sub == "" {
+		return nil, fmt.Errorf("no subject in %s claims", p.config.Name)
+	}
+	name, _ := claims["name"].(string)
+	if name == "" {
+		name, _ = claims["preferred_username"].(string)
+	}
+	email, _ := claims["email"].(string)
+	return &models.UserInfo{UserID: sub, Name: name, Email: email}, nil
+}
+
+// ValidateIDToken verifies the ID token signature against the JWKS of the issuer and validates its
+// issuer, audience, expiry and nonce. The claims of the token are returned.
+func (p *OIDC) ValidateIDToken(idToken, clientID, nonce string) (map[string]interface{}, error) {
+	discovery, err := p.Discover()
+	if err != nil {
+		return nil, err
+	}
+	keys, err := p.keySet(false)
+	if err != nil {
+		return nil, err
+	}
+	options := []jwt.ParseOption{
+		jwt.WithValidate(true),
+		jwt.WithIssuer(discovery.Issuer),
+		jwt.WithAudience(clientID),
+		jwt.WithAcceptableSkew(oidcClockSkew),
+	}
+	parsed, err := jwt.Parse([]byte(idToken), append(options, jwt.WithKeySet(keys, jws.WithInferAlgorithmFromKey(true)))...)
+	if err != nil {
+		// the issuer may have rotated its keys since they were fetched
+		if keys, err = p.keySet(true); err != nil {
+			return nil, err
+		}
+		if parsed, err = jwt.Parse([]byte(idToken), append(options, jwt.WithKeySet(keys, jws.WithInferAlgorithmFromKey(true)))...); err != nil {
+			return nil, fmt.Errorf("invalid ID token: %v", err)
+		}
+	}
+	claims, err := parsed.AsMap(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	if nonce != "" && claims["nonce"] != nonce {
+		return nil, fmt.Errorf("invalid ID token: nonce mismatch")
+	}
+	return claims, nil
+}
+
+// Discover reads the openid configuration of the issuer, the document is fetched once.
+func (p *OIDC) Discover() (*OIDCDiscovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+	httpReq, err := http.NewRequest("GET", p.config.Issuer+oidcDiscoveryPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	var discovery OIDCDiscovery
+	if err := p.getJSON(httpReq, &discovery); err != nil {
+		return nil, fmt.Errorf("discovering %s: %v", p.config.Issuer, err)
+	}
+	if strings.TrimRight(discovery.Issuer, "/") != p.config.Issuer {
+		return nil, fmt.Errorf("discovered issuer %s does not match %s", discovery.Issuer, p.config.Issuer)
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JwksURI == "" {
+		return nil, fmt.Errorf("openid configuration of %s is incomplete", p.config.Issuer)
+	}
+	p.discovery = &discovery
+	return p.discovery, nil
+}
+
+// keySet returns the JWKS of the issuer, fetching it again once it is stale or when forced.
+func (p *OIDC) keySet(refresh bool) (jwk.Set, error) {
+	discovery, err := p.Discover()
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !refresh && p.keys != nil && time.Since(p.keysFetchedAt) < oidcKeysTTL {
+		return p.keys, nil
+	}
+	keys, err := jwk.Fetch(context.Background(), discovery.JwksURI, jwk.WithHTTPClient(p.httpClient))
+	if err != nil {
+		return nil, fmt.Errorf("fetching jwks of %s: %v", p.config.Issuer, err)
+	}
+	p.keys = keys
+	p.keysFetchedAt = time.Now()
+	return keys, nil
+}
+
+// oidcScopes returns the scopes configured for the application, the default scopes otherwise.
+func oidcScopes(config models.OAuthConfig) []string {
+	if len(config.Scopes) == 0 {
+		return oidcDefaultScopes
+	}
+	return config.Scopes
+}
+
+func (p *OIDC) getJSON(req *http.Request, v interface{}) error {
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("request failed with status code: %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,236 @@
+package providers
+
+import (
+	"authonomy/models"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+)
+
+const (
+	testClientID = "client"
+	testNonce    = "nonce"
+)
+
+// mockOIDCServer serves the discovery document, JWKS, token and userinfo endpoints of an issuer
+type mockOIDCServer struct {
+	*httptest.Server
+
+	mu sync.Mutex
+	// issuer is the issuer announced in the discovery document, the server URL by default
+	issuer string
+	// key signs the ID tokens, its public key is the only one in the JWKS
+	key jwk.Key
+	// idToken is returned by the token endpoint, none when empty
+	idToken string
+}
+
+func newMockOIDCServer(t *testing.T) *mockOIDCServer {
+	t.Helper()
+	m := &mockOIDCServer{key: newSigningKey(t, "key-1")}
+	mux := http.NewServeMux()
+	mux.HandleFunc(oidcDiscoveryPath, func(w http.ResponseWriter, r *http.Request) {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		json.NewEncoder(w).Encode(OIDCDiscovery{
+			Issuer:                m.issuer,
+			AuthorizationEndpoint: m.URL + "/authorize",
+			TokenEndpoint:         m.URL + "/token",
+			UserinfoEndpoint:      m.URL + "/userinfo",
+			JwksURI:               m.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		publicKey, err := m.key.PublicKey()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		set := jwk.NewSet()
+		set.AddKey(publicKey)
+		json.NewEncoder(w).Encode(set)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		json.NewEncoder(w).Encode(Token{AccessToken: "access", IDToken: m.idToken})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer access" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"sub": "user", "email": "user@example.com"})
+	})
+	m.Server = httptest.NewServer(mux)
+	m.issuer = m.URL
+	t.Cleanup(m.Close)
+	return m
+}
+
+// rotate replaces the signing key, the JWKS only publishes the new key
+func (m *mockOIDCServer) rotate(t *testing.T, kid string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.key = newSigningKey(t, kid)
+}
+
+// sign signs an ID token with the current key of the server
+func (m *mockOIDCServer) sign(t *testing.T, issuer, audience, nonce string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return signIDToken(t, m.key, issuer, audience, nonce)
+}
+
+func (m *mockOIDCServer) setIDToken(idToken string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.idToken = idToken
+}
+
+func newSigningKey(t *testing.T, kid string) jwk.Key {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := jwk.FromRaw(rsaKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key.Set(jwk.KeyIDKey, kid)
+	key.Set(jwk.AlgorithmKey, jwa.RS256)
+	return key
+}
+
+func signIDToken(t *testing.T, key jwk.Key, issuer, audience, nonce string) string {
+	t.Helper()
+	token := jwt.New()
+	token.Set(jwt.IssuerKey, issuer)
+	token.Set(jwt.AudienceKey, audience)
+	token.Set(jwt.SubjectKey, "user")
+	token.Set(jwt.IssuedAtKey, time.Now())
+	token.Set(jwt.ExpirationKey, time.Now().Add(time.Minute))
+	token.Set("nonce", nonce)
+	token.Set("name", "User")
+	signed, err := jwt.Sign(token, jwt.WithKey(jwa.RS256, key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(signed)
+}
+
+func newTestOIDC(t *testing.T, m *mockOIDCServer) *OIDC {
+	t.Helper()
+	provider, err := NewOIDC(OIDCConfig{Name: "mock", Issuer: m.URL}, m.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return provider
+}
+
+func TestOIDCSignIn(t *testing.T) {
+	m := newMockOIDCServer(t)
+	m.setIDToken(m.sign(t, m.URL, testClientID, testNonce))
+	provider := newTestOIDC(t, m)
+	config := models.OAuthConfig{ClientID: testClientID}
+	req := AuthRequest{State: "state", Nonce: testNonce, CodeVerifier: "verifier"}
+
+	loginURL, err := provider.LoginURL(config, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(loginURL, m.URL+"/authorize?") || !strings.Contains(loginURL, "code_challenge_method=S256") {
+		t.Fatalf("unexpected login url: %s", loginURL)
+	}
+	token, err := provider.Exchange(config, "code", req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := provider.UserInfo(config, token, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err := provider.MapClaims(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.UserID != "user" || user.Name != "User" || user.Email != "user@example.com" {
+		t.Fatalf("unexpected user: %+v", user)
+	}
+}
+
+func TestOIDCDiscoveryIssuerMismatch(t *testing.T) {
+	m := newMockOIDCServer(t)
+	m.issuer = "https://other.example.com"
+	if _, err := newTestOIDC(t, m).Discover(); err == nil {
+		t.Fatal("discovery of another issuer was accepted")
+	}
+}
+
+func TestOIDCValidateIDToken(t *testing.T) {
+	m := newMockOIDCServer(t)
+	otherKey := newSigningKey(t, "key-1")
+	tests := []struct {
+		name    string
+		idToken func() string
+		nonce   string
+	}{
+		{"issuer mismatch", func() string { return m.sign(t, "https://other.example.com", testClientID, testNonce) }, testNonce},
+		{"audience mismatch", func() string { return m.sign(t, m.URL, "other-client", testNonce) }, testNonce},
+		{"bad signature", func() string { return signIDToken(t, otherKey, m.URL, testClientID, testNonce) }, testNonce},
+		{"wrong nonce", func() string { return m.sign(t, m.URL, testClientID, "other-nonce") }, testNonce},
+		{"malformed", func() string { return "not.a.token" }, testNonce},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := newTestOIDC(t, m).ValidateIDToken(tt.idToken(), testClientID, tt.nonce); err == nil {
+				t.Fatal("invalid ID token was accepted")
+			}
+		})
+	}
+}
+
+func TestOIDCKeyRotation(t *testing.T) {
+	m := newMockOIDCServer(t)
+	provider := newTestOIDC(t, m)
+	if _, err := provider.ValidateIDToken(m.sign(t, m.URL, testClientID, testNonce), testClientID, testNonce); err != nil {
+		t.Fatal(err)
+	}
+	// the cached JWKS lacks the new key, validation forces a refresh
+	m.rotate(t, "key-2")
+	if _, err := provider.ValidateIDToken(m.sign(t, m.URL, testClientID, testNonce), testClientID, testNonce); err != nil {
+		t.Fatalf("token signed with the rotated key was rejected: %v", err)
+	}
+}
+
+func TestOIDCMissingIDToken(t *testing.T) {
+	m := newMockOIDCServer(t)
+	provider := newTestOIDC(t, m)
+	config := models.OAuthConfig{ClientID: testClientID}
+	req := AuthRequest{State: "state", Nonce: testNonce}
+	token, err := provider.Exchange(config, "code", req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := provider.UserInfo(config, token, req); err == nil {
+		t.Fatal("sign in without an ID token was accepted")
+	}
+	// without the openid scope the userinfo endpoint identifies the user
+	config.Scopes = []string{"profile"}
+	if _, err := provider.UserInfo(config, token, req); err != nil {
+		t.Fatal(err)
+	}
+}
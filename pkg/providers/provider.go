@@ -0,0 +1,138 @@
+package providers
+
+import (
+	"authonomy/models"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Provider is an authentication provider users can sign in with.
+type Provider interface {
+	// Name is the unique name of the provider, e.g. facebook
+	Name() string
+	// Type is the kind of provider, e.g. social, email, phone
+	Type() string
+	// Protocol is the protocol spoken with the provider, e.g. oauth2
+	Protocol() string
+	// LoginURL builds the url the user is sent to for signing in
+	LoginURL(config models.OAuthConfig, req AuthRequest) (string, error)
+	// Exchange trades an authorization code for the provider tokens
+	Exchange(config models.OAuthConfig, code string, req AuthRequest) (*Token, error)
+	// UserInfo fetches the raw claims of the user from the provider
+	UserInfo(config models.OAuthConfig, token *Token, req AuthRequest) (map[string]interface{}, error)
+	// MapClaims maps the raw claims of the user to the user info credential
+	MapClaims(claims map[string]interface{}) (*models.UserInfo, error)
+}
+
+// AuthRequest carries the values bound to a single sign-in attempt.
+type AuthRequest struct {
+	State        string `json:"state"`
+	Nonce        string `json:"nonce,omitempty"`
+	CodeVerifier string `json:"code_verifier,omitempty"`
+}
+
+// Token holds the tokens issued by a provider.
+type Token struct {
+	AccessToken string `json:"access_token"`
+	IDToken     string `json:"id_token,omitempty"`
+}
+
+// NewAuthRequest generates the random state, nonce and PKCE code verifier of a sign-in attempt.
+func NewAuthRequest() (AuthRequest, error) {
+	var req AuthRequest
+	var err error
+	if req.State, err = randomString(32); err != nil {
+		return req, err
+	}
+	if req.Nonce, err = randomString(32); err != nil {
+		return req, err
+	}
+	if req.CodeVerifier, err = randomString(48); err != nil {
+		return req, err
+	}
+	return req, nil
+}
+
+// CodeChallenge derives the S256 PKCE code challenge from the code verifier.
+func (req AuthRequest) CodeChallenge() string {
+	hash := sha256.Sum256([]byte(req.CodeVerifier))
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
+
+// endpoint returns the endpoint configured for the application, the provider default otherwise.
+func endpoint(configured, fallback string) string {
+	if configured != "" {
+		return configured
+	}
+	return fallback
+}
+
+func randomString(size int) (string, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Provider)
+)
+
+// Register makes a provider available by its name, registering a name twice replaces the provider.
+func Register(provider Provider) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[provider.Name()] = provider
+}
+
+// Get returns the registered provider with the given name.
+func Get(name string) (Provider, error) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	provider, ok := registry[name]
+	if !ok {
+		return nil, fmt.Errorf("unsupported provider: %s", name)
+	}
+	return provider, nil
+}
+
+// List returns all registered providers sorted by name.
+func List() []Provider {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	list := make([]Provider, 0, len(registry))
+	for _, provider := range registry {
+		list = append(list, provider)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name() < list[j].Name() })
+	return list
+}
+
+// Describe returns the available provider details with the credential schema issued for it.
+func Describe(provider Provider, schemaID string) models.AvailableProvider {
+	return models.AvailableProvider{
+		ProviderName:     provider.Name(),
+		ProviderType:     provider.Type(),
+		ProviderProtocol: provider.Protocol(),
+		ProviderSchemaID: schemaID,
+	}
+}
+
+// GetUserInfo gets the user info from the provider using the provider issued tokens.
+func GetUserInfo(name string, config models.OAuthConfig, token *Token, req AuthRequest) (*models.UserInfo, error) {
+	provider, err := Get(name)
+	if err != nil {
+		return nil, err
+	}
+	claims, err := provider.UserInfo(config, token, req)
+	if err != nil {
+		return nil, err
+	}
+	return provider.MapClaims(claims)
+}
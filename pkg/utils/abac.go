@@ -0,0 +1,106 @@
+package utils
+
+import (
+	"authonomy/models"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// abacWildcard matches any value of the attribute, as long as the attribute is present.
+const abacWildcard = "*"
+
+// RulesFromSubject extracts the ABAC rules from a policy credential subject.
+func RulesFromSubject(subject interface{}) ([]models.ABACRule, error) {
+	subjectBytes, err := json.Marshal(subject)
+	if err != nil {
+		return nil, err
+	}
+	var wrapper models.RulesWrapper
+	if err := json.Unmarshal(subjectBytes, &wrapper); err != nil {
+		return nil, fmt.Errorf("rules not in expected format: %v", err)
+	}
+	return wrapper.Rules, nil
+}
+
+// PolicyTypeFromSubject tells an RBAC policy subject, declaring roles, from an ABAC one, declaring rules. It is
+// empty when the subject declares neither.
+func PolicyTypeFromSubject(subject interface{}) string {
+	subjectBytes, err := json.Marshal(subject)
+	if err != nil {
+		return ""
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(subjectBytes, &fields); err != nil {
+		return ""
+	}
+	if _, ok := fields["roles"]; ok {
+		return models.PolicyTypeRBAC
+	}
+	if _, ok := fields["rules"]; ok {
+		return models.PolicyTypeABAC
+	}
+	return ""
+}
+
+// EvaluateABAC evaluates the rules against the subject, action and resource attributes.
+// A matching deny rule overrides any matching permit rule, access is denied when no rule matches.
+func EvaluateABAC(rules []models.ABACRule, subject, action, resource map[string]interface{}) models.AuthorizeResponse {
+	decision := models.AuthorizeResponse{Decision: models.EffectDeny}
+	permitted := false
+	for _, rule := range rules {
+		if !attributesMatch(rule.SubjectAttributes, subject) ||
+			!attributesMatch(rule.ActionAttributes, action) ||
+			!attributesMatch(rule.ResourceAttributes, resource) {
+			continue
+		}
+		switch strings.ToLower(rule.Effect) {
+		case models.EffectDeny:
+			return models.AuthorizeResponse{Decision: models.EffectDeny, RuleID: rule.RuleID}
+		case models.EffectPermit:
+			// the first matching permit rule is reported, unless a deny rule matches later
+			if !permitted {
+				permitted = true
+				decision = models.AuthorizeResponse{Decision: models.EffectPermit, RuleID: rule.RuleID}
+			}
+		}
+	}
+	return decision
+}
+
+// attributesMatch checks every attribute required by the rule is present with a matching value.
+func attributesMatch(required, actual map[string]interface{}) bool {
+	for name, want := range required {
+		got, ok := actual[name]
+		if !ok || !valueMatches(want, got) {
+			return false
+		}
+	}
+	return true
+}
+
+// valueMatches compares a rule value with a request value. A list in the rule matches any of its
+// values and a list in the request matches when it contains the rule value.
+func valueMatches(want, got interface{}) bool {
+	if want == abacWildcard {
+		return true
+	}
+	if wantList, ok := want.([]interface{}); ok {
+		for _, w := range wantList {
+			if valueMatches(w, got) {
+				return true
+			}
+		}
+		return false
+	}
+	if gotList, ok := got.([]interface{}); ok {
+		for _, g := range gotList {
+			if valueMatches(want, g) {
+				return true
+			}
+		}
+		return false
+	}
+	return reflect.DeepEqual(want, got)
+}
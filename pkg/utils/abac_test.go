@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"authonomy/models"
+	"testing"
+)
+
+func TestEvaluateABAC(t *testing.T) {
+	permitRead := models.ABACRule{
+		RuleID:             "permit-read",
+		SubjectAttributes:  map[string]interface{}{"department": "sales"},
+		ActionAttributes:   map[string]interface{}{"name": "read"},
+		ResourceAttributes: map[string]interface{}{"type": "report"},
+		Effect:             models.EffectPermit,
+	}
+	permitAny := models.ABACRule{
+		RuleID:             "permit-any",
+		SubjectAttributes:  map[string]interface{}{"department": abacWildcard},
+		ActionAttributes:   map[string]interface{}{"name": []interface{}{"read", "write"}},
+		ResourceAttributes: map[string]interface{}{},
+		Effect:             models.EffectPermit,
+	}
+	denyConfidential := models.ABACRule{
+		RuleID:             "deny-confidential",
+		SubjectAttributes:  map[string]interface{}{},
+		ActionAttributes:   map[string]interface{}{},
+		ResourceAttributes: map[string]interface{}{"classification": "confidential"},
+		Effect:             "DENY",
+	}
+	sales := map[string]interface{}{"department": "sales"}
+	read := map[string]interface{}{"name": "read"}
+	report := map[string]interface{}{"type": "report"}
+
+	tests := []struct {
+		name     string
+		rules    []models.ABACRule
+		subject  map[string]interface{}
+		action   map[string]interface{}
+		resource map[string]interface{}
+		want     models.AuthorizeResponse
+	}{
+		{
+			name:  "no rules",
+			rules: nil, subject: sales, action: read, resource: report,
+			want: models.AuthorizeResponse{Decision: models.EffectDeny},
+		},
+		{
+			name:  "no matching rule",
+			rules: []models.ABACRule{permitRead}, subject: map[string]interface{}{"department": "hr"}, action: read, resource: report,
+			want: models.AuthorizeResponse{Decision: models.EffectDeny},
+		},
+		{
+			name:  "missing attribute",
+			rules: []models.ABACRule{permitRead}, subject: map[string]interface{}{}, action: read, resource: report,
+			want: models.AuthorizeResponse{Decision: models.EffectDeny},
+		},
+		{
+			name:  "matching permit",
+			rules: []models.ABACRule{permitRead}, subject: sales, action: read, resource: report,
+			want: models.AuthorizeResponse{Decision: models.EffectPermit, RuleID: "permit-read"},
+		},
+		{
+			name:  "first matching permit is reported",
+			rules: []models.ABACRule{permitAny, permitRead}, subject: sales, action: read, resource: report,
+			want: models.AuthorizeResponse{Decision: models.EffectPermit, RuleID: "permit-any"},
+		},
+		{
+			name:  "list value in the request",
+			rules: []models.ABACRule{permitRead}, subject: map[string]interface{}{"department": []interface{}{"hr", "sales"}}, action: read, resource: report,
+			want: models.AuthorizeResponse{Decision: models.EffectPermit, RuleID: "permit-read"},
+		},
+		{
+			name:  "deny after permit overrides it",
+			rules: []models.ABACRule{permitRead, denyConfidential}, subject: sales, action: read,
+			resource: map[string]interface{}{"type": "report", "classification": "confidential"},
+			want:     models.AuthorizeResponse{Decision: models.EffectDeny, RuleID: "deny-confidential"},
+		},
+		{
+			name:  "deny before permit overrides it",
+			rules: []models.ABACRule{denyConfidential, permitRead}, subject: sales, action: read,
+			resource: map[string]interface{}{"type": "report", "classification": "confidential"},
+			want:     models.AuthorizeResponse{Decision: models.EffectDeny, RuleID: "deny-confidential"},
+		},
+		{
+			name:  "unknown effect is ignored",
+			rules: []models.ABACRule{{RuleID: "audit", Effect: "audit"}}, subject: sales, action: read, resource: report,
+			want: models.AuthorizeResponse{Decision: models.EffectDeny},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := EvaluateABAC(tt.rules, tt.subject, tt.action, tt.resource)
+			if got != tt.want {
+				t.Errorf("EvaluateABAC() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
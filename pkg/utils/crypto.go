@@ -2,51 +2,72 @@ package utils
 
 import (
 	"authonomy/models"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/golang-jwt/jwt"
-	"github.com/spf13/viper"
 )
 
-var secret = viper.GetString("service.jwt_encryption_key")
-var encryptionKey = []byte(secret)
-var validity = 24 * time.Hour
+// MaxAccessTokenTTL is the longest an access token can be valid, a retired signing key is published as long.
+const MaxAccessTokenTTL = 24 * time.Hour
 
 type CustomClaims struct {
 	AppDID         string                      `json:"app_id"`
 	CredentialJWTs models.IssueOAuthCredential `json:"credential_jwts"`
+	// Confirmation is set on a token bound to a key of the user DID
+	Confirmation *models.Confirmation `json:"cnf,omitempty"`
 	jwt.StandardClaims
 }
 
-// CreateAccessToken create a access token.
-func CreateAccessToken(appDID string, credentialJWTs models.IssueOAuthCredential) (string, error) {
-	expirationTime := time.Now().Add(validity)
-	claims := CustomClaims{
-		AppDID:         appDID,
-		CredentialJWTs: credentialJWTs,
-		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: expirationTime.Unix(),
-		},
+// CreateAccessToken signs the access token claims with the Ed25519 signing key, the kid of the key is set in
+// the token header. The token is issued now and valid for ttl, iat, nbf and exp are set accordingly.
+func CreateAccessToken(key *models.SigningKey, claims CustomClaims, ttl time.Duration) (string, error) {
+	if len(key.PrivateKey) != ed25519.PrivateKeySize {
+		return "", errors.New("signing key has no Ed25519 private key")
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(encryptionKey)
-
-	if err != nil {
-		return "", err
+	if ttl <= 0 || ttl > MaxAccessTokenTTL {
+		return "", fmt.Errorf("access token ttl must be positive and at most %s", MaxAccessTokenTTL)
 	}
-
-	return tokenString, nil
+	now := time.Now()
+	claims.IssuedAt = now.Unix()
+	claims.NotBefore = now.Unix()
+	claims.ExpiresAt = now.Add(ttl).Unix()
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	token.Header["kid"] = key.KeyID
+	return token.SignedString(ed25519.PrivateKey(key.PrivateKey))
 }
 
-// ValidateAccessToken validates the access token.
-func ValidateAccessToken(tokenString string) (*CustomClaims, error) {
+// ValidateAccessToken validates an EdDSA signed access token with the public key of the kid in its header,
+// tokens signed with any other algorithm are rejected. The token must be issued by the issuer and name the app
+// it was issued for as its audience, exp, iat and nbf are checked against the current time.
+func ValidateAccessToken(tokenString, issuer string, publicKey func(kid string) (ed25519.PublicKey, error)) (*CustomClaims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &CustomClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return encryptionKey, nil
+		if token.Method != jwt.SigningMethodEdDSA {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" {
+			return nil, errors.New("token has no kid")
+		}
+		return publicKey(kid)
 	})
-
-	if claims, ok := token.Claims.(*CustomClaims); ok && token.Valid {
-		return claims, nil
-	} else {
+	if err != nil {
 		return nil, err
 	}
+	claims, ok := token.Claims.(*CustomClaims)
+	if !ok || !token.Valid {
+		return nil, errors.New("invalid access token")
+	}
+	if !claims.VerifyIssuer(issuer, true) {
+		return nil, fmt.Errorf("unexpected issuer: %s", claims.Issuer)
+	}
+	if claims.Id == "" {
+		return nil, errors.New("token has no jti")
+	}
+	if claims.Audience == "" || (claims.AppDID != "" && claims.AppDID != claims.Audience) {
+		return nil, errors.New("token has no valid audience")
+	}
+	return claims, nil
 }
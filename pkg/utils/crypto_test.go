@@ -0,0 +1,157 @@
+package utils
+
+import (
+	"authonomy/models"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+const testIssuer = "https://authonomy.test"
+
+func newTestSigningKey(t *testing.T, kid string) *models.SigningKey {
+	t.Helper()
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &models.SigningKey{KeyID: kid, Algorithm: "EdDSA", PublicKey: publicKey, PrivateKey: privateKey}
+}
+
+// publicKeys resolves the kid among the keys, like the published JWKS
+func publicKeys(keys ...*models.SigningKey) func(kid string) (ed25519.PublicKey, error) {
+	return func(kid string) (ed25519.PublicKey, error) {
+		for _, key := range keys {
+			if key.KeyID == kid {
+				return ed25519.PublicKey(key.PublicKey), nil
+			}
+		}
+		return nil, errors.New("unknown signing key")
+	}
+}
+
+func testClaims() CustomClaims {
+	return CustomClaims{
+		AppDID: "did:key:app",
+		StandardClaims: jwt.StandardClaims{
+			Id:       "token-1",
+			Issuer:   testIssuer,
+			Audience: "did:key:app",
+			Subject:  "did:key:user",
+		},
+	}
+}
+
+// signClaims signs the claims as they are, so their times and header can be made invalid
+func signClaims(t *testing.T, key *models.SigningKey, kid string, claims CustomClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(ed25519.PrivateKey(key.PrivateKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestCreateAccessToken(t *testing.T) {
+	key := newTestSigningKey(t, "key-1")
+	tests := []struct {
+		name    string
+		key     *models.SigningKey
+		ttl     time.Duration
+		wantErr bool
+	}{
+		{name: "valid", key: key, ttl: 15 * time.Minute},
+		{name: "longest ttl", key: key, ttl: MaxAccessTokenTTL},
+		{name: "zero ttl", key: key, ttl: 0, wantErr: true},
+		{name: "ttl too long", key: key, ttl: MaxAccessTokenTTL + time.Second, wantErr: true},
+		{name: "no private key", key: &models.SigningKey{KeyID: "key-1", PublicKey: key.PublicKey}, ttl: time.Minute, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := CreateAccessToken(tt.key, testClaims(), tt.ttl)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CreateAccessToken() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			claims, err := ValidateAccessToken(token, testIssuer, publicKeys(key))
+			if err != nil {
+				t.Fatalf("ValidateAccessToken() error = %v", err)
+			}
+			if got := time.Unix(claims.ExpiresAt, 0).Sub(time.Unix(claims.IssuedAt, 0)); got != tt.ttl {
+				t.Errorf("token lifetime = %s, want %s", got, tt.ttl)
+			}
+		})
+	}
+}
+
+func TestValidateAccessToken(t *testing.T) {
+	key := newTestSigningKey(t, "key-1")
+	otherKey := newTestSigningKey(t, "key-2")
+	now := time.Now()
+	withTimes := func(issuedAt, notBefore, expiresAt time.Time) CustomClaims {
+		claims := testClaims()
+		claims.IssuedAt = issuedAt.Unix()
+		claims.NotBefore = notBefore.Unix()
+		claims.ExpiresAt = expiresAt.Unix()
+		return claims
+	}
+	valid := withTimes(now, now, now.Add(time.Minute))
+	modified := func(modify func(*CustomClaims)) CustomClaims {
+		claims := valid
+		modify(&claims)
+		return claims
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{name: "valid", token: signClaims(t, key, "key-1", valid)},
+		{name: "expired", token: signClaims(t, key, "key-1", withTimes(now.Add(-time.Hour), now.Add(-time.Hour), now.Add(-time.Minute))), wantErr: true},
+		{name: "not yet valid", token: signClaims(t, key, "key-1", withTimes(now, now.Add(time.Hour), now.Add(2*time.Hour))), wantErr: true},
+		{name: "issued in the future", token: signClaims(t, key, "key-1", withTimes(now.Add(time.Hour), now, now.Add(2*time.Hour))), wantErr: true},
+		{name: "wrong issuer", token: signClaims(t, key, "key-1", modified(func(c *CustomClaims) { c.Issuer = "https://other.test" })), wantErr: true},
+		{name: "wrong audience", token: signClaims(t, key, "key-1", modified(func(c *CustomClaims) { c.Audience = "did:key:other" })), wantErr: true},
+		{name: "no audience", token: signClaims(t, key, "key-1", modified(func(c *CustomClaims) { c.Audience = "" })), wantErr: true},
+		{name: "no jti", token: signClaims(t, key, "key-1", modified(func(c *CustomClaims) { c.Id = "" })), wantErr: true},
+		{name: "no kid", token: signClaims(t, key, "", valid), wantErr: true},
+		{name: "unknown kid", token: signClaims(t, key, "key-3", valid), wantErr: true},
+		{name: "kid of another key", token: signClaims(t, key, "key-2", valid), wantErr: true},
+		{name: "signed with another key", token: signClaims(t, otherKey, "key-1", valid), wantErr: true},
+		{name: "not a token", token: "not-a-token", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := ValidateAccessToken(tt.token, testIssuer, publicKeys(key, otherKey))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateAccessToken() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && claims.Subject != "did:key:user" {
+				t.Errorf("subject = %s, want did:key:user", claims.Subject)
+			}
+		})
+	}
+}
+
+func TestValidateAccessTokenRejectsOtherAlgorithms(t *testing.T) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims())
+	token.Header["kid"] = "key-1"
+	signed, err := token.SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ValidateAccessToken(signed, testIssuer, publicKeys(newTestSigningKey(t, "key-1"))); err == nil {
+		t.Fatal("ValidateAccessToken() accepted an HS256 token")
+	}
+}
@@ -3,8 +3,11 @@ package utils
 import (
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/rand"
 	"crypto/sha256"
 	"errors"
+
+	"golang.org/x/crypto/bcrypt"
 )
 
 // generateEncryptionKey generates an encryption key from the given config key.
@@ -13,7 +16,7 @@ func GenerateEncryptionKey(configKey string) []byte {
 	return hash[:]
 }
 
-// encryptData encrypts the given data using AES.
+// encryptData encrypts the given data using AES-GCM, the random nonce is prepended to the ciphertext.
 func EncryptData(data []byte, key []byte) ([]byte, error) {
 	block, err := aes.NewCipher(key)
 	if err != nil {
@@ -26,6 +29,9 @@ func EncryptData(data []byte, key []byte) ([]byte, error) {
 	}
 
 	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
 	return gcm.Seal(nonce, nonce, data, nil), nil
 }
 
@@ -49,3 +55,20 @@ func DecryptData(data []byte, key []byte) ([]byte, error) {
 	nonce, ciphertext := data[:nonceSize], data[nonceSize:]
 	return gcm.Open(nil, nonce, ciphertext, nil)
 }
+
+// HashSecret hashes the secret with bcrypt, so a leaked database does not reveal it.
+func HashSecret(secret string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// CheckSecret reports whether the secret matches the bcrypt hash, the comparison is constant time.
+func CheckSecret(hash, secret string) bool {
+	if hash == "" || secret == "" {
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(secret)) == nil
+}
@@ -2,14 +2,18 @@ package utils
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/TBD54566975/ssi-sdk/credential"
 	"github.com/TBD54566975/ssi-sdk/crypto/jwx"
+	"github.com/TBD54566975/ssi-sdk/did/key"
+	"github.com/TBD54566975/ssi-sdk/did/resolution"
 	"github.com/lestrrat-go/jwx/v2/jws"
 	"github.com/lestrrat-go/jwx/v2/jwt"
 	"github.com/pkg/errors"
@@ -17,6 +21,8 @@ import (
 
 const (
 	VCJWTProperty string = "vc"
+	// clockSkew is the leeway accepted when validating exp and nbf claims
+	clockSkew = 30 * time.Second
 )
 
 type HTTPResponse struct {
@@ -65,7 +71,7 @@ func SendHTTPRequest(method, url string, body *bytes.Buffer) (*HTTPResponse, err
 	}, nil
 }
 
-// ParseVerifiableCredentialFromJWT parse the credential from the JWT.
+// ParseVerifiableCredentialFromJWT parse the credential from the JWT without verifying it.
 // this code it taken from the tbd web5 ssi service.
 func ParseVerifiableCredentialFromJWT(token string) (jws.Headers, jwt.Token, *credential.VerifiableCredential, error) {
 	parsed, err := jwt.Parse([]byte(token), jwt.WithValidate(false), jwt.WithVerify(false))
@@ -88,6 +94,41 @@ func ParseVerifiableCredentialFromJWT(token string) (jws.Headers, jwt.Token, *cr
 	return headers, parsed, cred, nil
 }
 
+// VerifyVerifiableCredentialFromJWT verifies the signature of the credential JWT with the key of its issuer,
+// validates the exp and nbf claims and parses the credential from the JWT.
+// Only did:key issuers are supported, they are resolved locally without a network call.
+func VerifyVerifiableCredentialFromJWT(token string) (jws.Headers, jwt.Token, *credential.VerifiableCredential, error) {
+	headers, parsed, cred, err := ParseVerifiableCredentialFromJWT(token)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	issuer := parsed.Issuer()
+	if !strings.HasPrefix(issuer, key.Prefix+":") {
+		return nil, nil, nil, fmt.Errorf("unsupported issuer DID method: %s", issuer)
+	}
+	kid := headers.KeyID()
+	if kid == "" {
+		return nil, nil, nil, errors.New("credential token has no kid header")
+	}
+	pubKey, err := resolution.ResolveKeyForDID(context.Background(), key.Resolver{}, issuer, kid)
+	if err != nil {
+		return nil, nil, nil, errors.Wrap(err, "resolving issuer key")
+	}
+	verifier, err := jwx.NewJWXVerifier(issuer, kid, pubKey)
+	if err != nil {
+		return nil, nil, nil, errors.Wrap(err, "creating verifier")
+	}
+	if err := verifier.Verify(token); err != nil {
+		return nil, nil, nil, errors.Wrap(err, "verifying credential signature")
+	}
+	if err := jwt.Validate(parsed, jwt.WithAcceptableSkew(clockSkew)); err != nil {
+		return nil, nil, nil, errors.Wrap(err, "validating credential token")
+	}
+
+	return headers, parsed, cred, nil
+}
+
 // ParseVerifiableCredentialFromToken takes a JWT object and parses it into a VerifiableCredential
 func ParseVerifiableCredentialFromToken(token jwt.Token) (*credential.VerifiableCredential, error) {
 	// parse remaining JWT properties and set in the credential
@@ -0,0 +1,127 @@
+package utils
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/TBD54566975/ssi-sdk/crypto/jwx"
+	"github.com/TBD54566975/ssi-sdk/did/key"
+	"github.com/TBD54566975/ssi-sdk/did/resolution"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/pkg/errors"
+)
+
+const (
+	// ProofType is the typ header of a proof of possession (RFC 9449)
+	ProofType = "dpop+jwt"
+	// proofMaxAge is how long after its iat a proof of possession is accepted
+	proofMaxAge = time.Minute
+)
+
+// HolderProof is a verified proof of possession
+type HolderProof struct {
+	// KeyID is the DID URL of the key the proof is signed with
+	KeyID string
+	// ID identifies the proof for the replay check, the RFC 7638 thumbprint of the key and the jti
+	ID string
+	// ExpiresAt is when the proof is no longer accepted, its ID has to be remembered until then
+	ExpiresAt time.Time
+}
+
+// VerifyHolderProof verifies a DPoP-style proof of possession (RFC 9449) of a key of the holder DID. The proof is
+// a JWT of type dpop+jwt signed with a key of the did:key holder, the kid header names the key by its DID URL or
+// its fragment. It names the method and URL of the request (htm, htu), carries a jti, is issued less than a minute
+// ago (iat) and, when an access token is presented, carries its hash (ath). The caller rejects a replayed proof by
+// its ID.
+func VerifyHolderProof(proof, holderDID, method, url, accessToken string) (*HolderProof, error) {
+	if !strings.HasPrefix(holderDID, key.Prefix+":") {
+		return nil, fmt.Errorf("unsupported holder DID method: %s", holderDID)
+	}
+	headers, err := jwx.GetJWSHeaders([]byte(proof))
+	if err != nil {
+		return nil, errors.Wrap(err, "getting proof headers")
+	}
+	if headers.Type() != ProofType {
+		return nil, fmt.Errorf("proof type must be %s", ProofType)
+	}
+	// the kid is the DID URL of the key, or its fragment relative to the holder DID
+	did, fragment, found := strings.Cut(headers.KeyID(), "#")
+	if !found || fragment == "" || (did != "" && did != holderDID) {
+		return nil, errors.New("proof is not signed with a key of the holder DID")
+	}
+	kid := holderDID + "#" + fragment
+	pubKey, err := resolution.ResolveKeyForDID(context.Background(), key.Resolver{}, holderDID, "#"+fragment)
+	if err != nil {
+		return nil, errors.Wrap(err, "resolving holder key")
+	}
+	verifier, err := jwx.NewJWXVerifier(holderDID, kid, pubKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating verifier")
+	}
+	if err := verifier.Verify(proof); err != nil {
+		return nil, errors.Wrap(err, "verifying proof signature")
+	}
+	parsed, err := jwt.Parse([]byte(proof), jwt.WithValidate(false), jwt.WithVerify(false))
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing proof")
+	}
+	if htm, _ := parsed.Get("htm"); htm != method {
+		return nil, errors.New("proof was made for another method")
+	}
+	htu, _ := parsed.Get("htu")
+	if htuString, _ := htu.(string); stripQuery(htuString) != url {
+		return nil, errors.New("proof was made for another URL")
+	}
+	if parsed.JwtID() == "" {
+		return nil, errors.New("proof has no jti")
+	}
+	issuedAt := parsed.IssuedAt()
+	if issuedAt.IsZero() || time.Since(issuedAt) > proofMaxAge || time.Until(issuedAt) > clockSkew {
+		return nil, errors.New("proof is not fresh")
+	}
+	if accessToken != "" {
+		hash := sha256.Sum256([]byte(accessToken))
+		if ath, _ := parsed.Get("ath"); ath != base64.RawURLEncoding.EncodeToString(hash[:]) {
+			return nil, errors.New("proof was made for another access token")
+		}
+	}
+	publicJWK, err := jwx.PublicKeyToPublicKeyJWK(kid, pubKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "converting holder key")
+	}
+	thumbprint, err := jwkThumbprint(publicJWK)
+	if err != nil {
+		return nil, err
+	}
+	return &HolderProof{KeyID: kid, ID: thumbprint + "." + parsed.JwtID(), ExpiresAt: issuedAt.Add(proofMaxAge)}, nil
+}
+
+// jwkThumbprint is the RFC 7638 thumbprint of the key: the SHA-256 hash of its required members, in
+// lexicographic order and without whitespace
+func jwkThumbprint(publicJWK *jwx.PublicKeyJWK) (string, error) {
+	members := map[string]string{"kty": publicJWK.KTY}
+	for name, value := range map[string]string{"crv": publicJWK.CRV, "e": publicJWK.E, "n": publicJWK.N, "x": publicJWK.X, "y": publicJWK.Y} {
+		if value != "" {
+			members[name] = value
+		}
+	}
+	// the keys of a map are marshalled in order
+	membersJSON, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(membersJSON)
+	return base64.RawURLEncoding.EncodeToString(hash[:]), nil
+}
+
+// stripQuery drops the query and fragment of a URL, the htu of a proof is compared without them
+func stripQuery(url string) string {
+	url, _, _ = strings.Cut(url, "#")
+	url, _, _ = strings.Cut(url, "?")
+	return url
+}
@@ -0,0 +1,175 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TBD54566975/ssi-sdk/crypto"
+	"github.com/TBD54566975/ssi-sdk/did/key"
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jws"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+)
+
+const (
+	testProofMethod = "POST"
+	testProofURL    = "https://authonomy.test/get-access-token"
+)
+
+// testHolder is a did:key holder signing proofs of possession
+type testHolder struct {
+	did        string
+	fragment   string
+	privateKey interface{}
+}
+
+func newTestHolder(t *testing.T) *testHolder {
+	t.Helper()
+	privateKey, didKey, err := key.GenerateDIDKey(crypto.Ed25519)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := didKey.Expand()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, fragment, _ := strings.Cut(doc.VerificationMethod[0].ID, "#")
+	return &testHolder{did: didKey.String(), fragment: fragment, privateKey: privateKey}
+}
+
+// proofClaims are the claims of a valid proof for the test request
+func proofClaims() map[string]interface{} {
+	return map[string]interface{}{
+		"htm": testProofMethod,
+		"htu": testProofURL,
+		"jti": "proof-1",
+		"iat": time.Now().Unix(),
+	}
+}
+
+// sign signs the claims as a proof, typ and kid are set in the header when not empty
+func (h *testHolder) sign(t *testing.T, typ, kid string, claims map[string]interface{}) string {
+	t.Helper()
+	token := jwt.New()
+	for name, value := range claims {
+		if err := token.Set(name, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	headers := jws.NewHeaders()
+	if typ != "" {
+		headers.Set(jws.TypeKey, typ)
+	}
+	if kid != "" {
+		headers.Set(jws.KeyIDKey, kid)
+	}
+	signed, err := jwt.Sign(token, jwt.WithKey(jwa.EdDSA, h.privateKey, jws.WithProtectedHeaders(headers)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(signed)
+}
+
+func (h *testHolder) proof(t *testing.T, claims map[string]interface{}) string {
+	return h.sign(t, ProofType, h.did+"#"+h.fragment, claims)
+}
+
+func TestVerifyHolderProof(t *testing.T) {
+	holder := newTestHolder(t)
+	other := newTestHolder(t)
+	with := func(name string, value interface{}) map[string]interface{} {
+		claims := proofClaims()
+		if value == nil {
+			delete(claims, name)
+		} else {
+			claims[name] = value
+		}
+		return claims
+	}
+	hash := sha256.Sum256([]byte("access-token"))
+	ath := base64.RawURLEncoding.EncodeToString(hash[:])
+
+	tests := []struct {
+		name        string
+		proof       string
+		holderDID   string
+		accessToken string
+		wantErr     bool
+	}{
+		{name: "valid", proof: holder.proof(t, proofClaims())},
+		{name: "kid fragment", proof: holder.sign(t, ProofType, "#"+holder.fragment, proofClaims())},
+		{name: "query ignored", proof: holder.proof(t, with("htu", testProofURL+"?app_did=did:key:app"))},
+		{name: "access token hash", proof: holder.proof(t, with("ath", ath)), accessToken: "access-token"},
+		{name: "wrong htm", proof: holder.proof(t, with("htm", "GET")), wantErr: true},
+		{name: "no htm", proof: holder.proof(t, with("htm", nil)), wantErr: true},
+		{name: "wrong htu", proof: holder.proof(t, with("htu", "https://authonomy.test/refresh-token")), wantErr: true},
+		{name: "htu of another host", proof: holder.proof(t, with("htu", "https://other.test/get-access-token")), wantErr: true},
+		{name: "stale iat", proof: holder.proof(t, with("iat", time.Now().Add(-2*proofMaxAge).Unix())), wantErr: true},
+		{name: "iat in the future", proof: holder.proof(t, with("iat", time.Now().Add(time.Hour).Unix())), wantErr: true},
+		{name: "no iat", proof: holder.proof(t, with("iat", nil)), wantErr: true},
+		{name: "no jti", proof: holder.proof(t, with("jti", nil)), wantErr: true},
+		{name: "wrong access token hash", proof: holder.proof(t, with("ath", ath)), accessToken: "other-token", wantErr: true},
+		{name: "no access token hash", proof: holder.proof(t, proofClaims()), accessToken: "access-token", wantErr: true},
+		{name: "wrong typ", proof: holder.sign(t, "JWT", holder.did+"#"+holder.fragment, proofClaims()), wantErr: true},
+		{name: "no kid", proof: holder.sign(t, ProofType, "", proofClaims()), wantErr: true},
+		{name: "key of another DID", proof: other.proof(t, proofClaims()), wantErr: true},
+		{name: "signed by another key", proof: other.sign(t, ProofType, holder.did+"#"+holder.fragment, proofClaims()), wantErr: true},
+		{name: "unsupported DID method", proof: holder.proof(t, proofClaims()), holderDID: "did:web:authonomy.test", wantErr: true},
+		{name: "not a proof", proof: "not-a-proof", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			holderDID := tt.holderDID
+			if holderDID == "" {
+				holderDID = holder.did
+			}
+			got, err := VerifyHolderProof(tt.proof, holderDID, testProofMethod, testProofURL, tt.accessToken)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("VerifyHolderProof() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.KeyID != holder.did+"#"+holder.fragment {
+				t.Errorf("key ID = %s, want %s", got.KeyID, holder.did+"#"+holder.fragment)
+			}
+			if !strings.HasSuffix(got.ID, ".proof-1") {
+				t.Errorf("proof ID = %s, want the jti proof-1 after the key thumbprint", got.ID)
+			}
+		})
+	}
+}
+
+// A replayed proof has the ID of the first one, so it is rejected by the caller remembering the IDs. Another
+// jti or another key gives another ID.
+func TestVerifyHolderProofReplayID(t *testing.T) {
+	holder := newTestHolder(t)
+	other := newTestHolder(t)
+	verify := func(holderDID, proof string) *HolderProof {
+		t.Helper()
+		holderProof, err := VerifyHolderProof(proof, holderDID, testProofMethod, testProofURL, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return holderProof
+	}
+	proof := holder.proof(t, proofClaims())
+	first := verify(holder.did, proof)
+	if replayed := verify(holder.did, proof); replayed.ID != first.ID {
+		t.Errorf("replayed proof ID = %s, want %s", replayed.ID, first.ID)
+	}
+	claims := proofClaims()
+	claims["jti"] = "proof-2"
+	if next := verify(holder.did, holder.proof(t, claims)); next.ID == first.ID {
+		t.Error("proof with another jti has the same ID")
+	}
+	if otherKey := verify(other.did, other.proof(t, proofClaims())); otherKey.ID == first.ID {
+		t.Error("proof of another key with the same jti has the same ID")
+	}
+	if !first.ExpiresAt.After(time.Now()) || first.ExpiresAt.After(time.Now().Add(proofMaxAge)) {
+		t.Errorf("proof expires at %s, want within %s", first.ExpiresAt, proofMaxAge)
+	}
+}
@@ -1,31 +1,160 @@
 package utils
 
 import (
+	"authonomy/models"
+	"encoding/json"
 	"fmt"
-
-	"github.com/TBD54566975/ssi-sdk/credential"
+	"slices"
+	"sort"
+	"strings"
 )
 
-// IsRoleExists check the role exists on RBAC or not.
-func IsRoleExists(cred credential.CredentialSubject, r string) bool {
-	// Extract the roles
-	roles, ok := cred["roles"].([]any)
-	if !ok {
-		fmt.Println("roles not found or not in expected format")
-		return false
+// RolesFromSubject extracts the RBAC roles from a policy credential subject.
+func RolesFromSubject(subject interface{}) ([]models.Role, error) {
+	subjectBytes, err := json.Marshal(subject)
+	if err != nil {
+		return nil, err
+	}
+	var wrapper models.RolesWrapper
+	if err := json.Unmarshal(subjectBytes, &wrapper); err != nil {
+		return nil, fmt.Errorf("roles not in expected format: %v", err)
 	}
+	return wrapper.Roles, nil
+}
 
-	for _, roleInterface := range roles {
-		role, ok := roleInterface.(map[string]any)
-		if !ok {
-			fmt.Println("role is not in expected format")
-			continue
+// FindRole returns the role with the given name.
+func FindRole(roles []models.Role, roleName string) (models.Role, bool) {
+	for _, role := range roles {
+		if role.RoleName == roleName {
+			return role, true
 		}
+	}
+	return models.Role{}, false
+}
 
-		roleName, ok := role["roleName"].(string)
-		if ok {
-			return (roleName == r)
+// IsPermissionDefined checks if any of the roles carries the permission.
+func IsPermissionDefined(roles []models.Role, permission string) bool {
+	for _, role := range roles {
+		for _, p := range role.Permissions {
+			if p == permission {
+				return true
+			}
 		}
 	}
 	return false
 }
+
+// Permissions returns the permissions carried by the roles, each once and sorted.
+func Permissions(roles []models.Role) []string {
+	var permissions []string
+	for _, role := range roles {
+		for _, p := range role.Permissions {
+			if !slices.Contains(permissions, p) {
+				permissions = append(permissions, p)
+			}
+		}
+	}
+	sort.Strings(permissions)
+	return permissions
+}
+
+// HasPermissions checks the permissions against the permissions of all roles. With requireAll every
+// permission must be carried by some role, otherwise a single one is enough.
+func HasPermissions(roles []models.Role, permissions []string, requireAll bool) bool {
+	if len(permissions) == 0 {
+		return false
+	}
+	for _, permission := range permissions {
+		defined := IsPermissionDefined(roles, permission)
+		if defined && !requireAll {
+			return true
+		}
+		if !defined && requireAll {
+			return false
+		}
+	}
+	return requireAll
+}
+
+// ValidateRoleHierarchy checks every inherited role is defined and the inheritance has no cycle.
+func ValidateRoleHierarchy(roles []models.Role) error {
+	definitions := make(map[string]models.Role, len(roles))
+	for _, role := range roles {
+		definitions[role.RoleName] = role
+	}
+	for _, role := range roles {
+		for _, parent := range role.Inherits {
+			if _, ok := definitions[parent]; !ok {
+				return fmt.Errorf("role %s inherits undefined role %s", role.RoleName, parent)
+			}
+		}
+	}
+
+	// depth first search, a role found again on the current path closes a cycle
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[string]int, len(roles))
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		path = append(path, name)
+		switch state[name] {
+		case visiting:
+			return fmt.Errorf("role inheritance cycle: %s", strings.Join(path, " -> "))
+		case visited:
+			return nil
+		}
+		state[name] = visiting
+		for _, parent := range definitions[name].Inherits {
+			if err := visit(parent, path); err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+		return nil
+	}
+	for _, role := range roles {
+		if err := visit(role.RoleName, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EffectiveRoles returns the roles of the user together with every role they inherit. Inherited roles
+// are resolved from the application roles, falling back to the user roles, and each role is returned once.
+func EffectiveRoles(userRoles, appRoles []models.Role) []models.Role {
+	definitions := make(map[string]models.Role, len(userRoles)+len(appRoles))
+	for _, role := range userRoles {
+		definitions[role.RoleName] = role
+	}
+	for _, role := range appRoles {
+		definitions[role.RoleName] = role
+	}
+
+	seen := make(map[string]bool)
+	var effective []models.Role
+	var queue []string
+	for _, role := range userRoles {
+		if seen[role.RoleName] {
+			continue
+		}
+		seen[role.RoleName] = true
+		effective = append(effective, role)
+		queue = append(queue, role.Inherits...)
+		queue = append(queue, definitions[role.RoleName].Inherits...)
+	}
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		role, ok := definitions[name]
+		if !ok || seen[name] {
+			continue
+		}
+		seen[name] = true
+		effective = append(effective, role)
+		queue = append(queue, role.Inherits...)
+	}
+	return effective
+}
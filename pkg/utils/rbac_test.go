@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"authonomy/models"
+	"slices"
+	"testing"
+)
+
+func role(name string, inherits ...string) models.Role {
+	return models.Role{RoleName: name, Permissions: []string{name + ":read"}, Inherits: inherits}
+}
+
+func roleNames(roles []models.Role) []string {
+	var names []string
+	for _, role := range roles {
+		names = append(names, role.RoleName)
+	}
+	return names
+}
+
+func TestValidateRoleHierarchy(t *testing.T) {
+	tests := []struct {
+		name    string
+		roles   []models.Role
+		wantErr bool
+	}{
+		{name: "no roles"},
+		{name: "flat", roles: []models.Role{role("admin"), role("user")}},
+		{name: "chain", roles: []models.Role{role("admin", "editor"), role("editor", "user"), role("user")}},
+		{name: "diamond", roles: []models.Role{role("admin", "editor", "viewer"), role("editor", "user"), role("viewer", "user"), role("user")}},
+		{name: "undefined parent", roles: []models.Role{role("admin", "editor")}, wantErr: true},
+		{name: "self inheritance", roles: []models.Role{role("admin", "admin")}, wantErr: true},
+		{name: "two role cycle", roles: []models.Role{role("admin", "editor"), role("editor", "admin")}, wantErr: true},
+		{name: "longer cycle", roles: []models.Role{role("admin", "editor"), role("editor", "user"), role("user", "admin")}, wantErr: true},
+		{name: "cycle below an acyclic role", roles: []models.Role{role("owner", "admin"), role("admin", "editor"), role("editor", "admin")}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateRoleHierarchy(tt.roles)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateRoleHierarchy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEffectiveRoles(t *testing.T) {
+	appRoles := []models.Role{role("admin", "editor"), role("editor", "user"), role("user")}
+	tests := []struct {
+		name      string
+		userRoles []models.Role
+		appRoles  []models.Role
+		want      []string
+	}{
+		{name: "no roles"},
+		{name: "leaf role", userRoles: []models.Role{role("user")}, appRoles: appRoles, want: []string{"user"}},
+		{name: "transitive inheritance", userRoles: []models.Role{role("admin")}, appRoles: appRoles, want: []string{"admin", "editor", "user"}},
+		{name: "roles returned once", userRoles: []models.Role{role("admin"), role("user"), role("admin")}, appRoles: appRoles, want: []string{"admin", "user", "editor"}},
+		{name: "inheritance of the user role", userRoles: []models.Role{role("admin", "editor")}, want: []string{"admin"}},
+		{name: "undefined parent is skipped", userRoles: []models.Role{role("admin", "ghost")}, appRoles: appRoles, want: []string{"admin", "editor", "user"}},
+		{name: "self inheritance", userRoles: []models.Role{role("admin", "admin")}, appRoles: []models.Role{role("admin", "admin")}, want: []string{"admin"}},
+		{
+			name:      "cycle terminates",
+			userRoles: []models.Role{role("admin")},
+			appRoles:  []models.Role{role("admin", "editor"), role("editor", "admin")},
+			want:      []string{"admin", "editor"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := roleNames(EffectiveRoles(tt.userRoles, tt.appRoles))
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("EffectiveRoles() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package services
+
+import (
+	"authonomy/models"
+	"authonomy/store"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ErrInvalidAPIKey is returned when the API key is unknown, malformed or revoked
+var ErrInvalidAPIKey = errors.New("invalid API key")
+
+// ErrScopeNotHeld is returned when an API key would grant a scope it does not hold itself
+var ErrScopeNotHeld = errors.New("scope not held by the API key")
+
+// CreateAPIKey creates an admin API key with the given scopes. The key is returned once, only its
+// hash is stored. A key has the form <key id>.<secret>.
+func CreateAPIKey(db store.Store, name string, scopes []string) (*models.CreateAPIKeyResponse, error) {
+	if name == "" {
+		return nil, errors.New("name is required")
+	}
+	if len(scopes) == 0 {
+		return nil, errors.New("at least one scope is required")
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(models.Scopes, scope) {
+			return nil, fmt.Errorf("unknown scope: %s", scope)
+		}
+	}
+	keyID, err := randomString(8, hex.EncodeToString)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := randomString(32, base64.RawURLEncoding.EncodeToString)
+	if err != nil {
+		return nil, err
+	}
+	apiKey := models.APIKey{
+		KeyID:     keyID,
+		Name:      name,
+		Scopes:    scopes,
+		KeyHash:   hashAPIKeySecret(secret),
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := db.SetAPIKey(apiKey); err != nil {
+		return nil, err
+	}
+	apiKey.KeyHash = ""
+	return &models.CreateAPIKeyResponse{APIKey: apiKey, Key: keyID + "." + secret}, nil
+}
+
+// GrantAPIKey creates an admin API key on behalf of the granter key. A key can only grant the scopes it
+// holds, "*" included, so it cannot be used to mint a key with more rights than its own.
+func GrantAPIKey(db store.Store, granter *models.APIKey, name string, scopes []string) (*models.CreateAPIKeyResponse, error) {
+	for _, scope := range scopes {
+		if !HasScope(granter, scope) {
+			return nil, fmt.Errorf("%w: %s", ErrScopeNotHeld, scope)
+		}
+	}
+	return CreateAPIKey(db, name, scopes)
+}
+
+// ListAPIKeys returns the admin API keys without their hashes
+func ListAPIKeys(db store.Store) ([]models.APIKey, error) {
+	keys, err := db.GetAllAPIKeys()
+	if err != nil {
+		return nil, err
+	}
+	for i := range keys {
+		keys[i].KeyHash = ""
+	}
+	return keys, nil
+}
+
+// RevokeAPIKey revokes the admin API key, revoking it again is a no-op
+func RevokeAPIKey(db store.Store, keyID string) (*models.APIKey, error) {
+	apiKey, err := db.GetAPIKey(keyID)
+	if err != nil {
+		return nil, err
+	}
+	if !apiKey.Revoked {
+		now := time.Now().UTC()
+		apiKey.Revoked = true
+		apiKey.RevokedAt = &now
+		if err := db.SetAPIKey(*apiKey); err != nil {
+			return nil, err
+		}
+	}
+	apiKey.KeyHash = ""
+	return apiKey, nil
+}
+
+// VerifyAPIKey returns the stored admin API key matching the presented key
+func VerifyAPIKey(db store.Store, key string) (*models.APIKey, error) {
+	keyID, secret, ok := strings.Cut(key, ".")
+	if !ok || keyID == "" || secret == "" {
+		return nil, ErrInvalidAPIKey
+	}
+	apiKey, err := db.GetAPIKey(keyID)
+	if err != nil {
+		return nil, ErrInvalidAPIKey
+	}
+	if apiKey.Revoked || subtle.ConstantTimeCompare([]byte(apiKey.KeyHash), []byte(hashAPIKeySecret(secret))) != 1 {
+		return nil, ErrInvalidAPIKey
+	}
+	return apiKey, nil
+}
+
+// HasScope reports whether the admin API key was granted the scope
+func HasScope(apiKey *models.APIKey, scope string) bool {
+	return slices.Contains(apiKey.Scopes, models.ScopeAll) || slices.Contains(apiKey.Scopes, scope)
+}
+
+// BootstrapAPIKey creates an admin API key with every scope when the store has no active key,
+// so a fresh deployment can be administered. It returns nil when active keys exist.
+func BootstrapAPIKey(db store.Store) (*models.CreateAPIKeyResponse, error) {
+	keys, err := db.GetAllAPIKeys()
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		if !key.Revoked {
+			return nil, nil
+		}
+	}
+	return CreateAPIKey(db, "bootstrap", []string{models.ScopeAll})
+}
+
+// hashAPIKeySecret hashes the random secret of a key. The key is checked on every admin request, so unlike the
+// app secrets it is not hashed with bcrypt: a fast hash is enough for a 256 bit random secret.
+func hashAPIKeySecret(secret string) string {
+	hash := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(hash[:])
+}
+
+func randomString(size int, encode func([]byte) string) (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return encode(b), nil
+}
@@ -0,0 +1,214 @@
+package services
+
+import (
+	"authonomy/models"
+	"authonomy/pkg/utils"
+	"authonomy/store"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ErrPolicyDeprecated is returned when a deprecated policy version is attached
+var ErrPolicyDeprecated = errors.New("policy version is deprecated")
+
+// ErrNoRBACPolicy is returned when the app has no RBAC policy attached to issue the user roles against
+var ErrNoRBACPolicy = errors.New("no RBAC policy attached to the application")
+
+// GetPolicies groups the stored policy schemas by name, the versions of a policy are ordered oldest first.
+func GetPolicies(db store.Store) ([]models.Policy, error) {
+	schemas, err := db.GetAllPolicies()
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string][]models.PolicySchemaResponse)
+	var names []string
+	for _, schema := range schemas {
+		if _, ok := byName[schema.Name]; !ok {
+			names = append(names, schema.Name)
+		}
+		byName[schema.Name] = append(byName[schema.Name], schema)
+	}
+	sort.Strings(names)
+	policies := []models.Policy{}
+	for _, name := range names {
+		versions := byName[name]
+		sortVersions(versions)
+		policies = append(policies, models.Policy{Name: name, LatestSchemaID: latestSchemaID(versions), Versions: versions})
+	}
+	return policies, nil
+}
+
+// GetPolicyVersions returns the versions of the named policy, oldest first.
+func GetPolicyVersions(db store.Store, name string) ([]models.PolicySchemaResponse, error) {
+	schemas, err := db.GetAllPolicies()
+	if err != nil {
+		return nil, err
+	}
+	var versions []models.PolicySchemaResponse
+	for _, schema := range schemas {
+		if schema.Name == name {
+			versions = append(versions, schema)
+		}
+	}
+	if len(versions) == 0 {
+		return nil, store.ErrNotFound
+	}
+	sortVersions(versions)
+	return versions, nil
+}
+
+// NextPolicyVersion returns the version the next schema of the named policy gets, 1 for a new policy.
+func NextPolicyVersion(db store.Store, name string) (int, error) {
+	versions, err := GetPolicyVersions(db, name)
+	if errors.Is(err, store.ErrNotFound) {
+		return 1, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return versions[len(versions)-1].Version + 1, nil
+}
+
+// ResolvePolicySchema returns the schema to attach: the given schema ID, or the latest version of the named
+// policy when no schema ID is given. Deprecated versions are rejected.
+func ResolvePolicySchema(db store.Store, schemaID, name string) (*models.PolicySchemaResponse, error) {
+	if schemaID == "" {
+		versions, err := GetPolicyVersions(db, name)
+		if err != nil {
+			return nil, err
+		}
+		if schemaID = latestSchemaID(versions); schemaID == "" {
+			return nil, fmt.Errorf("every version of policy %s is deprecated", name)
+		}
+	}
+	policy, err := db.GetPolicy(schemaID)
+	if err != nil {
+		return nil, err
+	}
+	if policy.Deprecated {
+		return nil, ErrPolicyDeprecated
+	}
+	return policy, nil
+}
+
+// DeprecatePolicy marks a policy version as deprecated, it can no longer be attached. Credentials already
+// issued against it stay valid. Deprecating it again is a no-op.
+func DeprecatePolicy(db store.Store, schemaID string) (*models.PolicySchemaResponse, error) {
+	policy, err := db.GetPolicy(schemaID)
+	if err != nil {
+		return nil, err
+	}
+	if !policy.Deprecated {
+		now := time.Now().UTC()
+		policy.Deprecated = true
+		policy.DeprecatedAt = &now
+		if err := db.SetPolicy(*policy); err != nil {
+			return nil, err
+		}
+	}
+	return policy, nil
+}
+
+// PolicySchemaIDs returns the schema IDs of every version of the policy the schema belongs to. A schema not
+// created through the service only has itself.
+func PolicySchemaIDs(db store.Store, schemaID string) ([]string, error) {
+	policy, err := db.GetPolicy(schemaID)
+	if errors.Is(err, store.ErrNotFound) {
+		return []string{schemaID}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	versions, err := GetPolicyVersions(db, policy.Name)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(versions))
+	for _, version := range versions {
+		ids = append(ids, version.ID)
+	}
+	return ids, nil
+}
+
+// GetAttachedPolicies returns the policies attached to the app. Policies attached before they had a type get it
+// from their credential subject.
+func GetAttachedPolicies(db store.Store, appDID string) ([]models.ApplicationPolicyResponse, error) {
+	policies, err := db.GetIssuedPoliciesByApp(appDID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range policies {
+		if policies[i].PolicyType == "" {
+			policies[i].PolicyType = utils.PolicyTypeFromSubject(policies[i].CredentialSubject)
+		}
+	}
+	return policies, nil
+}
+
+// GetAttachedPolicy returns the policy of the type attached to the app.
+func GetAttachedPolicy(db store.Store, appDID, policyType string) (*models.ApplicationPolicyResponse, error) {
+	policies, err := GetAttachedPolicies(db, appDID)
+	if err != nil {
+		return nil, err
+	}
+	for _, policy := range policies {
+		if policy.PolicyType == policyType {
+			return &policy, nil
+		}
+	}
+	return nil, store.ErrNotFound
+}
+
+// UserPolicy returns the RBAC policy the policy credentials of the app users are issued against. The credentials
+// carry roles, which do not validate against an ABAC schema, so an app without an RBAC policy gets ErrNoRBACPolicy.
+func UserPolicy(db store.Store, appDID string) (*models.ApplicationPolicyResponse, error) {
+	policy, err := GetAttachedPolicy(db, appDID, models.PolicyTypeRBAC)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, ErrNoRBACPolicy
+	}
+	return policy, err
+}
+
+// VersionPolicies numbers the policy schemas stored before versioning, after the versions already given to
+// their policy.
+func VersionPolicies(db store.Store) error {
+	schemas, err := db.GetAllPolicies()
+	if err != nil {
+		return err
+	}
+	latest := make(map[string]int)
+	for _, schema := range schemas {
+		if schema.Version > latest[schema.Name] {
+			latest[schema.Name] = schema.Version
+		}
+	}
+	for _, schema := range schemas {
+		if schema.Version != 0 {
+			continue
+		}
+		latest[schema.Name]++
+		schema.Version = latest[schema.Name]
+		if err := db.SetPolicy(schema); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sortVersions(versions []models.PolicySchemaResponse) {
+	sort.SliceStable(versions, func(i, j int) bool {
+		return versions[i].Version < versions[j].Version
+	})
+}
+
+// latestSchemaID returns the newest version not deprecated, empty when all are deprecated
+func latestSchemaID(versions []models.PolicySchemaResponse) string {
+	for i := len(versions) - 1; i >= 0; i-- {
+		if !versions[i].Deprecated {
+			return versions[i].ID
+		}
+	}
+	return ""
+}
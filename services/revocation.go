@@ -0,0 +1,96 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/TBD54566975/ssi-sdk/credential"
+	"github.com/TBD54566975/ssi-sdk/credential/status"
+)
+
+// ErrCredentialRevoked is returned when the status list of a credential marks it as revoked
+var ErrCredentialRevoked = errors.New("credential has been revoked")
+
+// defaultStatusListTTL is used when no cache ttl is configured
+const defaultStatusListTTL = time.Minute
+
+type cachedStatusList struct {
+	credential credential.VerifiableCredential
+	expiresAt  time.Time
+}
+
+// RevocationChecker resolves StatusList2021 credentials through the SSI service and caches them
+type RevocationChecker struct {
+	client *SsiClient
+	ttl    time.Duration
+	mu     sync.Mutex
+	lists  map[string]cachedStatusList
+}
+
+// NewRevocationChecker creates a new instance of RevocationChecker
+func NewRevocationChecker(client *SsiClient, ttl time.Duration) *RevocationChecker {
+	if ttl <= 0 {
+		ttl = defaultStatusListTTL
+	}
+	return &RevocationChecker{
+		client: client,
+		ttl:    ttl,
+		lists:  make(map[string]cachedStatusList),
+	}
+}
+
+// CheckRevocation returns ErrCredentialRevoked if the credential is revoked in its status list.
+// Credentials issued without a status entry are not revocable and always pass.
+func (c *RevocationChecker) CheckRevocation(cred *credential.VerifiableCredential) error {
+	if cred == nil || cred.CredentialStatus == nil {
+		return nil
+	}
+	statusBytes, err := json.Marshal(cred.CredentialStatus)
+	if err != nil {
+		return err
+	}
+	var entry status.StatusList2021Entry
+	if err := json.Unmarshal(statusBytes, &entry); err != nil {
+		return fmt.Errorf("invalid credential status: %v", err)
+	}
+	if entry.Type != status.StatusList2021EntryType || entry.StatusListCredential == "" {
+		return fmt.Errorf("unsupported credential status type: %s", entry.Type)
+	}
+	statusList, err := c.statusList(entry.StatusListCredential)
+	if err != nil {
+		return err
+	}
+	toValidate := *cred
+	toValidate.CredentialStatus = entry
+	revoked, err := status.ValidateCredentialInStatusList(toValidate, *statusList)
+	if err != nil {
+		return err
+	}
+	if revoked && entry.StatusPurpose == status.StatusRevocation {
+		return ErrCredentialRevoked
+	}
+	return nil
+}
+
+// statusList returns the cached status list credential or resolves it again once the ttl is over.
+func (c *RevocationChecker) statusList(statusListURL string) (*credential.VerifiableCredential, error) {
+	c.mu.Lock()
+	cached, ok := c.lists[statusListURL]
+	c.mu.Unlock()
+	if ok && time.Now().Before(cached.expiresAt) {
+		return &cached.credential, nil
+	}
+
+	statusList, err := c.client.GetStatusListCredential(statusListURL)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.lists[statusListURL] = cachedStatusList{credential: *statusList, expiresAt: time.Now().Add(c.ttl)}
+	c.mu.Unlock()
+	return statusList, nil
+}
@@ -0,0 +1,29 @@
+package services
+
+import (
+	"authonomy/pkg/utils"
+	"authonomy/store"
+)
+
+// HashAppSecrets replaces the app secrets stored before hashing with their hash.
+func HashAppSecrets(db store.Store) error {
+	apps, err := db.GetAllApps()
+	if err != nil {
+		return err
+	}
+	for _, app := range apps {
+		if app.AppSceret == "" {
+			continue
+		}
+		if app.SecretHash == "" {
+			if app.SecretHash, err = utils.HashSecret(app.AppSceret); err != nil {
+				return err
+			}
+		}
+		app.AppSceret = ""
+		if err := db.SetApp(app); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package services
+
+import (
+	"authonomy/models"
+	"authonomy/pkg/utils"
+	"authonomy/store"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// SigningAlgorithm is the JWS algorithm access tokens are signed with
+const SigningAlgorithm = "EdDSA"
+
+// JWKSMaxAge is how long relying parties may cache the JWKS
+const JWKSMaxAge = 5 * time.Minute
+
+// ErrUnknownSigningKey is returned for a kid that is not published
+var ErrUnknownSigningKey = errors.New("unknown signing key")
+
+// RotateSigningKey creates a new signing key. It is published at once but only signs once the cached JWKS of
+// the relying parties expired, then the keys it replaces are retired. They stay published until the tokens
+// they signed expired, so tokens issued before the rotation keep verifying. Without an active key the new
+// key signs at once.
+func RotateSigningKey(db store.Store) (*models.SigningKey, error) {
+	keys, err := db.GetAllSigningKeys()
+	if err != nil {
+		return nil, err
+	}
+	key, err := newSigningKey()
+	if err != nil {
+		return nil, err
+	}
+	if activeSigningKey(keys, key.CreatedAt) != nil {
+		key.ActivatesAt = key.CreatedAt.Add(JWKSMaxAge)
+	}
+	// the new key is stored first, tokens are never left without an active key
+	if err := db.SetSigningKey(*key); err != nil {
+		return nil, err
+	}
+	for _, old := range keys {
+		if old.RetiredAt != nil {
+			continue
+		}
+		old.RetiredAt = &key.ActivatesAt
+		if err := db.SetSigningKey(old); err != nil {
+			return nil, err
+		}
+	}
+	key.PrivateKey = nil
+	return key, nil
+}
+
+// EnsureSigningKey creates a signing key when the store has no active key. It returns nil when one exists.
+func EnsureSigningKey(db store.Store) (*models.SigningKey, error) {
+	_, err := ActiveSigningKey(db)
+	if err == nil {
+		return nil, nil
+	}
+	if !errors.Is(err, store.ErrNotFound) {
+		return nil, err
+	}
+	return RotateSigningKey(db)
+}
+
+// ActiveSigningKey returns the newest activated key that is not retired, the one access tokens are signed with.
+func ActiveSigningKey(db store.Store) (*models.SigningKey, error) {
+	keys, err := db.GetAllSigningKeys()
+	if err != nil {
+		return nil, err
+	}
+	active := activeSigningKey(keys, time.Now())
+	if active == nil {
+		return nil, store.ErrNotFound
+	}
+	return active, nil
+}
+
+// activeSigningKey returns the key that signs at the given time, nil when there is none
+func activeSigningKey(keys []models.SigningKey, at time.Time) *models.SigningKey {
+	var active *models.SigningKey
+	for i := range keys {
+		if keys[i].ActivatesAt.After(at) || (keys[i].RetiredAt != nil && !keys[i].RetiredAt.After(at)) {
+			continue
+		}
+		if active == nil || keys[i].ActivatesAt.After(active.ActivatesAt) ||
+			(keys[i].ActivatesAt.Equal(active.ActivatesAt) && keys[i].CreatedAt.After(active.CreatedAt)) {
+			active = &keys[i]
+		}
+	}
+	return active
+}
+
+// ListSigningKeys returns the signing keys without their private keys, newest first
+func ListSigningKeys(db store.Store) ([]models.SigningKey, error) {
+	keys, err := db.GetAllSigningKeys()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.After(keys[j].CreatedAt)
+	})
+	for i := range keys {
+		keys[i].PrivateKey = nil
+	}
+	return keys, nil
+}
+
+// GetJWKS returns the published public keys: the active key, the keys that will activate and the keys retired
+// less than an access token validity ago.
+func GetJWKS(db store.Store) (*models.JWKS, error) {
+	keys, err := ListSigningKeys(db)
+	if err != nil {
+		return nil, err
+	}
+	jwks := &models.JWKS{Keys: []models.JWK{}}
+	for _, key := range keys {
+		if isPublished(key) {
+			jwks.Keys = append(jwks.Keys, jwk(key.PublicKey))
+		}
+	}
+	return jwks, nil
+}
+
+// SigningPublicKey returns the lookup access tokens are validated with, it only knows the published keys.
+func SigningPublicKey(db store.Store) func(kid string) (ed25519.PublicKey, error) {
+	return func(kid string) (ed25519.PublicKey, error) {
+		key, err := db.GetSigningKey(kid)
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, ErrUnknownSigningKey
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !isPublished(*key) || len(key.PublicKey) != ed25519.PublicKeySize {
+			return nil, ErrUnknownSigningKey
+		}
+		return ed25519.PublicKey(key.PublicKey), nil
+	}
+}
+
+func newSigningKey() (*models.SigningKey, error) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	return &models.SigningKey{
+		KeyID:      jwkThumbprint(publicKey),
+		Algorithm:  SigningAlgorithm,
+		PublicKey:  publicKey,
+		PrivateKey: privateKey,
+		CreatedAt:  now,
+		// a rotation delays the activation until the JWKS publishing the key expired from the caches
+		ActivatesAt: now,
+	}, nil
+}
+
+func isPublished(key models.SigningKey) bool {
+	return key.RetiredAt == nil || time.Since(*key.RetiredAt) < utils.MaxAccessTokenTTL
+}
+
+func jwk(publicKey []byte) models.JWK {
+	return models.JWK{
+		KeyType:   "OKP",
+		Curve:     "Ed25519",
+		X:         base64.RawURLEncoding.EncodeToString(publicKey),
+		KeyID:     jwkThumbprint(publicKey),
+		Algorithm: SigningAlgorithm,
+		Use:       "sig",
+	}
+}
+
+// jwkThumbprint is the RFC 7638 thumbprint of the public key, used as its kid
+func jwkThumbprint(publicKey []byte) string {
+	members := fmt.Sprintf(`{"crv":"Ed25519","kty":"OKP","x":"%s"}`, base64.RawURLEncoding.EncodeToString(publicKey))
+	hash := sha256.Sum256([]byte(members))
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
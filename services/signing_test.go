@@ -0,0 +1,180 @@
+package services
+
+import (
+	"authonomy/pkg/utils"
+	"authonomy/store"
+	"errors"
+	"testing"
+	"time"
+)
+
+func jwksKeyIDs(t *testing.T, db store.Store) map[string]bool {
+	t.Helper()
+	jwks, err := GetJWKS(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kids := make(map[string]bool)
+	for _, key := range jwks.Keys {
+		kids[key.KeyID] = true
+	}
+	return kids
+}
+
+func TestRotateSigningKey(t *testing.T) {
+	db := store.NewMemoryStore()
+
+	first, err := RotateSigningKey(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.PrivateKey != nil {
+		t.Error("rotated key is returned with its private key")
+	}
+	if !first.ActivatesAt.Equal(first.CreatedAt) {
+		t.Errorf("first key activates at %s, want at once", first.ActivatesAt)
+	}
+	active, err := ActiveSigningKey(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if active.KeyID != first.KeyID {
+		t.Errorf("active key = %s, want the first key %s", active.KeyID, first.KeyID)
+	}
+
+	second, err := RotateSigningKey(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := second.CreatedAt.Add(JWKSMaxAge); !second.ActivatesAt.Equal(want) {
+		t.Errorf("rotated key activates at %s, want %s", second.ActivatesAt, want)
+	}
+	// the new key does not sign before the cached JWKS expired, the first key keeps signing until then
+	active, err = ActiveSigningKey(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if active.KeyID != first.KeyID {
+		t.Errorf("active key before activation = %s, want the first key %s", active.KeyID, first.KeyID)
+	}
+	retired, err := db.GetSigningKey(first.KeyID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if retired.RetiredAt == nil || !retired.RetiredAt.Equal(second.ActivatesAt) {
+		t.Errorf("first key retired at %v, want %s", retired.RetiredAt, second.ActivatesAt)
+	}
+	// the new key is published at once so relying parties cache it before it signs
+	kids := jwksKeyIDs(t, db)
+	if !kids[first.KeyID] || !kids[second.KeyID] {
+		t.Errorf("JWKS = %v, want both keys", kids)
+	}
+
+	keys, err := db.GetAllSigningKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		at   time.Time
+		want string
+	}{
+		{name: "before the rotation activates", at: second.ActivatesAt.Add(-time.Second), want: first.KeyID},
+		{name: "when the rotation activates", at: second.ActivatesAt, want: second.KeyID},
+		{name: "after the rotation activated", at: second.ActivatesAt.Add(time.Hour), want: second.KeyID},
+		{name: "before any key", at: first.CreatedAt.Add(-time.Second)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			if key := activeSigningKey(keys, tt.at); key != nil {
+				got = key.KeyID
+			}
+			if got != tt.want {
+				t.Errorf("activeSigningKey() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetJWKS(t *testing.T) {
+	now := time.Now().UTC()
+	retiredAt := func(ago time.Duration) *time.Time {
+		at := now.Add(-ago)
+		return &at
+	}
+	tests := []struct {
+		name          string
+		retiredAt     *time.Time
+		activatesAt   time.Time
+		wantPublished bool
+	}{
+		{name: "active", activatesAt: now.Add(-time.Hour), wantPublished: true},
+		{name: "activates later", activatesAt: now.Add(JWKSMaxAge), wantPublished: true},
+		{name: "retires later", activatesAt: now.Add(-time.Hour), retiredAt: retiredAt(-JWKSMaxAge), wantPublished: true},
+		{name: "just retired", activatesAt: now.Add(-time.Hour), retiredAt: retiredAt(time.Minute), wantPublished: true},
+		{name: "retired within a token validity", activatesAt: now.Add(-48 * time.Hour), retiredAt: retiredAt(utils.MaxAccessTokenTTL - time.Minute), wantPublished: true},
+		{name: "retired longer than a token validity", activatesAt: now.Add(-48 * time.Hour), retiredAt: retiredAt(utils.MaxAccessTokenTTL + time.Minute)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := store.NewMemoryStore()
+			key, err := newSigningKey()
+			if err != nil {
+				t.Fatal(err)
+			}
+			key.ActivatesAt = tt.activatesAt
+			key.RetiredAt = tt.retiredAt
+			if err := db.SetSigningKey(*key); err != nil {
+				t.Fatal(err)
+			}
+
+			if published := jwksKeyIDs(t, db)[key.KeyID]; published != tt.wantPublished {
+				t.Errorf("key published in the JWKS = %v, want %v", published, tt.wantPublished)
+			}
+			// tokens only verify with the published keys
+			_, err = SigningPublicKey(db)(key.KeyID)
+			if tt.wantPublished && err != nil {
+				t.Errorf("SigningPublicKey() error = %v", err)
+			}
+			if !tt.wantPublished && !errors.Is(err, ErrUnknownSigningKey) {
+				t.Errorf("SigningPublicKey() error = %v, want %v", err, ErrUnknownSigningKey)
+			}
+		})
+	}
+}
+
+func TestSigningPublicKeyUnknownKid(t *testing.T) {
+	db := store.NewMemoryStore()
+	if _, err := EnsureSigningKey(db); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SigningPublicKey(db)("unknown"); !errors.Is(err, ErrUnknownSigningKey) {
+		t.Errorf("SigningPublicKey() error = %v, want %v", err, ErrUnknownSigningKey)
+	}
+}
+
+func TestEnsureSigningKey(t *testing.T) {
+	db := store.NewMemoryStore()
+	created, err := EnsureSigningKey(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created == nil {
+		t.Fatal("no key created in an empty store")
+	}
+	again, err := EnsureSigningKey(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != nil {
+		t.Errorf("key %s created while %s is active", again.KeyID, created.KeyID)
+	}
+	keys, err := ListSigningKeys(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || keys[0].PrivateKey != nil {
+		t.Errorf("ListSigningKeys() = %d keys, want one without its private key", len(keys))
+	}
+}
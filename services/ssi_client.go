@@ -2,6 +2,7 @@ package services
 
 import (
 	"authonomy/models"
+	"authonomy/pkg/providers"
 	"authonomy/store"
 	"bytes"
 	"encoding/json"
@@ -10,6 +11,8 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+
+	"github.com/TBD54566975/ssi-sdk/credential"
 )
 
 // SsiClient is the client for interacting with the SSI service
@@ -30,7 +33,7 @@ func NewSsiClient(url string) *SsiClient {
 }
 
 // CreateDemoPolicies to create a demo policy for the demo.
-func CreateDemoPolicies(client *SsiClient, db *store.Store) error {
+func CreateDemoPolicies(client *SsiClient, db store.Store) error {
 	path, err := os.Getwd()
 	if err != nil {
 		return err
@@ -53,12 +56,37 @@ func CreateDemoPolicies(client *SsiClient, db *store.Store) error {
 	if err != nil {
 		return fmt.Errorf("error creating policy from file %v", err)
 	}
-	// only facebook is supported yet for the demo
-	return db.SetProviderSchema(models.ProviderSchema{ProviderName: "facebook", SchemaID: policy.ID})
+	return setProviderSchemas(db, policy.ID)
+}
+
+// SyncProviderSchemas sets the user info schema of the already configured providers
+// on the registered providers missing one, e.g. a newly configured OIDC provider.
+func SyncProviderSchemas(db store.Store) error {
+	for _, provider := range providers.List() {
+		if schema, err := db.GetProviderSchema(provider.Name()); err == nil {
+			return setProviderSchemas(db, schema.SchemaID)
+		}
+	}
+	return nil
+}
+
+// setProviderSchemas sets the user info schema on every registered provider missing one,
+// as every provider issues the same user info credential.
+func setProviderSchemas(db store.Store, schemaID string) error {
+	for _, provider := range providers.List() {
+		if _, err := db.GetProviderSchema(provider.Name()); err == nil {
+			continue
+		}
+		err := db.SetProviderSchema(models.ProviderSchema{ProviderName: provider.Name(), SchemaID: schemaID})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // createPolicyFromFile create a policy from a json file path.
-func (client *SsiClient) createPolicyFromFile(filePath string, db *store.Store) (schemaRep models.PolicySchemaResponse, err error) {
+func (client *SsiClient) createPolicyFromFile(filePath string, db store.Store) (schemaRep models.PolicySchemaResponse, err error) {
 	// Read the JSON file
 	fileBytes, err := os.ReadFile(filePath)
 	if err != nil {
@@ -136,7 +164,7 @@ func (client *SsiClient) CreatePolicy(schema models.PolicySchemaRequest) (policy
 	return policyResp, nil
 }
 
-// IssueCredentialBySchemaID issues a credential based on a schema ID
+// IssueCredentialBySchemaID issues a revocable credential based on a schema ID
 func (client *SsiClient) IssueCredentialBySchemaID(issuer, subject, schemaID string, data map[string]interface{}) (cred models.CredentialResponse, err error) {
 	credRequest := models.CredentialRequest{
 		Issuer:               issuer,
@@ -144,6 +172,7 @@ func (client *SsiClient) IssueCredentialBySchemaID(issuer, subject, schemaID str
 		Subject:              subject,
 		SchemaID:             schemaID,
 		Data:                 data,
+		Revocable:            true,
 	}
 	requestBody, err := json.Marshal(credRequest)
 	if err != nil {
@@ -173,6 +202,66 @@ func (client *SsiClient) IssueCredentialBySchemaID(issuer, subject, schemaID str
 	return credentialResp, nil
 }
 
+// RevokeCredential flips the revocation bit of a credential in its status list
+func (client *SsiClient) RevokeCredential(credentialID string) (status models.CredentialStatusResponse, err error) {
+	requestBody, err := json.Marshal(models.CredentialStatusRequest{Revoked: true})
+	if err != nil {
+		return status, err
+	}
+
+	req, err := http.NewRequest("PUT", client.serviceUrl+"/credentials/"+credentialID+"/status", bytes.NewBuffer(requestBody))
+	if err != nil {
+		return status, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.httpClient.Do(req)
+	if err != nil {
+		return status, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return status, fmt.Errorf("failed to revoke credential, status code: %d", resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return status, err
+	}
+	if !status.Revoked {
+		return status, fmt.Errorf("credential %s was not revoked", credentialID)
+	}
+
+	return status, nil
+}
+
+// GetStatusListCredential resolves a status list credential by the URL found in a credential status entry.
+// The list is always fetched from the configured SSI service, whatever host the URL was minted with.
+func (client *SsiClient) GetStatusListCredential(statusListURL string) (*credential.VerifiableCredential, error) {
+	segments := strings.Split(strings.TrimRight(statusListURL, "/"), "/")
+	statusListID := segments[len(segments)-1]
+	if statusListID == "" {
+		return nil, fmt.Errorf("invalid status list url: %s", statusListURL)
+	}
+
+	resp, err := client.httpClient.Get(client.serviceUrl + "/credentials/status/" + statusListID)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get status list, status code: %d", resp.StatusCode)
+	}
+
+	var statusListResp models.CredentialResponse
+	if err := json.NewDecoder(resp.Body).Decode(&statusListResp); err != nil {
+		return nil, err
+	}
+	if statusListResp.Credential == nil {
+		return nil, fmt.Errorf("status list %s has no credential", statusListID)
+	}
+	return statusListResp.Credential, nil
+}
+
 // // VerifyCredential verifies a credential JWT and returns the verification result
 // func (client *SsiClient) VerifyCredential(credentialJWT string) (models.VerificationResponse, error) {
 // 	// Prepare the request body
@@ -0,0 +1,354 @@
+package services
+
+import (
+	"authonomy/models"
+	"authonomy/pkg/utils"
+	"authonomy/store"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+const (
+	// DefaultAccessTokenTTL is the access token lifetime of an app that sets none
+	DefaultAccessTokenTTL = 15 * time.Minute
+	// DefaultRefreshTokenTTL is the refresh token lifetime of an app that sets none
+	DefaultRefreshTokenTTL = 30 * 24 * time.Hour
+)
+
+var (
+	// ErrInvalidAccessToken is returned for an access token that is malformed, unknown, expired or not signed
+	// with a published key
+	ErrInvalidAccessToken = errors.New("invalid access token")
+	// ErrInvalidRefreshToken is returned for a refresh token that is unknown, expired, revoked or issued for
+	// another app
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	// ErrRefreshTokenReused is returned when a used refresh token is presented again
+	ErrRefreshTokenReused = errors.New("refresh token reused, every token of its family is revoked")
+	// ErrTokenNotIssuedToApp is returned when an app revokes a token issued to another app
+	ErrTokenNotIssuedToApp = errors.New("token was not issued to the app")
+	// ErrProofReplayed is returned for a proof of possession that was already presented
+	ErrProofReplayed = errors.New("proof was already used")
+)
+
+const (
+	// token types of an issued access token, a DPoP token is bound to a key of the user DID
+	TokenTypeBearer = "Bearer"
+	TokenTypeDPoP   = "DPoP"
+)
+
+const (
+	// token type hints of a revocation request (RFC 7009)
+	TokenTypeHintAccessToken  = "access_token"
+	TokenTypeHintRefreshToken = "refresh_token"
+)
+
+// AccessTokenTTL returns the access token lifetime of the app
+func AccessTokenTTL(app *models.ApplicationResponse) time.Duration {
+	if app.AccessTokenTTL <= 0 {
+		return DefaultAccessTokenTTL
+	}
+	return min(time.Duration(app.AccessTokenTTL)*time.Second, utils.MaxAccessTokenTTL)
+}
+
+// RefreshTokenTTL returns the refresh token lifetime of the app
+func RefreshTokenTTL(app *models.ApplicationResponse) time.Duration {
+	if app.RefreshTokenTTL <= 0 {
+		return DefaultRefreshTokenTTL
+	}
+	return time.Duration(app.RefreshTokenTTL) * time.Second
+}
+
+// IssueTokens issues an access token and a refresh token starting a new token family, on sign in. The issuer
+// is the URL of the service, it is the iss of the signed access tokens.
+func IssueTokens(db store.Store, issuer string, app *models.ApplicationResponse, grant models.TokenGrant) (*models.GetAccessTokenResponse, error) {
+	familyID, err := randomString(16, hex.EncodeToString)
+	if err != nil {
+		return nil, err
+	}
+	grant.AppDID = app.AppDID
+	return issueTokens(db, issuer, app, familyID, grant)
+}
+
+// GetRefreshToken returns the record of the refresh token presented by the app. A refresh token is used once,
+// presenting it again revokes its whole family: either the client or an attacker holds a stolen token.
+func GetRefreshToken(db store.Store, app *models.ApplicationResponse, token string) (*models.RefreshToken, error) {
+	refreshToken, err := db.GetRefreshToken(hashToken(token))
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if refreshToken.AppDID != app.AppDID || refreshToken.RevokedAt != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+	if refreshToken.UsedAt != nil {
+		if err := RevokeTokenFamily(db, refreshToken.FamilyID); err != nil {
+			return nil, err
+		}
+		return nil, ErrRefreshTokenReused
+	}
+	return refreshToken, nil
+}
+
+// RotateRefreshToken uses up the refresh token and issues a new access token and refresh token in its family.
+// The token is marked used atomically, a concurrent use that got there first is a reuse and revokes the family.
+func RotateRefreshToken(db store.Store, issuer string, app *models.ApplicationResponse, refreshToken *models.RefreshToken) (*models.GetAccessTokenResponse, error) {
+	now := time.Now().UTC()
+	marked, err := db.MarkRefreshTokenUsed(refreshToken.TokenHash, now)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !marked {
+		if err := RevokeTokenFamily(db, refreshToken.FamilyID); err != nil {
+			return nil, err
+		}
+		return nil, ErrRefreshTokenReused
+	}
+	refreshToken.UsedAt = &now
+	return issueTokens(db, issuer, app, refreshToken.FamilyID, refreshToken.TokenGrant)
+}
+
+// RevokeTokenFamily revokes every refresh token of the family, revoked tokens are kept until they expire.
+func RevokeTokenFamily(db store.Store, familyID string) error {
+	tokens, err := db.GetRefreshTokensByFamily(familyID)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	for _, token := range tokens {
+		if token.RevokedAt != nil {
+			continue
+		}
+		token.RevokedAt = &now
+		if err := db.SetRefreshToken(token); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IssueAccessToken issues an access token for the grant in the format set for the app. A signed token names the
+// issuer, the app as its audience and the user DID as its subject. An opaque token is a random string, only its
+// hash is stored with the grant it stands for.
+func IssueAccessToken(db store.Store, issuer string, app *models.ApplicationResponse, grant models.TokenGrant) (string, error) {
+	tokenID, err := randomString(16, hex.EncodeToString)
+	if err != nil {
+		return "", err
+	}
+	if app.AccessTokenFormat != models.AccessTokenFormatOpaque {
+		signingKey, err := ActiveSigningKey(db)
+		if err != nil {
+			return "", fmt.Errorf("failed to get the signing key: %v", err)
+		}
+		return utils.CreateAccessToken(signingKey, utils.CustomClaims{
+			AppDID: app.AppDID,
+			CredentialJWTs: models.IssueOAuthCredential{
+				OAuthCredential:  grant.OAuthCredential,
+				PolicyCredential: grant.PolicyCredential,
+			},
+			Confirmation: grant.Confirmation,
+			StandardClaims: jwt.StandardClaims{
+				Id:       tokenID,
+				Issuer:   issuer,
+				Audience: app.AppDID,
+				Subject:  grant.SubjectDID,
+			},
+		}, AccessTokenTTL(app))
+	}
+	token, err := randomString(32, base64.RawURLEncoding.EncodeToString)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now().UTC()
+	grant.AppDID = app.AppDID
+	err = db.SetAccessToken(models.AccessToken{
+		TokenID:    tokenID,
+		TokenHash:  hashToken(token),
+		TokenGrant: grant,
+		IssuedAt:   now,
+		ExpiresAt:  now.Add(AccessTokenTTL(app)),
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// ResolveAccessToken returns what the access token stands for. A signed token is validated against the
+// published signing keys and must be issued by the issuer, an opaque token is looked up. Expired and revoked
+// tokens are rejected either way.
+func ResolveAccessToken(db store.Store, issuer, token string) (*models.AccessToken, error) {
+	accessToken, err := resolveAccessToken(db, issuer, token)
+	if err != nil {
+		return nil, err
+	}
+	_, err = db.GetRevokedToken(accessToken.TokenID)
+	if err == nil {
+		return nil, ErrInvalidAccessToken
+	}
+	if !errors.Is(err, store.ErrNotFound) {
+		return nil, err
+	}
+	return accessToken, nil
+}
+
+// RevokeToken revokes an access token or a refresh token, the hint only decides which kind is looked up first.
+// An access token is denied until it expires, a refresh token is revoked with its family. With an app DID the
+// token must have been issued to that app, without one holding the token is enough. Unknown tokens are ignored.
+func RevokeToken(db store.Store, issuer, appDID, token, hint string) error {
+	revokers := []func(store.Store, string, string, string) (bool, error){revokeAccessToken, revokeRefreshToken}
+	if hint == TokenTypeHintRefreshToken {
+		revokers = []func(store.Store, string, string, string) (bool, error){revokeRefreshToken, revokeAccessToken}
+	}
+	for _, revoke := range revokers {
+		found, err := revoke(db, issuer, appDID, token)
+		if found || err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func revokeAccessToken(db store.Store, issuer, appDID, token string) (bool, error) {
+	accessToken, err := ResolveAccessToken(db, issuer, token)
+	if errors.Is(err, ErrInvalidAccessToken) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if appDID != "" && accessToken.AppDID != appDID {
+		return true, ErrTokenNotIssuedToApp
+	}
+	return true, db.SetRevokedToken(models.RevokedToken{
+		TokenID:   accessToken.TokenID,
+		AppDID:    accessToken.AppDID,
+		RevokedAt: time.Now().UTC(),
+		ExpiresAt: accessToken.ExpiresAt,
+	})
+}
+
+func revokeRefreshToken(db store.Store, _, appDID, token string) (bool, error) {
+	refreshToken, err := db.GetRefreshToken(hashToken(token))
+	if errors.Is(err, store.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if appDID != "" && refreshToken.AppDID != appDID {
+		return true, ErrTokenNotIssuedToApp
+	}
+	if refreshToken.RevokedAt != nil {
+		return true, nil
+	}
+	return true, RevokeTokenFamily(db, refreshToken.FamilyID)
+}
+
+func resolveAccessToken(db store.Store, issuer, token string) (*models.AccessToken, error) {
+	// a signed token has a header, claims and signature, an opaque token has no dot
+	if strings.Count(token, ".") == 2 {
+		claims, err := utils.ValidateAccessToken(token, issuer, SigningPublicKey(db))
+		if err != nil {
+			return nil, ErrInvalidAccessToken
+		}
+		oauthCredential, _ := claims.CredentialJWTs.OAuthCredential.(string)
+		policyCredential, _ := claims.CredentialJWTs.PolicyCredential.(string)
+		return &models.AccessToken{
+			TokenID: claims.Id,
+			TokenGrant: models.TokenGrant{
+				AppDID:           claims.Audience,
+				SubjectDID:       claims.Subject,
+				OAuthCredential:  oauthCredential,
+				PolicyCredential: policyCredential,
+				Confirmation:     claims.Confirmation,
+			},
+			IssuedAt:  time.Unix(claims.IssuedAt, 0).UTC(),
+			ExpiresAt: time.Unix(claims.ExpiresAt, 0).UTC(),
+		}, nil
+	}
+	accessToken, err := db.GetAccessToken(hashToken(token))
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, ErrInvalidAccessToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if accessToken.TokenID == "" {
+		accessToken.TokenID = accessToken.TokenHash
+	}
+	return accessToken, nil
+}
+
+// issueTokens issues an access token and a refresh token of the family
+func issueTokens(db store.Store, issuer string, app *models.ApplicationResponse, familyID string, grant models.TokenGrant) (*models.GetAccessTokenResponse, error) {
+	accessToken, err := IssueAccessToken(db, issuer, app, grant)
+	if err != nil {
+		return nil, err
+	}
+	token, err := randomString(32, base64.RawURLEncoding.EncodeToString)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	err = db.SetRefreshToken(models.RefreshToken{
+		TokenHash:  hashToken(token),
+		FamilyID:   familyID,
+		TokenGrant: grant,
+		IssuedAt:   now,
+		ExpiresAt:  now.Add(RefreshTokenTTL(app)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &models.GetAccessTokenResponse{
+		AccessToken:           accessToken,
+		TokenType:             tokenType(grant),
+		ExpiresIn:             int64(AccessTokenTTL(app) / time.Second),
+		RefreshToken:          token,
+		RefreshTokenExpiresIn: int64(RefreshTokenTTL(app) / time.Second),
+	}, nil
+}
+
+// tokenType is DPoP for tokens bound to a key of the user DID (RFC 9449), Bearer otherwise
+func tokenType(grant models.TokenGrant) string {
+	if grant.Confirmation != nil {
+		return TokenTypeDPoP
+	}
+	return TokenTypeBearer
+}
+
+// hashToken is the SHA-256 hash an opaque access token or a refresh token is stored under
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// VerifyHolderProof verifies a proof of possession of a key of the holder DID, see utils.VerifyHolderProof, and
+// returns the DID URL of the key. The proof is used up: its jti is remembered for the key until the proof
+// expired, a replay is rejected (RFC 9449 section 11.1).
+func VerifyHolderProof(db store.Store, proof, holderDID, method, url, accessToken string) (string, error) {
+	holderProof, err := utils.VerifyHolderProof(proof, holderDID, method, url, accessToken)
+	if err != nil {
+		return "", err
+	}
+	used, err := db.UseProof(holderProof.ID, holderProof.ExpiresAt)
+	if err != nil {
+		return "", err
+	}
+	if !used {
+		return "", ErrProofReplayed
+	}
+	return holderProof.KeyID, nil
+}
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"authonomy/pkg/utils"
+	"authonomy/store"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TBD54566975/ssi-sdk/crypto"
+	"github.com/TBD54566975/ssi-sdk/did/key"
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jws"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+)
+
+const testProofURL = "https://authonomy.test/get-access-token"
+
+// signProof signs a proof of possession of the key of a new did:key holder, it returns the holder DID
+func signProof(t *testing.T, jti string) (string, string) {
+	t.Helper()
+	privateKey, didKey, err := key.GenerateDIDKey(crypto.Ed25519)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := didKey.Expand()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, fragment, _ := strings.Cut(doc.VerificationMethod[0].ID, "#")
+	token := jwt.New()
+	token.Set("htm", "POST")
+	token.Set("htu", testProofURL)
+	token.Set(jwt.JwtIDKey, jti)
+	token.Set(jwt.IssuedAtKey, time.Now())
+	headers := jws.NewHeaders()
+	headers.Set(jws.TypeKey, utils.ProofType)
+	headers.Set(jws.KeyIDKey, didKey.String()+"#"+fragment)
+	signed, err := jwt.Sign(token, jwt.WithKey(jwa.EdDSA, privateKey, jws.WithProtectedHeaders(headers)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return didKey.String(), string(signed)
+}
+
+func TestVerifyHolderProofReplay(t *testing.T) {
+	db := store.NewMemoryStore()
+	holderDID, proof := signProof(t, "proof-1")
+
+	keyID, err := VerifyHolderProof(db, proof, holderDID, "POST", testProofURL, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(keyID, holderDID+"#") {
+		t.Errorf("key ID = %s, want a key of %s", keyID, holderDID)
+	}
+	if _, err := VerifyHolderProof(db, proof, holderDID, "POST", testProofURL, ""); !errors.Is(err, ErrProofReplayed) {
+		t.Errorf("replayed proof error = %v, want %v", err, ErrProofReplayed)
+	}
+	// the jti is only unique per key, the same jti of another holder is not a replay
+	otherDID, otherProof := signProof(t, "proof-1")
+	if _, err := VerifyHolderProof(db, otherProof, otherDID, "POST", testProofURL, ""); err != nil {
+		t.Errorf("proof of another holder with the same jti: %v", err)
+	}
+	// a rejected proof is not remembered
+	nextDID, nextProof := signProof(t, "proof-2")
+	if _, err := VerifyHolderProof(db, nextProof, nextDID, "GET", testProofURL, ""); err == nil || errors.Is(err, ErrProofReplayed) {
+		t.Errorf("proof for another method error = %v, want a verification error", err)
+	}
+	if _, err := VerifyHolderProof(db, nextProof, nextDID, "POST", testProofURL, ""); err != nil {
+		t.Errorf("proof rejected before: %v", err)
+	}
+}
@@ -0,0 +1,827 @@
+package store
+
+import (
+	"authonomy/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+const (
+	app_prefix             = "app-"
+	policy_prefix          = "policy-"
+	issued_policy_prefix   = "issued-"
+	auth_prefix            = "auth-"
+	conf_prefix            = "conf-"
+	provider_schema_prefix = "prov-"
+	credential_prefix      = "cred-"
+	access_prefix          = "access-"
+	audit_prefix           = "audit-"
+	request_prefix         = "request-"
+	auth_session_prefix    = "state-"
+	login_session_prefix   = "session-"
+	api_key_prefix         = "apikey-"
+	signing_key_prefix     = "signkey-"
+	access_token_prefix    = "token-"
+	refresh_token_prefix   = "refresh-"
+	revoked_token_prefix   = "revoked-"
+	used_proof_prefix      = "proof-"
+)
+
+// BadgerStore encapsulates the BadgerDB operations, every record is encrypted with its own data
+// key which is wrapped by the master key
+type BadgerStore struct {
+	db  *badger.DB
+	key masterKey
+}
+
+// NewBadgerStore initializes and returns a new BadgerStore instance, the secret derives the master key
+func NewBadgerStore(path string, s string) (*BadgerStore, error) {
+	key, err := newMasterKey(s)
+	if err != nil {
+		return nil, err
+	}
+	db, err := badger.Open(badger.DefaultOptions(path))
+	if err != nil {
+		return nil, err
+	}
+	store := &BadgerStore{db: db, key: key}
+	// auth providers were stored under auth-<app>, attached policies under issued-<app>
+	err = migrateLegacyKeys(store, auth_prefix,
+		func(auth models.AuthProvider) string { return auth_prefix + auth.AppDID },
+		func(auth models.AuthProvider) string { return authProviderKey(auth.AppDID, auth.Provider.ProviderName) })
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrating the auth providers: %v", err)
+	}
+	err = migrateLegacyKeys(store, issued_policy_prefix,
+		func(policy models.ApplicationPolicyResponse) string {
+			return issued_policy_prefix + policy.ApplicationDID
+		},
+		func(policy models.ApplicationPolicyResponse) string {
+			return issuedPolicyKey(policy.ApplicationDID, policy.SchemaID)
+		})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrating the attached policies: %v", err)
+	}
+	return store, nil
+}
+
+// ClearDB deletes all key-value pairs in the database
+func (s *BadgerStore) ClearDB() error {
+	return s.db.DropAll()
+}
+
+// Close safely closes the BadgerDB instance
+func (s *BadgerStore) Close() error {
+	return s.db.Close()
+}
+
+// SetApp stores an Application instance in the database
+func (s *BadgerStore) SetApp(app models.ApplicationResponse) error {
+	return s.setJSON(app_prefix+app.AppDID, app)
+}
+
+// GetApp retrieves an Application instance from the database
+func (s *BadgerStore) GetApp(appID string) (*models.ApplicationResponse, error) {
+	var app models.ApplicationResponse
+	if err := s.getJSON(app_prefix+appID, &app); err != nil {
+		return nil, err
+	}
+	return &app, nil
+}
+
+// DeleteApp deletes the app and the records depending on it in one transaction
+func (s *BadgerStore) DeleteApp(appDID string) error {
+	return s.db.Update(func(txn *badger.Txn) error {
+		if _, err := txn.Get([]byte(app_prefix + appDID)); err != nil {
+			return notFound(err)
+		}
+		var keys [][]byte
+		keys = append(keys, []byte(app_prefix+appDID))
+
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		for _, prefix := range []string{auth_prefix + appDID + "-", issued_policy_prefix + appDID + "-", access_prefix + appDID + "-"} {
+			opts.Prefix = []byte(prefix)
+			it := txn.NewIterator(opts)
+			for it.Rewind(); it.Valid(); it.Next() {
+				keys = append(keys, it.Item().KeyCopy(nil))
+			}
+			it.Close()
+		}
+
+		opts = badger.DefaultIteratorOptions
+		opts.Prefix = []byte(request_prefix)
+		it := txn.NewIterator(opts)
+		for it.Rewind(); it.Valid(); it.Next() {
+			var request models.AccessRequest
+			err := it.Item().Value(func(val []byte) error {
+				return s.open(val, &request)
+			})
+			if err != nil {
+				it.Close()
+				return err
+			}
+			if request.AppDID == appDID {
+				keys = append(keys, it.Item().KeyCopy(nil))
+			}
+		}
+		it.Close()
+
+		for _, key := range keys {
+			if err := txn.Delete(key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// SetAuthProvider stores an AuthProvider instance in the database, keyed by the app and the provider
+func (s *BadgerStore) SetAuthProvider(auth models.AuthProvider) error {
+	return s.setJSON(authProviderKey(auth.AppDID, auth.Provider.ProviderName), auth)
+}
+
+// GetAuthProvider retrieves the provider linked to the app from the database
+func (s *BadgerStore) GetAuthProvider(appID, provider string) (*models.AuthProvider, error) {
+	var auth models.AuthProvider
+	if err := s.getJSON(authProviderKey(appID, provider), &auth); err != nil {
+		return nil, err
+	}
+	return &auth, nil
+}
+
+// GetAuthProvidersByApp retrieves all providers linked to the app, ordered by provider name
+func (s *BadgerStore) GetAuthProvidersByApp(appID string) ([]models.AuthProvider, error) {
+	var auths []models.AuthProvider
+	err := s.iterate(auth_prefix+appID+"-", func(val []byte) error {
+		var auth models.AuthProvider
+		if err := json.Unmarshal(val, &auth); err != nil {
+			return err
+		}
+		auths = append(auths, auth)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return auths, nil
+}
+
+// DeleteAuthProvider unlinks the provider from the app
+func (s *BadgerStore) DeleteAuthProvider(appID, provider string) error {
+	key := []byte(authProviderKey(appID, provider))
+	err := s.db.Update(func(txn *badger.Txn) error {
+		if _, err := txn.Get(key); err != nil {
+			return err
+		}
+		return txn.Delete(key)
+	})
+	return notFound(err)
+}
+
+// migrateLegacyKeys moves the records of the prefix stored under their legacy key, when an app could only
+// hold one of them, to their current key
+func migrateLegacyKeys[T any](s *BadgerStore, prefix string, legacyKey, key func(T) string) error {
+	return s.db.Update(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = []byte(prefix)
+		it := txn.NewIterator(opts)
+		var legacy []T
+		for it.Rewind(); it.Valid(); it.Next() {
+			var record T
+			err := it.Item().Value(func(val []byte) error {
+				return s.open(val, &record)
+			})
+			if err != nil {
+				it.Close()
+				return err
+			}
+			if string(it.Item().Key()) == legacyKey(record) {
+				legacy = append(legacy, record)
+			}
+		}
+		it.Close()
+
+		for _, record := range legacy {
+			sealed, err := s.seal(record)
+			if err != nil {
+				return err
+			}
+			if err := txn.Set([]byte(key(record)), sealed); err != nil {
+				return err
+			}
+			if err := txn.Delete([]byte(legacyKey(record))); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func authProviderKey(appID, provider string) string {
+	return auth_prefix + appID + "-" + provider
+}
+
+// SetPolicy stores a PolicySchemaResponse instance in the database
+func (s *BadgerStore) SetPolicy(policy models.PolicySchemaResponse) error {
+	return s.setJSON(policy_prefix+policy.ID, policy)
+}
+
+// GetPolicy retrieves a PolicySchemaResponse instance from the database
+func (s *BadgerStore) GetPolicy(policyID string) (*models.PolicySchemaResponse, error) {
+	var policy models.PolicySchemaResponse
+	if err := s.getJSON(policy_prefix+policyID, &policy); err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// SetIssuedPolicy stores an ApplicationPolicyResponse instance in the database, keyed by the app and the schema
+func (s *BadgerStore) SetIssuedPolicy(policy models.ApplicationPolicyResponse) error {
+	return s.setJSON(issuedPolicyKey(policy.ApplicationDID, policy.SchemaID), policy)
+}
+
+// GetIssuedPolicy retrieves the policy of the schema attached to the app from the database
+func (s *BadgerStore) GetIssuedPolicy(appDID, schemaID string) (*models.ApplicationPolicyResponse, error) {
+	var policy models.ApplicationPolicyResponse
+	if err := s.getJSON(issuedPolicyKey(appDID, schemaID), &policy); err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// GetIssuedPoliciesByApp retrieves all policies attached to the app, ordered by schema ID
+func (s *BadgerStore) GetIssuedPoliciesByApp(appDID string) ([]models.ApplicationPolicyResponse, error) {
+	var policies []models.ApplicationPolicyResponse
+	err := s.iterate(issued_policy_prefix+appDID+"-", func(val []byte) error {
+		var policy models.ApplicationPolicyResponse
+		if err := json.Unmarshal(val, &policy); err != nil {
+			return err
+		}
+		policies = append(policies, policy)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return policies, nil
+}
+
+// DeleteIssuedPolicy detaches the policy of the schema from the app
+func (s *BadgerStore) DeleteIssuedPolicy(appDID, schemaID string) error {
+	key := []byte(issuedPolicyKey(appDID, schemaID))
+	err := s.db.Update(func(txn *badger.Txn) error {
+		if _, err := txn.Get(key); err != nil {
+			return err
+		}
+		return txn.Delete(key)
+	})
+	return notFound(err)
+}
+
+func issuedPolicyKey(appDID, schemaID string) string {
+	return issued_policy_prefix + appDID + "-" + schemaID
+}
+
+// GetAllApps retrieves all Application instances from the database
+func (s *BadgerStore) GetAllApps() ([]models.ApplicationResponse, error) {
+	var apps []models.ApplicationResponse
+	err := s.iterate(app_prefix, func(val []byte) error {
+		var app models.ApplicationResponse
+		if err := json.Unmarshal(val, &app); err != nil {
+			return err
+		}
+		apps = append(apps, app)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return apps, nil
+}
+
+// GetAllPolicies retrieves all PolicySchemaResponse instances from the database
+func (s *BadgerStore) GetAllPolicies() ([]models.PolicySchemaResponse, error) {
+	var policies []models.PolicySchemaResponse
+	err := s.iterate(policy_prefix, func(val []byte) error {
+		var policy models.PolicySchemaResponse
+		if err := json.Unmarshal(val, &policy); err != nil {
+			return err
+		}
+		policies = append(policies, policy)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return policies, nil
+}
+
+// SetProviderSchema sets provider schema details.
+func (s *BadgerStore) SetProviderSchema(prov models.ProviderSchema) error {
+	return s.setJSON(provider_schema_prefix+prov.ProviderName, prov)
+}
+
+// GetProviderSchema get provider schema details.
+func (s *BadgerStore) GetProviderSchema(provider string) (*models.ProviderSchema, error) {
+	var prov models.ProviderSchema
+	if err := s.getJSON(provider_schema_prefix+provider, &prov); err != nil {
+		return nil, err
+	}
+	return &prov, nil
+}
+
+// SetCredentialRecord stores an issued credential record in the database
+func (s *BadgerStore) SetCredentialRecord(record models.CredentialRecord) error {
+	return s.setJSON(credential_prefix+record.CredentialID, record)
+}
+
+// GetCredentialRecord retrieves an issued credential record from the database
+func (s *BadgerStore) GetCredentialRecord(credentialID string) (*models.CredentialRecord, error) {
+	var record models.CredentialRecord
+	if err := s.getJSON(credential_prefix+credentialID, &record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// GetCredentialRecordsByApp retrieves all credential records issued for an application
+func (s *BadgerStore) GetCredentialRecordsByApp(appDID string) ([]models.CredentialRecord, error) {
+	var records []models.CredentialRecord
+	err := s.iterate(credential_prefix, func(val []byte) error {
+		var record models.CredentialRecord
+		if err := json.Unmarshal(val, &record); err != nil {
+			return err
+		}
+		if record.AppDID == appDID {
+			records = append(records, record)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// SetUserAccess stores the roles granted to a user on an application
+func (s *BadgerStore) SetUserAccess(access models.UserAccess) error {
+	return s.setJSON(access_prefix+access.AppDID+"-"+access.UserDID, access)
+}
+
+// GetUserAccess retrieves the roles granted to a user on an application
+func (s *BadgerStore) GetUserAccess(appDID, userDID string) (*models.UserAccess, error) {
+	var access models.UserAccess
+	if err := s.getJSON(access_prefix+appDID+"-"+userDID, &access); err != nil {
+		return nil, err
+	}
+	return &access, nil
+}
+
+// AddAuditEvent appends an access change to the audit log of an application
+func (s *BadgerStore) AddAuditEvent(event models.AuditEvent) error {
+	key := fmt.Sprintf("%s%s-%020d-%s", audit_prefix, event.AppDID, event.Timestamp.UnixNano(), event.ID)
+	return s.setJSON(key, event)
+}
+
+// GetAuditEvents retrieves the audit log of an application in chronological order
+func (s *BadgerStore) GetAuditEvents(appDID string) ([]models.AuditEvent, error) {
+	var events []models.AuditEvent
+	err := s.iterate(audit_prefix+appDID+"-", func(val []byte) error {
+		var event models.AuditEvent
+		if err := json.Unmarshal(val, &event); err != nil {
+			return err
+		}
+		events = append(events, event)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// SetAccessRequest stores an access request in the database
+func (s *BadgerStore) SetAccessRequest(request models.AccessRequest) error {
+	return s.setJSON(request_prefix+request.RequestID, request)
+}
+
+// GetAccessRequest retrieves an access request from the database
+func (s *BadgerStore) GetAccessRequest(requestID string) (*models.AccessRequest, error) {
+	var request models.AccessRequest
+	if err := s.getJSON(request_prefix+requestID, &request); err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+// GetAccessRequestsByApp retrieves all access requests made on an application
+func (s *BadgerStore) GetAccessRequestsByApp(appDID string) ([]models.AccessRequest, error) {
+	var requests []models.AccessRequest
+	err := s.iterate(request_prefix, func(val []byte) error {
+		var request models.AccessRequest
+		if err := json.Unmarshal(val, &request); err != nil {
+			return err
+		}
+		if request.AppDID == appDID {
+			requests = append(requests, request)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return requests, nil
+}
+
+// SetAuthSession stores the sign-in attempt until it expires
+func (s *BadgerStore) SetAuthSession(session models.AuthSession) error {
+	return s.setJSONWithExpiry(auth_session_prefix+session.State, session, session.ExpiresAt)
+}
+
+// TakeAuthSession retrieves and deletes the sign-in attempt, so a state can only be used once
+func (s *BadgerStore) TakeAuthSession(state string) (*models.AuthSession, error) {
+	var session models.AuthSession
+	if err := s.takeJSON(auth_session_prefix+state, &session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// SetLoginSession stores the signed in user until the session expires
+func (s *BadgerStore) SetLoginSession(session models.LoginSession) error {
+	return s.setJSONWithExpiry(login_session_prefix+session.SessionID, session, session.ExpiresAt)
+}
+
+// GetLoginSession retrieves the signed in user of the session
+func (s *BadgerStore) GetLoginSession(sessionID string) (*models.LoginSession, error) {
+	var session models.LoginSession
+	if err := s.getJSON(login_session_prefix+sessionID, &session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// DeleteLoginSession ends the session
+func (s *BadgerStore) DeleteLoginSession(sessionID string) error {
+	return s.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete([]byte(login_session_prefix + sessionID))
+	})
+}
+
+// SetAPIKey stores an admin API key in the database
+func (s *BadgerStore) SetAPIKey(key models.APIKey) error {
+	return s.setJSON(api_key_prefix+key.KeyID, key)
+}
+
+// GetAPIKey retrieves an admin API key from the database
+func (s *BadgerStore) GetAPIKey(keyID string) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := s.getJSON(api_key_prefix+keyID, &key); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// GetAllAPIKeys retrieves all admin API keys from the database
+func (s *BadgerStore) GetAllAPIKeys() ([]models.APIKey, error) {
+	var keys []models.APIKey
+	err := s.iterate(api_key_prefix, func(val []byte) error {
+		var key models.APIKey
+		if err := json.Unmarshal(val, &key); err != nil {
+			return err
+		}
+		keys = append(keys, key)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// SetSigningKey stores an access token signing key in the database
+func (s *BadgerStore) SetSigningKey(key models.SigningKey) error {
+	return s.setJSON(signing_key_prefix+key.KeyID, key)
+}
+
+// GetSigningKey retrieves an access token signing key from the database
+func (s *BadgerStore) GetSigningKey(keyID string) (*models.SigningKey, error) {
+	var key models.SigningKey
+	if err := s.getJSON(signing_key_prefix+keyID, &key); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// GetAllSigningKeys retrieves all access token signing keys from the database
+func (s *BadgerStore) GetAllSigningKeys() ([]models.SigningKey, error) {
+	var keys []models.SigningKey
+	err := s.iterate(signing_key_prefix, func(val []byte) error {
+		var key models.SigningKey
+		if err := json.Unmarshal(val, &key); err != nil {
+			return err
+		}
+		keys = append(keys, key)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// SetAccessToken stores an opaque access token until it expires
+func (s *BadgerStore) SetAccessToken(token models.AccessToken) error {
+	return s.setJSONWithExpiry(access_token_prefix+token.TokenHash, token, token.ExpiresAt)
+}
+
+// GetAccessToken retrieves an opaque access token by the hash of the token
+func (s *BadgerStore) GetAccessToken(tokenHash string) (*models.AccessToken, error) {
+	var token models.AccessToken
+	if err := s.getJSON(access_token_prefix+tokenHash, &token); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// SetRevokedToken denies an access token until it expires
+func (s *BadgerStore) SetRevokedToken(token models.RevokedToken) error {
+	return s.setJSONWithExpiry(revoked_token_prefix+token.TokenID, token, token.ExpiresAt)
+}
+
+// GetRevokedToken retrieves the deny-list entry of an access token by its jti
+func (s *BadgerStore) GetRevokedToken(tokenID string) (*models.RevokedToken, error) {
+	var token models.RevokedToken
+	if err := s.getJSON(revoked_token_prefix+tokenID, &token); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// SetRefreshToken stores a refresh token until it expires
+func (s *BadgerStore) SetRefreshToken(token models.RefreshToken) error {
+	return s.setJSONWithExpiry(refresh_token_prefix+token.TokenHash, token, token.ExpiresAt)
+}
+
+// GetRefreshToken retrieves a refresh token by the hash of the token
+func (s *BadgerStore) GetRefreshToken(tokenHash string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	if err := s.getJSON(refresh_token_prefix+tokenHash, &token); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// MarkRefreshTokenUsed sets the used time of an unused refresh token in a read-write transaction, of two
+// concurrent transactions the one committing last fails with a conflict and loses
+func (s *BadgerStore) MarkRefreshTokenUsed(tokenHash string, usedAt time.Time) (bool, error) {
+	key := []byte(refresh_token_prefix + tokenHash)
+	marked := false
+	err := s.db.Update(func(txn *badger.Txn) error {
+		item, err := txn.Get(key)
+		if err != nil {
+			return err
+		}
+		var token models.RefreshToken
+		if err := item.Value(func(val []byte) error {
+			return s.open(val, &token)
+		}); err != nil {
+			return err
+		}
+		ttl := time.Until(token.ExpiresAt)
+		if ttl <= 0 {
+			return badger.ErrKeyNotFound
+		}
+		if token.UsedAt != nil {
+			return nil
+		}
+		token.UsedAt = &usedAt
+		sealed, err := s.seal(token)
+		if err != nil {
+			return err
+		}
+		marked = true
+		return txn.SetEntry(badger.NewEntry(key, sealed).WithTTL(ttl))
+	})
+	if errors.Is(err, badger.ErrConflict) {
+		return false, nil
+	}
+	if err != nil {
+		return false, notFound(err)
+	}
+	return marked, nil
+}
+
+// GetRefreshTokensByFamily retrieves the unexpired refresh tokens of a token family
+func (s *BadgerStore) GetRefreshTokensByFamily(familyID string) ([]models.RefreshToken, error) {
+	var tokens []models.RefreshToken
+	err := s.iterate(refresh_token_prefix, func(val []byte) error {
+		var token models.RefreshToken
+		if err := json.Unmarshal(val, &token); err != nil {
+			return err
+		}
+		if token.FamilyID == familyID {
+			tokens = append(tokens, token)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// UseProof records the ID of a proof of possession until it expires, in a read-write transaction so of two
+// concurrent uses only one commits
+func (s *BadgerStore) UseProof(proofID string, expiresAt time.Time) (bool, error) {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return false, fmt.Errorf("proof has already expired")
+	}
+	key := []byte(used_proof_prefix + proofID)
+	used := false
+	err := s.db.Update(func(txn *badger.Txn) error {
+		_, err := txn.Get(key)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, badger.ErrKeyNotFound) {
+			return err
+		}
+		sealed, err := s.seal(expiresAt)
+		if err != nil {
+			return err
+		}
+		used = true
+		return txn.SetEntry(badger.NewEntry(key, sealed).WithTTL(ttl))
+	})
+	if errors.Is(err, badger.ErrConflict) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return used, nil
+}
+
+// setJSON marshals the value and stores it under the key
+func (s *BadgerStore) setJSON(key string, value interface{}) error {
+	return s.db.Update(func(txn *badger.Txn) error {
+		sealed, err := s.seal(value)
+		if err != nil {
+			return err
+		}
+		return txn.Set([]byte(key), sealed)
+	})
+}
+
+// getJSON retrieves the value stored under the key and unmarshals it
+func (s *BadgerStore) getJSON(key string, value interface{}) error {
+	err := s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(key))
+		if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			return s.open(val, value)
+		})
+	})
+	return notFound(err)
+}
+
+// setJSONWithExpiry stores the value under the key, badger drops it once it expires
+func (s *BadgerStore) setJSONWithExpiry(key string, value interface{}, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return fmt.Errorf("%s has already expired", key)
+	}
+	return s.db.Update(func(txn *badger.Txn) error {
+		sealed, err := s.seal(value)
+		if err != nil {
+			return err
+		}
+		return txn.SetEntry(badger.NewEntry([]byte(key), sealed).WithTTL(ttl))
+	})
+}
+
+// takeJSON retrieves the value stored under the key and deletes it in the same transaction
+func (s *BadgerStore) takeJSON(key string, value interface{}) error {
+	err := s.db.Update(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(key))
+		if err != nil {
+			return err
+		}
+		if err := item.Value(func(val []byte) error {
+			return s.open(val, value)
+		}); err != nil {
+			return err
+		}
+		return txn.Delete([]byte(key))
+	})
+	return notFound(err)
+}
+
+// iterate calls fn with the decrypted value of every key having the prefix, in key order
+func (s *BadgerStore) iterate(prefix string, fn func(val []byte) error) error {
+	return s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = []byte(prefix)
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			err := it.Item().Value(func(val []byte) error {
+				plaintext, err := s.key.open(val)
+				if err != nil {
+					return fmt.Errorf("decrypting %s: %v", it.Item().Key(), err)
+				}
+				return fn(plaintext)
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// seal marshals the value and encrypts it
+func (s *BadgerStore) seal(value interface{}) ([]byte, error) {
+	valueJSON, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return s.key.seal(valueJSON)
+}
+
+// open decrypts the stored value and unmarshals it
+func (s *BadgerStore) open(val []byte, value interface{}) error {
+	plaintext, err := s.key.open(val)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(plaintext, value)
+}
+
+// Rekey encrypts the data keys of every record with the master key derived from newSecret,
+// records written before encryption are sealed. The records already moved to the new key are
+// skipped, so an interrupted rekey can be run again. It returns the number of rewritten records.
+func (s *BadgerStore) Rekey(newSecret string) (int, error) {
+	newKey, err := newMasterKey(newSecret)
+	if err != nil {
+		return 0, err
+	}
+	wb := s.db.NewWriteBatch()
+	defer wb.Cancel()
+	count := 0
+	err = s.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			item := it.Item()
+			val, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			if envelopeKeyID(val) == newKey.id {
+				continue
+			}
+			rewrapped, err := rewrap(val, s.key, newKey)
+			if err != nil {
+				return fmt.Errorf("rekeying %s: %v", item.Key(), err)
+			}
+			// keep the expiry of the sessions
+			entry := badger.NewEntry(item.KeyCopy(nil), rewrapped)
+			entry.ExpiresAt = item.ExpiresAt()
+			if err := wb.SetEntry(entry); err != nil {
+				return err
+			}
+			count++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if err := wb.Flush(); err != nil {
+		return 0, err
+	}
+	s.key = newKey
+	return count, nil
+}
+
+// notFound maps the badger missing key error to ErrNotFound
+func notFound(err error) error {
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,142 @@
+package store
+
+import (
+	"authonomy/pkg/utils"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// envelopeVersion starts every encrypted record, the records written before encryption are plain
+// JSON and never start with it
+const envelopeVersion byte = 1
+
+// dataKeySize is the size of the AES-256 key generated for every record
+const dataKeySize = 32
+
+// masterKey encrypts the data keys of the records, it is derived from db_encryption_key
+type masterKey struct {
+	id  string
+	key []byte
+}
+
+// newMasterKey derives the master key from the configured secret
+func newMasterKey(secret string) (masterKey, error) {
+	if secret == "" {
+		return masterKey{}, errors.New("db_encryption_key is required")
+	}
+	key := utils.GenerateEncryptionKey(secret)
+	// the key ID identifies the master key without revealing it
+	id := sha256.Sum256(append([]byte("authonomy-key-id:"), key...))
+	return masterKey{id: hex.EncodeToString(id[:8]), key: key}, nil
+}
+
+// seal encrypts the record with a new data key and wraps the data key with the master key.
+// The envelope is: version | key ID length | key ID | wrapped key length | wrapped key | ciphertext
+func (m masterKey) seal(plaintext []byte) ([]byte, error) {
+	dataKey := make([]byte, dataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, err
+	}
+	ciphertext, err := utils.EncryptData(plaintext, dataKey)
+	if err != nil {
+		return nil, err
+	}
+	wrappedKey, err := utils.EncryptData(dataKey, m.key)
+	if err != nil {
+		return nil, err
+	}
+	return encodeEnvelope(m.id, wrappedKey, ciphertext), nil
+}
+
+// open decrypts a record sealed with the master key, plain JSON records are returned unchanged
+func (m masterKey) open(value []byte) ([]byte, error) {
+	if !isEnvelope(value) {
+		return value, nil
+	}
+	keyID, wrappedKey, ciphertext, err := decodeEnvelope(value)
+	if err != nil {
+		return nil, err
+	}
+	if keyID != m.id {
+		return nil, fmt.Errorf("record is encrypted with key %s, the configured key is %s", keyID, m.id)
+	}
+	dataKey, err := utils.DecryptData(wrappedKey, m.key)
+	if err != nil {
+		return nil, fmt.Errorf("unwrapping the data key: %v", err)
+	}
+	return utils.DecryptData(ciphertext, dataKey)
+}
+
+// rewrap moves a record from the old master key to the new one. Only the data key is encrypted
+// again, plain JSON records are sealed.
+func rewrap(value []byte, from, to masterKey) ([]byte, error) {
+	if !isEnvelope(value) {
+		return to.seal(value)
+	}
+	keyID, wrappedKey, ciphertext, err := decodeEnvelope(value)
+	if err != nil {
+		return nil, err
+	}
+	if keyID == to.id {
+		return value, nil
+	}
+	if keyID != from.id {
+		return nil, fmt.Errorf("record is encrypted with unknown key %s", keyID)
+	}
+	dataKey, err := utils.DecryptData(wrappedKey, from.key)
+	if err != nil {
+		return nil, fmt.Errorf("unwrapping the data key: %v", err)
+	}
+	if wrappedKey, err = utils.EncryptData(dataKey, to.key); err != nil {
+		return nil, err
+	}
+	return encodeEnvelope(to.id, wrappedKey, ciphertext), nil
+}
+
+// envelopeKeyID returns the ID of the master key the record is encrypted with, empty for plain records
+func envelopeKeyID(value []byte) string {
+	if !isEnvelope(value) {
+		return ""
+	}
+	keyID, _, _, err := decodeEnvelope(value)
+	if err != nil {
+		return ""
+	}
+	return keyID
+}
+
+func isEnvelope(value []byte) bool {
+	return len(value) > 0 && value[0] == envelopeVersion
+}
+
+func encodeEnvelope(keyID string, wrappedKey, ciphertext []byte) []byte {
+	out := make([]byte, 0, 4+len(keyID)+len(wrappedKey)+len(ciphertext))
+	out = append(out, envelopeVersion, byte(len(keyID)))
+	out = append(out, keyID...)
+	out = binary.BigEndian.AppendUint16(out, uint16(len(wrappedKey)))
+	out = append(out, wrappedKey...)
+	return append(out, ciphertext...)
+}
+
+func decodeEnvelope(value []byte) (keyID string, wrappedKey, ciphertext []byte, err error) {
+	malformed := errors.New("malformed encrypted record")
+	if len(value) < 2 {
+		return "", nil, nil, malformed
+	}
+	idLen := int(value[1])
+	rest := value[2:]
+	if len(rest) < idLen+2 {
+		return "", nil, nil, malformed
+	}
+	keyID, rest = string(rest[:idLen]), rest[idLen:]
+	keyLen := int(binary.BigEndian.Uint16(rest))
+	rest = rest[2:]
+	if len(rest) < keyLen {
+		return "", nil, nil, malformed
+	}
+	return keyID, rest[:keyLen], rest[keyLen:], nil
+}
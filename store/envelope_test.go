@@ -0,0 +1,197 @@
+package store
+
+import (
+	"bytes"
+	"testing"
+)
+
+func testMasterKey(t *testing.T, secret string) masterKey {
+	t.Helper()
+	key, err := newMasterKey(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestNewMasterKey(t *testing.T) {
+	if _, err := newMasterKey(""); err == nil {
+		t.Fatal("master key derived from an empty secret")
+	}
+	key := testMasterKey(t, "secret")
+	assertEqual(t, testMasterKey(t, "secret").id, key.id)
+	if testMasterKey(t, "other-secret").id == key.id {
+		t.Fatal("master keys of different secrets have the same ID")
+	}
+	if bytes.Contains([]byte(key.id), key.key) {
+		t.Fatal("key ID reveals the master key")
+	}
+}
+
+func TestEnvelopeEncoding(t *testing.T) {
+	tests := []struct {
+		name       string
+		keyID      string
+		wrappedKey []byte
+		ciphertext []byte
+	}{
+		{name: "record", keyID: "0123456789abcdef", wrappedKey: []byte("wrapped key"), ciphertext: []byte("ciphertext")},
+		{name: "empty ciphertext", keyID: "0123456789abcdef", wrappedKey: []byte("wrapped key")},
+		{name: "empty key ID", wrappedKey: []byte("wrapped key"), ciphertext: []byte("ciphertext")},
+		{name: "long wrapped key", keyID: "id", wrappedKey: bytes.Repeat([]byte{7}, 300), ciphertext: []byte("ciphertext")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value := encodeEnvelope(tt.keyID, tt.wrappedKey, tt.ciphertext)
+			if !isEnvelope(value) {
+				t.Fatal("encoded record is not an envelope")
+			}
+			keyID, wrappedKey, ciphertext, err := decodeEnvelope(value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertEqual(t, keyID, tt.keyID)
+			assertEqual(t, string(wrappedKey), string(tt.wrappedKey))
+			assertEqual(t, string(ciphertext), string(tt.ciphertext))
+			assertEqual(t, envelopeKeyID(value), tt.keyID)
+		})
+	}
+}
+
+func TestDecodeMalformedEnvelope(t *testing.T) {
+	tests := []struct {
+		name  string
+		value []byte
+	}{
+		{name: "empty", value: nil},
+		{name: "version only", value: []byte{envelopeVersion}},
+		{name: "key ID cut short", value: []byte{envelopeVersion, 4, 'a', 'b'}},
+		{name: "no wrapped key length", value: []byte{envelopeVersion, 2, 'i', 'd'}},
+		{name: "wrapped key length cut short", value: []byte{envelopeVersion, 2, 'i', 'd', 0}},
+		{name: "wrapped key cut short", value: []byte{envelopeVersion, 2, 'i', 'd', 0, 5, 'k', 'e', 'y'}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, _, err := decodeEnvelope(tt.value); err == nil {
+				t.Fatal("malformed envelope decoded")
+			}
+			assertEqual(t, envelopeKeyID(tt.value), "")
+		})
+	}
+	// a malformed envelope is neither opened nor rewrapped
+	key := testMasterKey(t, "secret")
+	malformed := []byte{envelopeVersion, 4, 'a', 'b'}
+	if _, err := key.open(malformed); err == nil {
+		t.Fatal("malformed envelope opened")
+	}
+	if _, err := rewrap(malformed, key, testMasterKey(t, "new-secret")); err == nil {
+		t.Fatal("malformed envelope rewrapped")
+	}
+}
+
+func TestSealOpen(t *testing.T) {
+	key := testMasterKey(t, "secret")
+	plaintext := []byte(`{"client_secret":"client-secret-value"}`)
+
+	sealed, err := key.seal(plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(sealed, plaintext) {
+		t.Fatal("sealed record contains the plaintext")
+	}
+	assertEqual(t, envelopeKeyID(sealed), key.id)
+	opened, err := key.open(sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, string(opened), string(plaintext))
+	// every record has its own data key
+	again, err := key.seal(plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(again, sealed) {
+		t.Fatal("sealing twice gave the same record")
+	}
+
+	// records written before encryption are plain JSON and read as they are
+	plain, err := key.open(plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, string(plain), string(plaintext))
+}
+
+func TestOpenWithWrongKey(t *testing.T) {
+	key := testMasterKey(t, "secret")
+	sealed, err := key.seal([]byte(`{"private_key":"key"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := testMasterKey(t, "other-secret").open(sealed); err == nil {
+		t.Fatal("record opened with another master key")
+	}
+	// a master key claiming the ID of the record still can not unwrap its data key
+	impostor := testMasterKey(t, "other-secret")
+	impostor.id = key.id
+	if _, err := impostor.open(sealed); err == nil {
+		t.Fatal("record opened with another master key under its ID")
+	}
+}
+
+func TestRewrap(t *testing.T) {
+	oldKey := testMasterKey(t, "secret")
+	newKey := testMasterKey(t, "new-secret")
+	otherKey := testMasterKey(t, "other-secret")
+	plaintext := []byte(`{"private_key":"key"}`)
+	seal := func(key masterKey) []byte {
+		t.Helper()
+		sealed, err := key.seal(plaintext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sealed
+	}
+
+	tests := []struct {
+		name    string
+		value   []byte
+		wantErr bool
+	}{
+		{name: "plain to sealed", value: plaintext},
+		{name: "old key to new key", value: seal(oldKey)},
+		{name: "already on the new key", value: seal(newKey)},
+		{name: "unknown key", value: seal(otherKey), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rewrapped, err := rewrap(tt.value, oldKey, newKey)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("rewrap() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			assertEqual(t, envelopeKeyID(rewrapped), newKey.id)
+			opened, err := newKey.open(rewrapped)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertEqual(t, string(opened), string(plaintext))
+			if _, err := oldKey.open(rewrapped); err == nil {
+				t.Fatal("rewrapped record opened with the old key")
+			}
+		})
+	}
+
+	// only the data key is encrypted again, the ciphertext of the record is kept
+	sealed := seal(oldKey)
+	rewrapped, err := rewrap(sealed, oldKey, newKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, oldCiphertext, _ := decodeEnvelope(sealed)
+	_, _, newCiphertext, _ := decodeEnvelope(rewrapped)
+	assertEqual(t, string(newCiphertext), string(oldCiphertext))
+}
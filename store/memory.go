@@ -0,0 +1,721 @@
+package store
+
+import (
+	"authonomy/models"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps every record in memory, nothing survives a restart. Records are copied in and
+// out of the store, so callers never share them with the store, like with the persistent backends.
+type MemoryStore struct {
+	mu              sync.RWMutex
+	apps            map[string]models.ApplicationResponse
+	authProviders   map[string]models.AuthProvider
+	providerSchemas map[string]models.ProviderSchema
+	policies        map[string]models.PolicySchemaResponse
+	issuedPolicies  map[string]models.ApplicationPolicyResponse
+	credentials     map[string]models.CredentialRecord
+	access          map[string]models.UserAccess
+	auditEvents     map[string][]models.AuditEvent
+	accessRequests  map[string]models.AccessRequest
+	authSessions    map[string]models.AuthSession
+	loginSessions   map[string]models.LoginSession
+	apiKeys         map[string]models.APIKey
+	signingKeys     map[string]models.SigningKey
+	accessTokens    map[string]models.AccessToken
+	refreshTokens   map[string]models.RefreshToken
+	revokedTokens   map[string]models.RevokedToken
+	usedProofs      map[string]time.Time
+}
+
+// NewMemoryStore initializes and returns a new, empty MemoryStore instance
+func NewMemoryStore() *MemoryStore {
+	s := &MemoryStore{}
+	s.reset()
+	return s
+}
+
+func (s *MemoryStore) reset() {
+	s.apps = make(map[string]models.ApplicationResponse)
+	s.authProviders = make(map[string]models.AuthProvider)
+	s.providerSchemas = make(map[string]models.ProviderSchema)
+	s.policies = make(map[string]models.PolicySchemaResponse)
+	s.issuedPolicies = make(map[string]models.ApplicationPolicyResponse)
+	s.credentials = make(map[string]models.CredentialRecord)
+	s.access = make(map[string]models.UserAccess)
+	s.auditEvents = make(map[string][]models.AuditEvent)
+	s.accessRequests = make(map[string]models.AccessRequest)
+	s.authSessions = make(map[string]models.AuthSession)
+	s.loginSessions = make(map[string]models.LoginSession)
+	s.apiKeys = make(map[string]models.APIKey)
+	s.signingKeys = make(map[string]models.SigningKey)
+	s.accessTokens = make(map[string]models.AccessToken)
+	s.refreshTokens = make(map[string]models.RefreshToken)
+	s.revokedTokens = make(map[string]models.RevokedToken)
+	s.usedProofs = make(map[string]time.Time)
+}
+
+// ClearDB deletes all records
+func (s *MemoryStore) ClearDB() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reset()
+	return nil
+}
+
+// Close is a no-op, the records are dropped with the store
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+// SetApp stores an Application instance
+func (s *MemoryStore) SetApp(app models.ApplicationResponse) error {
+	var stored models.ApplicationResponse
+	if err := clone(app, &stored); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.apps[app.AppDID] = stored
+	return nil
+}
+
+// GetApp retrieves an Application instance
+func (s *MemoryStore) GetApp(appID string) (*models.ApplicationResponse, error) {
+	s.mu.RLock()
+	app, ok := s.apps[appID]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+	var out models.ApplicationResponse
+	return &out, clone(app, &out)
+}
+
+// GetAllApps retrieves all Application instances ordered by DID
+func (s *MemoryStore) GetAllApps() ([]models.ApplicationResponse, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var apps []models.ApplicationResponse
+	for _, key := range sortedKeys(s.apps) {
+		apps = append(apps, s.apps[key])
+	}
+	var out []models.ApplicationResponse
+	return out, clone(apps, &out)
+}
+
+// DeleteApp deletes the app and the records depending on it
+func (s *MemoryStore) DeleteApp(appDID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.apps[appDID]; !ok {
+		return ErrNotFound
+	}
+	delete(s.apps, appDID)
+	for key, auth := range s.authProviders {
+		if auth.AppDID == appDID {
+			delete(s.authProviders, key)
+		}
+	}
+	for key, policy := range s.issuedPolicies {
+		if policy.ApplicationDID == appDID {
+			delete(s.issuedPolicies, key)
+		}
+	}
+	for key, access := range s.access {
+		if access.AppDID == appDID {
+			delete(s.access, key)
+		}
+	}
+	for key, request := range s.accessRequests {
+		if request.AppDID == appDID {
+			delete(s.accessRequests, key)
+		}
+	}
+	return nil
+}
+
+// SetAuthProvider stores an AuthProvider instance
+func (s *MemoryStore) SetAuthProvider(auth models.AuthProvider) error {
+	var stored models.AuthProvider
+	if err := clone(auth, &stored); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.authProviders[auth.AppDID+"-"+auth.Provider.ProviderName] = stored
+	return nil
+}
+
+// GetAuthProvider retrieves the provider linked to the app
+func (s *MemoryStore) GetAuthProvider(appID, provider string) (*models.AuthProvider, error) {
+	s.mu.RLock()
+	auth, ok := s.authProviders[appID+"-"+provider]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+	var out models.AuthProvider
+	return &out, clone(auth, &out)
+}
+
+// GetAuthProvidersByApp retrieves all providers linked to the app, ordered by provider name
+func (s *MemoryStore) GetAuthProvidersByApp(appID string) ([]models.AuthProvider, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var auths []models.AuthProvider
+	for _, key := range sortedKeys(s.authProviders) {
+		if auth := s.authProviders[key]; auth.AppDID == appID {
+			auths = append(auths, auth)
+		}
+	}
+	var out []models.AuthProvider
+	return out, clone(auths, &out)
+}
+
+// DeleteAuthProvider unlinks the provider from the app
+func (s *MemoryStore) DeleteAuthProvider(appID, provider string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.authProviders[appID+"-"+provider]; !ok {
+		return ErrNotFound
+	}
+	delete(s.authProviders, appID+"-"+provider)
+	return nil
+}
+
+// SetProviderSchema sets provider schema details.
+func (s *MemoryStore) SetProviderSchema(prov models.ProviderSchema) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.providerSchemas[prov.ProviderName] = prov
+	return nil
+}
+
+// GetProviderSchema get provider schema details.
+func (s *MemoryStore) GetProviderSchema(provider string) (*models.ProviderSchema, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	prov, ok := s.providerSchemas[provider]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &prov, nil
+}
+
+// SetPolicy stores a PolicySchemaResponse instance
+func (s *MemoryStore) SetPolicy(policy models.PolicySchemaResponse) error {
+	var stored models.PolicySchemaResponse
+	if err := clone(policy, &stored); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.policies[policy.ID] = stored
+	return nil
+}
+
+// GetPolicy retrieves a PolicySchemaResponse instance
+func (s *MemoryStore) GetPolicy(policyID string) (*models.PolicySchemaResponse, error) {
+	s.mu.RLock()
+	policy, ok := s.policies[policyID]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+	var out models.PolicySchemaResponse
+	return &out, clone(policy, &out)
+}
+
+// GetAllPolicies retrieves all PolicySchemaResponse instances ordered by ID
+func (s *MemoryStore) GetAllPolicies() ([]models.PolicySchemaResponse, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var policies []models.PolicySchemaResponse
+	for _, key := range sortedKeys(s.policies) {
+		policies = append(policies, s.policies[key])
+	}
+	var out []models.PolicySchemaResponse
+	return out, clone(policies, &out)
+}
+
+// SetIssuedPolicy stores an ApplicationPolicyResponse instance
+func (s *MemoryStore) SetIssuedPolicy(policy models.ApplicationPolicyResponse) error {
+	var stored models.ApplicationPolicyResponse
+	if err := clone(policy, &stored); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.issuedPolicies[policy.ApplicationDID+"-"+policy.SchemaID] = stored
+	return nil
+}
+
+// GetIssuedPolicy retrieves the policy of the schema attached to the app
+func (s *MemoryStore) GetIssuedPolicy(appDID, schemaID string) (*models.ApplicationPolicyResponse, error) {
+	s.mu.RLock()
+	policy, ok := s.issuedPolicies[appDID+"-"+schemaID]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+	var out models.ApplicationPolicyResponse
+	return &out, clone(policy, &out)
+}
+
+// GetIssuedPoliciesByApp retrieves all policies attached to the app, ordered by schema ID
+func (s *MemoryStore) GetIssuedPoliciesByApp(appDID string) ([]models.ApplicationPolicyResponse, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var policies []models.ApplicationPolicyResponse
+	for _, key := range sortedKeys(s.issuedPolicies) {
+		if policy := s.issuedPolicies[key]; policy.ApplicationDID == appDID {
+			policies = append(policies, policy)
+		}
+	}
+	var out []models.ApplicationPolicyResponse
+	return out, clone(policies, &out)
+}
+
+// DeleteIssuedPolicy detaches the policy of the schema from the app
+func (s *MemoryStore) DeleteIssuedPolicy(appDID, schemaID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.issuedPolicies[appDID+"-"+schemaID]; !ok {
+		return ErrNotFound
+	}
+	delete(s.issuedPolicies, appDID+"-"+schemaID)
+	return nil
+}
+
+// SetCredentialRecord stores an issued credential record
+func (s *MemoryStore) SetCredentialRecord(record models.CredentialRecord) error {
+	var stored models.CredentialRecord
+	if err := clone(record, &stored); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.credentials[record.CredentialID] = stored
+	return nil
+}
+
+// GetCredentialRecord retrieves an issued credential record
+func (s *MemoryStore) GetCredentialRecord(credentialID string) (*models.CredentialRecord, error) {
+	s.mu.RLock()
+	record, ok := s.credentials[credentialID]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+	var out models.CredentialRecord
+	return &out, clone(record, &out)
+}
+
+// GetCredentialRecordsByApp retrieves all credential records issued for an application
+func (s *MemoryStore) GetCredentialRecordsByApp(appDID string) ([]models.CredentialRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var records []models.CredentialRecord
+	for _, key := range sortedKeys(s.credentials) {
+		if record := s.credentials[key]; record.AppDID == appDID {
+			records = append(records, record)
+		}
+	}
+	var out []models.CredentialRecord
+	return out, clone(records, &out)
+}
+
+// SetUserAccess stores the roles granted to a user on an application
+func (s *MemoryStore) SetUserAccess(access models.UserAccess) error {
+	var stored models.UserAccess
+	if err := clone(access, &stored); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.access[access.AppDID+"-"+access.UserDID] = stored
+	return nil
+}
+
+// GetUserAccess retrieves the roles granted to a user on an application
+func (s *MemoryStore) GetUserAccess(appDID, userDID string) (*models.UserAccess, error) {
+	s.mu.RLock()
+	access, ok := s.access[appDID+"-"+userDID]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+	var out models.UserAccess
+	return &out, clone(access, &out)
+}
+
+// AddAuditEvent appends an access change to the audit log of an application
+func (s *MemoryStore) AddAuditEvent(event models.AuditEvent) error {
+	var stored models.AuditEvent
+	if err := clone(event, &stored); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	events := append(s.auditEvents[event.AppDID], stored)
+	sort.SliceStable(events, func(i, j int) bool {
+		if !events[i].Timestamp.Equal(events[j].Timestamp) {
+			return events[i].Timestamp.Before(events[j].Timestamp)
+		}
+		return events[i].ID < events[j].ID
+	})
+	s.auditEvents[stored.AppDID] = events
+	return nil
+}
+
+// GetAuditEvents retrieves the audit log of an application in chronological order
+func (s *MemoryStore) GetAuditEvents(appDID string) ([]models.AuditEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var events []models.AuditEvent
+	return events, clone(s.auditEvents[appDID], &events)
+}
+
+// SetAccessRequest stores an access request
+func (s *MemoryStore) SetAccessRequest(request models.AccessRequest) error {
+	var stored models.AccessRequest
+	if err := clone(request, &stored); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.accessRequests[request.RequestID] = stored
+	return nil
+}
+
+// GetAccessRequest retrieves an access request
+func (s *MemoryStore) GetAccessRequest(requestID string) (*models.AccessRequest, error) {
+	s.mu.RLock()
+	request, ok := s.accessRequests[requestID]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+	var out models.AccessRequest
+	return &out, clone(request, &out)
+}
+
+// GetAccessRequestsByApp retrieves all access requests made on an application
+func (s *MemoryStore) GetAccessRequestsByApp(appDID string) ([]models.AccessRequest, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var requests []models.AccessRequest
+	for _, key := range sortedKeys(s.accessRequests) {
+		if request := s.accessRequests[key]; request.AppDID == appDID {
+			requests = append(requests, request)
+		}
+	}
+	var out []models.AccessRequest
+	return out, clone(requests, &out)
+}
+
+// SetAuthSession stores the sign-in attempt until it expires
+func (s *MemoryStore) SetAuthSession(session models.AuthSession) error {
+	if !time.Now().Before(session.ExpiresAt) {
+		return fmt.Errorf("auth session %s has already expired", session.State)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.authSessions[session.State] = session
+	return nil
+}
+
+// TakeAuthSession retrieves and deletes the sign-in attempt, so a state can only be used once
+func (s *MemoryStore) TakeAuthSession(state string) (*models.AuthSession, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.authSessions[state]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	delete(s.authSessions, state)
+	if !time.Now().Before(session.ExpiresAt) {
+		return nil, ErrNotFound
+	}
+	return &session, nil
+}
+
+// SetLoginSession stores the signed in user until the session expires
+func (s *MemoryStore) SetLoginSession(session models.LoginSession) error {
+	if !time.Now().Before(session.ExpiresAt) {
+		return fmt.Errorf("login session %s has already expired", session.SessionID)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loginSessions[session.SessionID] = session
+	return nil
+}
+
+// GetLoginSession retrieves the signed in user of the session
+func (s *MemoryStore) GetLoginSession(sessionID string) (*models.LoginSession, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.loginSessions[sessionID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	// expired sessions are dropped when they are looked up
+	if !time.Now().Before(session.ExpiresAt) {
+		delete(s.loginSessions, sessionID)
+		return nil, ErrNotFound
+	}
+	return &session, nil
+}
+
+// DeleteLoginSession ends the session
+func (s *MemoryStore) DeleteLoginSession(sessionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.loginSessions, sessionID)
+	return nil
+}
+
+// SetAPIKey stores an admin API key
+func (s *MemoryStore) SetAPIKey(key models.APIKey) error {
+	var stored models.APIKey
+	if err := clone(key, &stored); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.apiKeys[key.KeyID] = stored
+	return nil
+}
+
+// GetAPIKey retrieves an admin API key
+func (s *MemoryStore) GetAPIKey(keyID string) (*models.APIKey, error) {
+	s.mu.RLock()
+	key, ok := s.apiKeys[keyID]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+	var out models.APIKey
+	return &out, clone(key, &out)
+}
+
+// GetAllAPIKeys retrieves all admin API keys ordered by key ID
+func (s *MemoryStore) GetAllAPIKeys() ([]models.APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var keys []models.APIKey
+	for _, id := range sortedKeys(s.apiKeys) {
+		keys = append(keys, s.apiKeys[id])
+	}
+	var out []models.APIKey
+	return out, clone(keys, &out)
+}
+
+// SetSigningKey stores an access token signing key
+func (s *MemoryStore) SetSigningKey(key models.SigningKey) error {
+	var stored models.SigningKey
+	if err := clone(key, &stored); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.signingKeys[key.KeyID] = stored
+	return nil
+}
+
+// GetSigningKey retrieves an access token signing key
+func (s *MemoryStore) GetSigningKey(keyID string) (*models.SigningKey, error) {
+	s.mu.RLock()
+	key, ok := s.signingKeys[keyID]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+	var out models.SigningKey
+	return &out, clone(key, &out)
+}
+
+// GetAllSigningKeys retrieves all access token signing keys ordered by key ID
+func (s *MemoryStore) GetAllSigningKeys() ([]models.SigningKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var keys []models.SigningKey
+	for _, id := range sortedKeys(s.signingKeys) {
+		keys = append(keys, s.signingKeys[id])
+	}
+	var out []models.SigningKey
+	return out, clone(keys, &out)
+}
+
+// SetAccessToken stores an opaque access token until it expires, the expired tokens are dropped
+func (s *MemoryStore) SetAccessToken(token models.AccessToken) error {
+	now := time.Now()
+	if !now.Before(token.ExpiresAt) {
+		return fmt.Errorf("access token has already expired")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for hash, stored := range s.accessTokens {
+		if !now.Before(stored.ExpiresAt) {
+			delete(s.accessTokens, hash)
+		}
+	}
+	s.accessTokens[token.TokenHash] = token
+	return nil
+}
+
+// GetAccessToken retrieves an opaque access token by the hash of the token
+func (s *MemoryStore) GetAccessToken(tokenHash string) (*models.AccessToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	token, ok := s.accessTokens[tokenHash]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if !time.Now().Before(token.ExpiresAt) {
+		delete(s.accessTokens, tokenHash)
+		return nil, ErrNotFound
+	}
+	return &token, nil
+}
+
+// SetRevokedToken denies an access token until it expires, the entries of expired tokens are dropped
+func (s *MemoryStore) SetRevokedToken(token models.RevokedToken) error {
+	now := time.Now()
+	if !now.Before(token.ExpiresAt) {
+		return fmt.Errorf("revoked token %s has already expired", token.TokenID)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, stored := range s.revokedTokens {
+		if !now.Before(stored.ExpiresAt) {
+			delete(s.revokedTokens, id)
+		}
+	}
+	s.revokedTokens[token.TokenID] = token
+	return nil
+}
+
+// GetRevokedToken retrieves the deny-list entry of an access token by its jti
+func (s *MemoryStore) GetRevokedToken(tokenID string) (*models.RevokedToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	token, ok := s.revokedTokens[tokenID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if !time.Now().Before(token.ExpiresAt) {
+		delete(s.revokedTokens, tokenID)
+		return nil, ErrNotFound
+	}
+	return &token, nil
+}
+
+// SetRefreshToken stores a refresh token until it expires, the expired tokens are dropped
+func (s *MemoryStore) SetRefreshToken(token models.RefreshToken) error {
+	now := time.Now()
+	if !now.Before(token.ExpiresAt) {
+		return fmt.Errorf("refresh token has already expired")
+	}
+	var stored models.RefreshToken
+	if err := clone(token, &stored); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for hash, token := range s.refreshTokens {
+		if !now.Before(token.ExpiresAt) {
+			delete(s.refreshTokens, hash)
+		}
+	}
+	s.refreshTokens[stored.TokenHash] = stored
+	return nil
+}
+
+// GetRefreshToken retrieves a refresh token by the hash of the token
+func (s *MemoryStore) GetRefreshToken(tokenHash string) (*models.RefreshToken, error) {
+	s.mu.RLock()
+	token, ok := s.refreshTokens[tokenHash]
+	s.mu.RUnlock()
+	if !ok || !time.Now().Before(token.ExpiresAt) {
+		return nil, ErrNotFound
+	}
+	var out models.RefreshToken
+	return &out, clone(token, &out)
+}
+
+// MarkRefreshTokenUsed sets the used time of an unused refresh token under the lock
+func (s *MemoryStore) MarkRefreshTokenUsed(tokenHash string, usedAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	token, ok := s.refreshTokens[tokenHash]
+	if !ok || !time.Now().Before(token.ExpiresAt) {
+		return false, ErrNotFound
+	}
+	if token.UsedAt != nil {
+		return false, nil
+	}
+	token.UsedAt = &usedAt
+	s.refreshTokens[tokenHash] = token
+	return true, nil
+}
+
+// GetRefreshTokensByFamily retrieves the unexpired refresh tokens of a token family ordered by hash
+func (s *MemoryStore) GetRefreshTokensByFamily(familyID string) ([]models.RefreshToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	now := time.Now()
+	var tokens []models.RefreshToken
+	for _, hash := range sortedKeys(s.refreshTokens) {
+		token := s.refreshTokens[hash]
+		if token.FamilyID == familyID && now.Before(token.ExpiresAt) {
+			tokens = append(tokens, token)
+		}
+	}
+	var out []models.RefreshToken
+	return out, clone(tokens, &out)
+}
+
+// UseProof records the ID of a proof of possession until it expires, the expired IDs are dropped
+func (s *MemoryStore) UseProof(proofID string, expiresAt time.Time) (bool, error) {
+	now := time.Now()
+	if !now.Before(expiresAt) {
+		return false, fmt.Errorf("proof has already expired")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, expires := range s.usedProofs {
+		if !now.Before(expires) {
+			delete(s.usedProofs, id)
+		}
+	}
+	if _, ok := s.usedProofs[proofID]; ok {
+		return false, nil
+	}
+	s.usedProofs[proofID] = expiresAt
+	return true, nil
+}
+
+// clone deep copies src into dst through JSON, the same round trip the persistent backends make
+func clone(src, dst interface{}) error {
+	data, err := json.Marshal(src)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, dst)
+}
+
+// sortedKeys returns the keys of the map in order, mirroring the key order of a badger scan
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,1251 @@
+package store
+
+import (
+	"authonomy/models"
+	"crypto/ed25519"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	_ "modernc.org/sqlite" // pure Go SQLite driver
+)
+
+// sqliteTimeLayout keeps a fixed width so the stored times sort as text
+const sqliteTimeLayout = "2006-01-02T15:04:05.000000000Z"
+
+// sqliteSchema creates the tables on first use. Nested documents that are only ever read whole
+// (app details excepted) are kept as JSON text, everything queried on gets its own column.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS apps (
+	app_did                    TEXT PRIMARY KEY,
+	app_secret                 TEXT NOT NULL DEFAULT '',
+	app_name                   TEXT NOT NULL,
+	description                TEXT NOT NULL DEFAULT '',
+	contact_email              TEXT NOT NULL DEFAULT '',
+	status                     TEXT NOT NULL DEFAULT '',
+	suspended_at               TEXT,
+	secret_hash                TEXT NOT NULL DEFAULT '',
+	previous_secret_hash       TEXT NOT NULL DEFAULT '',
+	previous_secret_expires_at TEXT,
+	access_token_format        TEXT NOT NULL DEFAULT '',
+	access_token_ttl           INTEGER NOT NULL DEFAULT 0,
+	refresh_token_ttl          INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS provider_schemas (
+	provider_name TEXT PRIMARY KEY,
+	schema_id     TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS auth_providers (
+	app_did            TEXT NOT NULL REFERENCES apps (app_did) ON DELETE CASCADE,
+	provider_name      TEXT NOT NULL,
+	provider_type      TEXT NOT NULL,
+	provider_protocol  TEXT NOT NULL,
+	provider_schema_id TEXT NOT NULL,
+	config             BLOB NOT NULL,
+	PRIMARY KEY (app_did, provider_name)
+);
+CREATE INDEX IF NOT EXISTS auth_providers_provider_name ON auth_providers (provider_name);
+
+CREATE TABLE IF NOT EXISTS policies (
+	id            TEXT PRIMARY KEY,
+	name          TEXT NOT NULL,
+	version       INTEGER NOT NULL DEFAULT 0,
+	deprecated    INTEGER NOT NULL DEFAULT 0,
+	deprecated_at TEXT,
+	schema        TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS policies_name ON policies (name, version);
+
+CREATE TABLE IF NOT EXISTS issued_policies (
+	app_did            TEXT NOT NULL REFERENCES apps (app_did) ON DELETE CASCADE,
+	schema_id          TEXT NOT NULL,
+	policy_type        TEXT NOT NULL DEFAULT '',
+	issuer_did         TEXT NOT NULL,
+	credential_id      TEXT NOT NULL,
+	credential_subject TEXT NOT NULL,
+	PRIMARY KEY (app_did, schema_id)
+);
+CREATE INDEX IF NOT EXISTS issued_policies_schema_id ON issued_policies (schema_id);
+
+CREATE TABLE IF NOT EXISTS credentials (
+	credential_id TEXT PRIMARY KEY,
+	app_did       TEXT NOT NULL,
+	subject_did   TEXT NOT NULL,
+	schema_id     TEXT NOT NULL,
+	issued_at     TEXT NOT NULL,
+	revoked       INTEGER NOT NULL DEFAULT 0,
+	revoked_at    TEXT,
+	reason        TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS credentials_app_did ON credentials (app_did, subject_did);
+
+CREATE TABLE IF NOT EXISTS user_access (
+	app_did              TEXT NOT NULL REFERENCES apps (app_did) ON DELETE CASCADE,
+	user_did             TEXT NOT NULL,
+	roles                TEXT NOT NULL,
+	policy_credential_id TEXT NOT NULL DEFAULT '',
+	updated_at           TEXT NOT NULL,
+	PRIMARY KEY (app_did, user_did)
+);
+
+CREATE TABLE IF NOT EXISTS audit_events (
+	id                  TEXT PRIMARY KEY,
+	app_did             TEXT NOT NULL,
+	user_did            TEXT NOT NULL,
+	action              TEXT NOT NULL,
+	role_name           TEXT NOT NULL,
+	changed             INTEGER NOT NULL,
+	credential_id       TEXT NOT NULL DEFAULT '',
+	revoked_credentials TEXT NOT NULL DEFAULT '[]',
+	reason              TEXT NOT NULL DEFAULT '',
+	timestamp           TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS audit_events_app_did ON audit_events (app_did, timestamp, id);
+
+CREATE TABLE IF NOT EXISTS access_requests (
+	request_id      TEXT PRIMARY KEY,
+	app_did         TEXT NOT NULL REFERENCES apps (app_did) ON DELETE CASCADE,
+	user_did        TEXT NOT NULL,
+	role_name       TEXT NOT NULL DEFAULT '',
+	permission      TEXT NOT NULL DEFAULT '',
+	reason          TEXT NOT NULL DEFAULT '',
+	status          TEXT NOT NULL,
+	granted_role    TEXT NOT NULL DEFAULT '',
+	decision_reason TEXT NOT NULL DEFAULT '',
+	credential_id   TEXT NOT NULL DEFAULT '',
+	created_at      TEXT NOT NULL,
+	updated_at      TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS access_requests_app_did ON access_requests (app_did, status);
+
+CREATE TABLE IF NOT EXISTS auth_sessions (
+	state         TEXT PRIMARY KEY,
+	app_did       TEXT NOT NULL,
+	providers     TEXT NOT NULL,
+	nonce         TEXT NOT NULL DEFAULT '',
+	code_verifier TEXT NOT NULL DEFAULT '',
+	expires_at    TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS login_sessions (
+	session_id TEXT PRIMARY KEY,
+	app_did    TEXT NOT NULL,
+	provider   TEXT NOT NULL,
+	user_info  TEXT NOT NULL,
+	expires_at TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS api_keys (
+	key_id     TEXT PRIMARY KEY,
+	name       TEXT NOT NULL,
+	scopes     TEXT NOT NULL,
+	key_hash   TEXT NOT NULL,
+	created_at TEXT NOT NULL,
+	revoked    INTEGER NOT NULL DEFAULT 0,
+	revoked_at TEXT
+);
+
+CREATE TABLE IF NOT EXISTS signing_keys (
+	kid          TEXT PRIMARY KEY,
+	algorithm    TEXT NOT NULL,
+	public_key   BLOB NOT NULL,
+	private_key  BLOB NOT NULL,
+	created_at   TEXT NOT NULL,
+	activates_at TEXT NOT NULL,
+	retired_at   TEXT
+);
+
+CREATE TABLE IF NOT EXISTS used_proofs (
+	proof_id   TEXT PRIMARY KEY,
+	expires_at TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS access_tokens (
+	token_hash        TEXT PRIMARY KEY,
+	token_id          TEXT NOT NULL DEFAULT '',
+	app_did           TEXT NOT NULL,
+	subject_did       TEXT NOT NULL DEFAULT '',
+	oauth_credential  TEXT NOT NULL,
+	policy_credential TEXT NOT NULL,
+	cnf_kid           TEXT NOT NULL DEFAULT '',
+	issued_at         TEXT NOT NULL,
+	expires_at        TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+	token_hash        TEXT PRIMARY KEY,
+	family_id         TEXT NOT NULL,
+	app_did           TEXT NOT NULL,
+	subject_did       TEXT NOT NULL DEFAULT '',
+	oauth_credential  TEXT NOT NULL,
+	policy_credential TEXT NOT NULL,
+	cnf_kid           TEXT NOT NULL DEFAULT '',
+	issued_at         TEXT NOT NULL,
+	expires_at        TEXT NOT NULL,
+	used_at           TEXT,
+	revoked_at        TEXT
+);
+
+CREATE INDEX IF NOT EXISTS refresh_tokens_family ON refresh_tokens (family_id);
+
+CREATE TABLE IF NOT EXISTS revoked_tokens (
+	token_id   TEXT PRIMARY KEY,
+	app_did    TEXT NOT NULL,
+	revoked_at TEXT NOT NULL,
+	expires_at TEXT NOT NULL
+);
+`
+
+// sqliteTables lists the tables children first, so they can be cleared without breaking a foreign key
+var sqliteTables = []string{
+	"used_proofs", "revoked_tokens", "refresh_tokens", "access_tokens", "signing_keys", "api_keys", "login_sessions", "auth_sessions", "access_requests", "audit_events", "user_access", "credentials",
+	"issued_policies", "policies", "auth_providers", "provider_schemas", "apps",
+}
+
+// SQLiteStore keeps the records in SQLite tables, so they can be queried for reporting. The secrets
+// among them, the private signing keys and the provider configs with their client secrets, are
+// encrypted like the Badger records.
+type SQLiteStore struct {
+	db  *sql.DB
+	key masterKey
+}
+
+// NewSQLiteStore opens the SQLite database at path and creates the missing tables, the secret derives
+// the master key
+func NewSQLiteStore(path string, secret string) (*SQLiteStore, error) {
+	key, err := newMasterKey(secret)
+	if err != nil {
+		return nil, err
+	}
+	dsn := fmt.Sprintf("file:%s?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)", path)
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("creating sqlite schema: %v", err)
+	}
+	return &SQLiteStore{db: db, key: key}, nil
+}
+
+// ClearDB deletes all records
+func (s *SQLiteStore) ClearDB() error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, table := range sqliteTables {
+		if _, err := tx.Exec("DELETE FROM " + table); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Close closes the database
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+// Rekey encrypts the data keys of the private signing keys and provider configs with the master key
+// derived from newSecret, the values written before encryption are sealed. It runs in one transaction
+// and returns the number of rewritten values.
+func (s *SQLiteStore) Rekey(newSecret string) (int, error) {
+	newKey, err := newMasterKey(newSecret)
+	if err != nil {
+		return 0, err
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	count := 0
+	// rewrapColumn rewrites the encrypted column of every row, plain tells the values written before
+	// encryption apart
+	rewrapColumn := func(table, column string, plain func([]byte) bool) error {
+		rows, err := tx.Query("SELECT rowid, " + column + " FROM " + table)
+		if err != nil {
+			return err
+		}
+		values := make(map[int64][]byte)
+		for rows.Next() {
+			var rowID int64
+			var val []byte
+			if err := rows.Scan(&rowID, &val); err != nil {
+				rows.Close()
+				return err
+			}
+			values[rowID] = val
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		for rowID, val := range values {
+			if envelopeKeyID(val) == newKey.id {
+				continue
+			}
+			var rewrapped []byte
+			if plain(val) {
+				rewrapped, err = newKey.seal(val)
+			} else {
+				rewrapped, err = rewrap(val, s.key, newKey)
+			}
+			if err != nil {
+				return err
+			}
+			if _, err := tx.Exec("UPDATE "+table+" SET "+column+" = ? WHERE rowid = ?", rewrapped, rowID); err != nil {
+				return err
+			}
+			count++
+		}
+		return nil
+	}
+	plainKey := func(val []byte) bool { return len(val) == ed25519.PrivateKeySize }
+	if err := rewrapColumn("signing_keys", "private_key", plainKey); err != nil {
+		return 0, err
+	}
+	if err := rewrapColumn("auth_providers", "config", func(val []byte) bool { return !isEnvelope(val) }); err != nil {
+		return 0, err
+	}
+	return count, tx.Commit()
+}
+
+// SetApp stores an Application instance in the database
+func (s *SQLiteStore) SetApp(app models.ApplicationResponse) error {
+	_, err := s.db.Exec(`INSERT INTO apps (app_did, app_secret, app_name, description, contact_email, status, suspended_at,
+			secret_hash, previous_secret_hash, previous_secret_expires_at, access_token_format,
+			access_token_ttl, refresh_token_ttl)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (app_did) DO UPDATE SET app_secret = excluded.app_secret, app_name = excluded.app_name,
+			description = excluded.description, contact_email = excluded.contact_email, status = excluded.status,
+			suspended_at = excluded.suspended_at, secret_hash = excluded.secret_hash,
+			previous_secret_hash = excluded.previous_secret_hash, previous_secret_expires_at = excluded.previous_secret_expires_at,
+			access_token_format = excluded.access_token_format, access_token_ttl = excluded.access_token_ttl,
+			refresh_token_ttl = excluded.refresh_token_ttl`,
+		app.AppDID, app.AppSceret, app.AppName, app.AppDetails.Description, app.AppDetails.ContactEmail,
+		app.Status, formatTimePtr(app.SuspendedAt), app.SecretHash, app.PreviousSecretHash, formatTimePtr(app.PreviousSecretExpiresAt),
+		app.AccessTokenFormat, app.AccessTokenTTL, app.RefreshTokenTTL)
+	return err
+}
+
+// GetApp retrieves an Application instance from the database
+func (s *SQLiteStore) GetApp(appID string) (*models.ApplicationResponse, error) {
+	row := s.db.QueryRow(`SELECT `+sqliteAppColumns+` FROM apps WHERE app_did = ?`, appID)
+	return scanApp(row)
+}
+
+// GetAllApps retrieves all Application instances from the database
+func (s *SQLiteStore) GetAllApps() ([]models.ApplicationResponse, error) {
+	rows, err := s.db.Query(`SELECT ` + sqliteAppColumns + ` FROM apps ORDER BY app_did`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var apps []models.ApplicationResponse
+	for rows.Next() {
+		app, err := scanApp(rows)
+		if err != nil {
+			return nil, err
+		}
+		apps = append(apps, *app)
+	}
+	return apps, rows.Err()
+}
+
+// DeleteApp deletes the app, the foreign keys cascade to the records depending on it
+func (s *SQLiteStore) DeleteApp(appDID string) error {
+	result, err := s.db.Exec(`DELETE FROM apps WHERE app_did = ?`, appDID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// SetAuthProvider stores an AuthProvider instance in the database
+func (s *SQLiteStore) SetAuthProvider(auth models.AuthProvider) error {
+	config, err := json.Marshal(auth.Config)
+	if err != nil {
+		return err
+	}
+	if config, err = s.key.seal(config); err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`INSERT INTO auth_providers (app_did, provider_name, provider_type, provider_protocol, provider_schema_id, config)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (app_did, provider_name) DO UPDATE SET provider_type = excluded.provider_type,
+			provider_protocol = excluded.provider_protocol, provider_schema_id = excluded.provider_schema_id, config = excluded.config`,
+		auth.AppDID, auth.Provider.ProviderName, auth.Provider.ProviderType, auth.Provider.ProviderProtocol, auth.Provider.ProviderSchemaID, config)
+	return err
+}
+
+// GetAuthProvider retrieves the provider linked to the app from the database
+func (s *SQLiteStore) GetAuthProvider(appID, provider string) (*models.AuthProvider, error) {
+	row := s.db.QueryRow(`SELECT app_did, provider_name, provider_type, provider_protocol, provider_schema_id, config
+		FROM auth_providers WHERE app_did = ? AND provider_name = ?`, appID, provider)
+	return scanAuthProvider(row, s.key)
+}
+
+// GetAuthProvidersByApp retrieves all providers linked to the app, ordered by provider name
+func (s *SQLiteStore) GetAuthProvidersByApp(appID string) ([]models.AuthProvider, error) {
+	rows, err := s.db.Query(`SELECT app_did, provider_name, provider_type, provider_protocol, provider_schema_id, config
+		FROM auth_providers WHERE app_did = ? ORDER BY provider_name`, appID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var auths []models.AuthProvider
+	for rows.Next() {
+		auth, err := scanAuthProvider(rows, s.key)
+		if err != nil {
+			return nil, err
+		}
+		auths = append(auths, *auth)
+	}
+	return auths, rows.Err()
+}
+
+// DeleteAuthProvider unlinks the provider from the app
+func (s *SQLiteStore) DeleteAuthProvider(appID, provider string) error {
+	result, err := s.db.Exec(`DELETE FROM auth_providers WHERE app_did = ? AND provider_name = ?`, appID, provider)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// SetProviderSchema sets provider schema details.
+func (s *SQLiteStore) SetProviderSchema(prov models.ProviderSchema) error {
+	_, err := s.db.Exec(`INSERT INTO provider_schemas (provider_name, schema_id) VALUES (?, ?)
+		ON CONFLICT (provider_name) DO UPDATE SET schema_id = excluded.schema_id`, prov.ProviderName, prov.SchemaID)
+	return err
+}
+
+// GetProviderSchema get provider schema details.
+func (s *SQLiteStore) GetProviderSchema(provider string) (*models.ProviderSchema, error) {
+	var prov models.ProviderSchema
+	err := s.db.QueryRow(`SELECT provider_name, schema_id FROM provider_schemas WHERE provider_name = ?`, provider).
+		Scan(&prov.ProviderName, &prov.SchemaID)
+	if err != nil {
+		return nil, sqlNotFound(err)
+	}
+	return &prov, nil
+}
+
+// SetPolicy stores a PolicySchemaResponse instance in the database
+func (s *SQLiteStore) SetPolicy(policy models.PolicySchemaResponse) error {
+	schema, err := json.Marshal(policy.Schema)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`INSERT INTO policies (id, name, version, deprecated, deprecated_at, schema) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET name = excluded.name, version = excluded.version, deprecated = excluded.deprecated,
+			deprecated_at = excluded.deprecated_at, schema = excluded.schema`,
+		policy.ID, policy.Name, policy.Version, policy.Deprecated, formatTimePtr(policy.DeprecatedAt), string(schema))
+	return err
+}
+
+// GetPolicy retrieves a PolicySchemaResponse instance from the database
+func (s *SQLiteStore) GetPolicy(policyID string) (*models.PolicySchemaResponse, error) {
+	row := s.db.QueryRow(`SELECT id, name, version, deprecated, deprecated_at, schema FROM policies WHERE id = ?`, policyID)
+	return scanPolicy(row)
+}
+
+// GetAllPolicies retrieves all PolicySchemaResponse instances from the database
+func (s *SQLiteStore) GetAllPolicies() ([]models.PolicySchemaResponse, error) {
+	rows, err := s.db.Query(`SELECT id, name, version, deprecated, deprecated_at, schema FROM policies ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var policies []models.PolicySchemaResponse
+	for rows.Next() {
+		policy, err := scanPolicy(rows)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, *policy)
+	}
+	return policies, rows.Err()
+}
+
+// SetIssuedPolicy stores an ApplicationPolicyResponse instance in the database
+func (s *SQLiteStore) SetIssuedPolicy(policy models.ApplicationPolicyResponse) error {
+	subject, err := json.Marshal(policy.CredentialSubject)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`INSERT INTO issued_policies (app_did, schema_id, policy_type, issuer_did, credential_id, credential_subject)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (app_did, schema_id) DO UPDATE SET policy_type = excluded.policy_type, issuer_did = excluded.issuer_did,
+			credential_id = excluded.credential_id, credential_subject = excluded.credential_subject`,
+		policy.ApplicationDID, policy.SchemaID, policy.PolicyType, policy.IssuerDID, policy.CredentialID, string(subject))
+	return err
+}
+
+// GetIssuedPolicy retrieves the policy of the schema attached to the app from the database
+func (s *SQLiteStore) GetIssuedPolicy(appDID, schemaID string) (*models.ApplicationPolicyResponse, error) {
+	row := s.db.QueryRow(`SELECT app_did, schema_id, policy_type, issuer_did, credential_id, credential_subject
+		FROM issued_policies WHERE app_did = ? AND schema_id = ?`, appDID, schemaID)
+	return scanIssuedPolicy(row)
+}
+
+// GetIssuedPoliciesByApp retrieves all policies attached to the app, ordered by schema ID
+func (s *SQLiteStore) GetIssuedPoliciesByApp(appDID string) ([]models.ApplicationPolicyResponse, error) {
+	rows, err := s.db.Query(`SELECT app_did, schema_id, policy_type, issuer_did, credential_id, credential_subject
+		FROM issued_policies WHERE app_did = ? ORDER BY schema_id`, appDID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var policies []models.ApplicationPolicyResponse
+	for rows.Next() {
+		policy, err := scanIssuedPolicy(rows)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, *policy)
+	}
+	return policies, rows.Err()
+}
+
+// DeleteIssuedPolicy detaches the policy of the schema from the app
+func (s *SQLiteStore) DeleteIssuedPolicy(appDID, schemaID string) error {
+	result, err := s.db.Exec(`DELETE FROM issued_policies WHERE app_did = ? AND schema_id = ?`, appDID, schemaID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// SetCredentialRecord stores an issued credential record in the database
+func (s *SQLiteStore) SetCredentialRecord(record models.CredentialRecord) error {
+	_, err := s.db.Exec(`INSERT INTO credentials (credential_id, app_did, subject_did, schema_id, issued_at, revoked, revoked_at, reason)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (credential_id) DO UPDATE SET app_did = excluded.app_did, subject_did = excluded.subject_did,
+			schema_id = excluded.schema_id, issued_at = excluded.issued_at, revoked = excluded.revoked,
+			revoked_at = excluded.revoked_at, reason = excluded.reason`,
+		record.CredentialID, record.AppDID, record.SubjectDID, record.SchemaID, formatTime(record.IssuedAt),
+		record.Revoked, formatTimePtr(record.RevokedAt), record.Reason)
+	return err
+}
+
+// GetCredentialRecord retrieves an issued credential record from the database
+func (s *SQLiteStore) GetCredentialRecord(credentialID string) (*models.CredentialRecord, error) {
+	row := s.db.QueryRow(`SELECT credential_id, app_did, subject_did, schema_id, issued_at, revoked, revoked_at, reason
+		FROM credentials WHERE credential_id = ?`, credentialID)
+	return scanCredentialRecord(row)
+}
+
+// GetCredentialRecordsByApp retrieves all credential records issued for an application
+func (s *SQLiteStore) GetCredentialRecordsByApp(appDID string) ([]models.CredentialRecord, error) {
+	rows, err := s.db.Query(`SELECT credential_id, app_did, subject_did, schema_id, issued_at, revoked, revoked_at, reason
+		FROM credentials WHERE app_did = ? ORDER BY credential_id`, appDID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var records []models.CredentialRecord
+	for rows.Next() {
+		record, err := scanCredentialRecord(rows)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, *record)
+	}
+	return records, rows.Err()
+}
+
+// SetUserAccess stores the roles granted to a user on an application
+func (s *SQLiteStore) SetUserAccess(access models.UserAccess) error {
+	roles, err := json.Marshal(access.Roles)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`INSERT INTO user_access (app_did, user_did, roles, policy_credential_id, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (app_did, user_did) DO UPDATE SET roles = excluded.roles,
+			policy_credential_id = excluded.policy_credential_id, updated_at = excluded.updated_at`,
+		access.AppDID, access.UserDID, string(roles), access.PolicyCredentialID, formatTime(access.UpdatedAt))
+	return err
+}
+
+// GetUserAccess retrieves the roles granted to a user on an application
+func (s *SQLiteStore) GetUserAccess(appDID, userDID string) (*models.UserAccess, error) {
+	var access models.UserAccess
+	var roles, updatedAt string
+	err := s.db.QueryRow(`SELECT app_did, user_did, roles, policy_credential_id, updated_at
+		FROM user_access WHERE app_did = ? AND user_did = ?`, appDID, userDID).
+		Scan(&access.AppDID, &access.UserDID, &roles, &access.PolicyCredentialID, &updatedAt)
+	if err != nil {
+		return nil, sqlNotFound(err)
+	}
+	if err := json.Unmarshal([]byte(roles), &access.Roles); err != nil {
+		return nil, err
+	}
+	if access.UpdatedAt, err = parseTime(updatedAt); err != nil {
+		return nil, err
+	}
+	return &access, nil
+}
+
+// AddAuditEvent appends an access change to the audit log of an application
+func (s *SQLiteStore) AddAuditEvent(event models.AuditEvent) error {
+	revoked, err := json.Marshal(event.RevokedCredentials)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`INSERT INTO audit_events (id, app_did, user_did, action, role_name, changed, credential_id, revoked_credentials, reason, timestamp)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		event.ID, event.AppDID, event.UserDID, event.Action, event.RoleName, event.Changed, event.CredentialID,
+		string(revoked), event.Reason, formatTime(event.Timestamp))
+	return err
+}
+
+// GetAuditEvents retrieves the audit log of an application in chronological order
+func (s *SQLiteStore) GetAuditEvents(appDID string) ([]models.AuditEvent, error) {
+	rows, err := s.db.Query(`SELECT id, app_did, user_did, action, role_name, changed, credential_id, revoked_credentials, reason, timestamp
+		FROM audit_events WHERE app_did = ? ORDER BY timestamp, id`, appDID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var events []models.AuditEvent
+	for rows.Next() {
+		var event models.AuditEvent
+		var revoked, timestamp string
+		err := rows.Scan(&event.ID, &event.AppDID, &event.UserDID, &event.Action, &event.RoleName, &event.Changed,
+			&event.CredentialID, &revoked, &event.Reason, &timestamp)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(revoked), &event.RevokedCredentials); err != nil {
+			return nil, err
+		}
+		if event.Timestamp, err = parseTime(timestamp); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+// SetAccessRequest stores an access request in the database
+func (s *SQLiteStore) SetAccessRequest(request models.AccessRequest) error {
+	_, err := s.db.Exec(`INSERT INTO access_requests (request_id, app_did, user_did, role_name, permission, reason, status,
+			granted_role, decision_reason, credential_id, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (request_id) DO UPDATE SET app_did = excluded.app_did, user_did = excluded.user_did,
+			role_name = excluded.role_name, permission = excluded.permission, reason = excluded.reason,
+			status = excluded.status, granted_role = excluded.granted_role, decision_reason = excluded.decision_reason,
+			credential_id = excluded.credential_id, created_at = excluded.created_at, updated_at = excluded.updated_at`,
+		request.RequestID, request.AppDID, request.UserDID, request.RoleName, request.Permission, request.Reason, request.Status,
+		request.GrantedRole, request.DecisionReason, request.CredentialID, formatTime(request.CreatedAt), formatTime(request.UpdatedAt))
+	return err
+}
+
+// GetAccessRequest retrieves an access request from the database
+func (s *SQLiteStore) GetAccessRequest(requestID string) (*models.AccessRequest, error) {
+	row := s.db.QueryRow(`SELECT request_id, app_did, user_did, role_name, permission, reason, status,
+		granted_role, decision_reason, credential_id, created_at, updated_at FROM access_requests WHERE request_id = ?`, requestID)
+	return scanAccessRequest(row)
+}
+
+// GetAccessRequestsByApp retrieves all access requests made on an application
+func (s *SQLiteStore) GetAccessRequestsByApp(appDID string) ([]models.AccessRequest, error) {
+	rows, err := s.db.Query(`SELECT request_id, app_did, user_did, role_name, permission, reason, status,
+		granted_role, decision_reason, credential_id, created_at, updated_at FROM access_requests
+		WHERE app_did = ? ORDER BY request_id`, appDID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var requests []models.AccessRequest
+	for rows.Next() {
+		request, err := scanAccessRequest(rows)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, *request)
+	}
+	return requests, rows.Err()
+}
+
+// SetAuthSession stores the sign-in attempt until it expires
+func (s *SQLiteStore) SetAuthSession(session models.AuthSession) error {
+	if !time.Now().Before(session.ExpiresAt) {
+		return fmt.Errorf("auth session %s has already expired", session.State)
+	}
+	s.deleteExpiredSessions()
+	providers, err := json.Marshal(session.Providers)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`INSERT INTO auth_sessions (state, app_did, providers, nonce, code_verifier, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		session.State, session.AppDID, string(providers), session.Nonce, session.CodeVerifier, formatTime(session.ExpiresAt))
+	return err
+}
+
+// TakeAuthSession retrieves and deletes the sign-in attempt, so a state can only be used once
+func (s *SQLiteStore) TakeAuthSession(state string) (*models.AuthSession, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	var session models.AuthSession
+	var providers, expiresAt string
+	err = tx.QueryRow(`SELECT state, app_did, providers, nonce, code_verifier, expires_at FROM auth_sessions WHERE state = ?`, state).
+		Scan(&session.State, &session.AppDID, &providers, &session.Nonce, &session.CodeVerifier, &expiresAt)
+	if err != nil {
+		return nil, sqlNotFound(err)
+	}
+	if _, err := tx.Exec(`DELETE FROM auth_sessions WHERE state = ?`, state); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(providers), &session.Providers); err != nil {
+		return nil, err
+	}
+	if session.ExpiresAt, err = parseTime(expiresAt); err != nil {
+		return nil, err
+	}
+	if !time.Now().Before(session.ExpiresAt) {
+		return nil, ErrNotFound
+	}
+	return &session, nil
+}
+
+// SetLoginSession stores the signed in user until the session expires
+func (s *SQLiteStore) SetLoginSession(session models.LoginSession) error {
+	if !time.Now().Before(session.ExpiresAt) {
+		return fmt.Errorf("login session %s has already expired", session.SessionID)
+	}
+	userInfo, err := json.Marshal(session.UserInfo)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`INSERT INTO login_sessions (session_id, app_did, provider, user_info, expires_at) VALUES (?, ?, ?, ?, ?)`,
+		session.SessionID, session.AppDID, session.Provider, string(userInfo), formatTime(session.ExpiresAt))
+	return err
+}
+
+// GetLoginSession retrieves the signed in user of the session
+func (s *SQLiteStore) GetLoginSession(sessionID string) (*models.LoginSession, error) {
+	var session models.LoginSession
+	var userInfo, expiresAt string
+	err := s.db.QueryRow(`SELECT session_id, app_did, provider, user_info, expires_at FROM login_sessions
+		WHERE session_id = ? AND expires_at > ?`, sessionID, formatTime(time.Now())).
+		Scan(&session.SessionID, &session.AppDID, &session.Provider, &userInfo, &expiresAt)
+	if err != nil {
+		return nil, sqlNotFound(err)
+	}
+	if err := json.Unmarshal([]byte(userInfo), &session.UserInfo); err != nil {
+		return nil, err
+	}
+	if session.ExpiresAt, err = parseTime(expiresAt); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// DeleteLoginSession ends the session
+func (s *SQLiteStore) DeleteLoginSession(sessionID string) error {
+	_, err := s.db.Exec(`DELETE FROM login_sessions WHERE session_id = ?`, sessionID)
+	return err
+}
+
+// SetAPIKey stores an admin API key in the database
+func (s *SQLiteStore) SetAPIKey(key models.APIKey) error {
+	scopes, err := json.Marshal(key.Scopes)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`INSERT INTO api_keys (key_id, name, scopes, key_hash, created_at, revoked, revoked_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (key_id) DO UPDATE SET name = excluded.name, scopes = excluded.scopes, key_hash = excluded.key_hash,
+			created_at = excluded.created_at, revoked = excluded.revoked, revoked_at = excluded.revoked_at`,
+		key.KeyID, key.Name, string(scopes), key.KeyHash, formatTime(key.CreatedAt), key.Revoked, formatTimePtr(key.RevokedAt))
+	return err
+}
+
+// GetAPIKey retrieves an admin API key from the database
+func (s *SQLiteStore) GetAPIKey(keyID string) (*models.APIKey, error) {
+	row := s.db.QueryRow(`SELECT key_id, name, scopes, key_hash, created_at, revoked, revoked_at FROM api_keys WHERE key_id = ?`, keyID)
+	return scanAPIKey(row)
+}
+
+// GetAllAPIKeys retrieves all admin API keys from the database
+func (s *SQLiteStore) GetAllAPIKeys() ([]models.APIKey, error) {
+	rows, err := s.db.Query(`SELECT key_id, name, scopes, key_hash, created_at, revoked, revoked_at FROM api_keys ORDER BY key_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var keys []models.APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *key)
+	}
+	return keys, rows.Err()
+}
+
+// SetSigningKey stores an access token signing key in the database
+func (s *SQLiteStore) SetSigningKey(key models.SigningKey) error {
+	privateKey, err := s.key.seal(key.PrivateKey)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`INSERT INTO signing_keys (kid, algorithm, public_key, private_key, created_at, activates_at, retired_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (kid) DO UPDATE SET algorithm = excluded.algorithm, public_key = excluded.public_key,
+			private_key = excluded.private_key, created_at = excluded.created_at, activates_at = excluded.activates_at,
+			retired_at = excluded.retired_at`,
+		key.KeyID, key.Algorithm, key.PublicKey, privateKey, formatTime(key.CreatedAt), formatTime(key.ActivatesAt), formatTimePtr(key.RetiredAt))
+	return err
+}
+
+// GetSigningKey retrieves an access token signing key from the database
+func (s *SQLiteStore) GetSigningKey(keyID string) (*models.SigningKey, error) {
+	row := s.db.QueryRow(`SELECT kid, algorithm, public_key, private_key, created_at, activates_at, retired_at FROM signing_keys WHERE kid = ?`, keyID)
+	return scanSigningKey(row, s.key)
+}
+
+// GetAllSigningKeys retrieves all access token signing keys from the database
+func (s *SQLiteStore) GetAllSigningKeys() ([]models.SigningKey, error) {
+	rows, err := s.db.Query(`SELECT kid, algorithm, public_key, private_key, created_at, activates_at, retired_at FROM signing_keys ORDER BY kid`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var keys []models.SigningKey
+	for rows.Next() {
+		key, err := scanSigningKey(rows, s.key)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *key)
+	}
+	return keys, rows.Err()
+}
+
+// SetAccessToken stores an opaque access token until it expires
+func (s *SQLiteStore) SetAccessToken(token models.AccessToken) error {
+	if !time.Now().Before(token.ExpiresAt) {
+		return fmt.Errorf("access token has already expired")
+	}
+	s.deleteExpiredSessions()
+	_, err := s.db.Exec(`INSERT INTO access_tokens (token_hash, token_id, app_did, subject_did, oauth_credential, policy_credential,
+			cnf_kid, issued_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		token.TokenHash, token.TokenID, token.AppDID, token.SubjectDID, token.OAuthCredential, token.PolicyCredential,
+		confirmationKeyID(token.Confirmation), formatTime(token.IssuedAt), formatTime(token.ExpiresAt))
+	return err
+}
+
+// GetAccessToken retrieves an opaque access token by the hash of the token
+func (s *SQLiteStore) GetAccessToken(tokenHash string) (*models.AccessToken, error) {
+	var token models.AccessToken
+	var cnfKeyID, issuedAt, expiresAt string
+	err := s.db.QueryRow(`SELECT token_hash, token_id, app_did, subject_did, oauth_credential, policy_credential, cnf_kid,
+			issued_at, expires_at
+		FROM access_tokens WHERE token_hash = ? AND expires_at > ?`, tokenHash, formatTime(time.Now())).
+		Scan(&token.TokenHash, &token.TokenID, &token.AppDID, &token.SubjectDID, &token.OAuthCredential, &token.PolicyCredential,
+			&cnfKeyID, &issuedAt, &expiresAt)
+	if err != nil {
+		return nil, sqlNotFound(err)
+	}
+	token.Confirmation = parseConfirmation(cnfKeyID)
+	if token.IssuedAt, err = parseTime(issuedAt); err != nil {
+		return nil, err
+	}
+	if token.ExpiresAt, err = parseTime(expiresAt); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// SetRevokedToken denies an access token until it expires
+func (s *SQLiteStore) SetRevokedToken(token models.RevokedToken) error {
+	if !time.Now().Before(token.ExpiresAt) {
+		return fmt.Errorf("revoked token %s has already expired", token.TokenID)
+	}
+	s.deleteExpiredSessions()
+	_, err := s.db.Exec(`INSERT INTO revoked_tokens (token_id, app_did, revoked_at, expires_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (token_id) DO NOTHING`,
+		token.TokenID, token.AppDID, formatTime(token.RevokedAt), formatTime(token.ExpiresAt))
+	return err
+}
+
+// GetRevokedToken retrieves the deny-list entry of an access token by its jti
+func (s *SQLiteStore) GetRevokedToken(tokenID string) (*models.RevokedToken, error) {
+	var token models.RevokedToken
+	var revokedAt, expiresAt string
+	err := s.db.QueryRow(`SELECT token_id, app_did, revoked_at, expires_at FROM revoked_tokens WHERE token_id = ? AND expires_at > ?`,
+		tokenID, formatTime(time.Now())).Scan(&token.TokenID, &token.AppDID, &revokedAt, &expiresAt)
+	if err != nil {
+		return nil, sqlNotFound(err)
+	}
+	if token.RevokedAt, err = parseTime(revokedAt); err != nil {
+		return nil, err
+	}
+	if token.ExpiresAt, err = parseTime(expiresAt); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// SetRefreshToken stores a refresh token until it expires
+func (s *SQLiteStore) SetRefreshToken(token models.RefreshToken) error {
+	if !time.Now().Before(token.ExpiresAt) {
+		return fmt.Errorf("refresh token has already expired")
+	}
+	s.deleteExpiredSessions()
+	_, err := s.db.Exec(`INSERT INTO refresh_tokens (`+sqliteRefreshTokenColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (token_hash) DO UPDATE SET family_id = excluded.family_id, app_did = excluded.app_did,
+			subject_did = excluded.subject_did, oauth_credential = excluded.oauth_credential,
+			policy_credential = excluded.policy_credential, cnf_kid = excluded.cnf_kid, issued_at = excluded.issued_at,
+			expires_at = excluded.expires_at, used_at = excluded.used_at, revoked_at = excluded.revoked_at`,
+		token.TokenHash, token.FamilyID, token.AppDID, token.SubjectDID, token.OAuthCredential, token.PolicyCredential,
+		confirmationKeyID(token.Confirmation), formatTime(token.IssuedAt), formatTime(token.ExpiresAt), formatTimePtr(token.UsedAt), formatTimePtr(token.RevokedAt))
+	return err
+}
+
+// GetRefreshToken retrieves a refresh token by the hash of the token
+func (s *SQLiteStore) GetRefreshToken(tokenHash string) (*models.RefreshToken, error) {
+	row := s.db.QueryRow(`SELECT `+sqliteRefreshTokenColumns+` FROM refresh_tokens WHERE token_hash = ? AND expires_at > ?`,
+		tokenHash, formatTime(time.Now()))
+	return scanRefreshToken(row)
+}
+
+// MarkRefreshTokenUsed sets the used time of an unused refresh token in a single conditional update
+func (s *SQLiteStore) MarkRefreshTokenUsed(tokenHash string, usedAt time.Time) (bool, error) {
+	now := formatTime(time.Now())
+	result, err := s.db.Exec(`UPDATE refresh_tokens SET used_at = ? WHERE token_hash = ? AND used_at IS NULL AND expires_at > ?`,
+		formatTime(usedAt), tokenHash, now)
+	if err != nil {
+		return false, err
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if count == 1 {
+		return true, nil
+	}
+	var exists bool
+	err = s.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM refresh_tokens WHERE token_hash = ? AND expires_at > ?)`, tokenHash, now).Scan(&exists)
+	if err != nil {
+		return false, err
+	}
+	if !exists {
+		return false, ErrNotFound
+	}
+	return false, nil
+}
+
+// UseProof records the ID of a proof of possession until it expires, the insert is skipped for a known ID
+func (s *SQLiteStore) UseProof(proofID string, expiresAt time.Time) (bool, error) {
+	if !time.Now().Before(expiresAt) {
+		return false, fmt.Errorf("proof has already expired")
+	}
+	s.deleteExpiredSessions()
+	result, err := s.db.Exec(`INSERT INTO used_proofs (proof_id, expires_at) VALUES (?, ?) ON CONFLICT (proof_id) DO NOTHING`,
+		proofID, formatTime(expiresAt))
+	if err != nil {
+		return false, err
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return count == 1, nil
+}
+
+// GetRefreshTokensByFamily retrieves the unexpired refresh tokens of a token family
+func (s *SQLiteStore) GetRefreshTokensByFamily(familyID string) ([]models.RefreshToken, error) {
+	rows, err := s.db.Query(`SELECT `+sqliteRefreshTokenColumns+` FROM refresh_tokens WHERE family_id = ? AND expires_at > ?
+		ORDER BY token_hash`, familyID, formatTime(time.Now()))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var tokens []models.RefreshToken
+	for rows.Next() {
+		token, err := scanRefreshToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, *token)
+	}
+	return tokens, rows.Err()
+}
+
+// deleteExpiredSessions drops the abandoned sign-in attempts, sessions and tokens, and the deny-list entries of
+// expired tokens. Badger expires them on its own.
+func (s *SQLiteStore) deleteExpiredSessions() {
+	now := formatTime(time.Now())
+	s.db.Exec(`DELETE FROM auth_sessions WHERE expires_at <= ?`, now)
+	s.db.Exec(`DELETE FROM login_sessions WHERE expires_at <= ?`, now)
+	s.db.Exec(`DELETE FROM access_tokens WHERE expires_at <= ?`, now)
+	s.db.Exec(`DELETE FROM refresh_tokens WHERE expires_at <= ?`, now)
+	s.db.Exec(`DELETE FROM revoked_tokens WHERE expires_at <= ?`, now)
+	s.db.Exec(`DELETE FROM used_proofs WHERE expires_at <= ?`, now)
+}
+
+const sqliteAppColumns = `app_did, app_secret, app_name, description, contact_email, status, suspended_at,
+	secret_hash, previous_secret_hash, previous_secret_expires_at, access_token_format,
+	access_token_ttl, refresh_token_ttl`
+
+const sqliteRefreshTokenColumns = `token_hash, family_id, app_did, subject_did, oauth_credential, policy_credential,
+	cnf_kid, issued_at, expires_at, used_at, revoked_at`
+
+// scanner is implemented by both sql.Row and sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanApp(row scanner) (*models.ApplicationResponse, error) {
+	var app models.ApplicationResponse
+	var suspendedAt, previousExpiresAt sql.NullString
+	err := row.Scan(&app.AppDID, &app.AppSceret, &app.AppName, &app.AppDetails.Description, &app.AppDetails.ContactEmail,
+		&app.Status, &suspendedAt, &app.SecretHash, &app.PreviousSecretHash, &previousExpiresAt,
+		&app.AccessTokenFormat, &app.AccessTokenTTL, &app.RefreshTokenTTL)
+	if err != nil {
+		return nil, sqlNotFound(err)
+	}
+	if app.SuspendedAt, err = parseTimePtr(suspendedAt); err != nil {
+		return nil, err
+	}
+	if app.PreviousSecretExpiresAt, err = parseTimePtr(previousExpiresAt); err != nil {
+		return nil, err
+	}
+	return &app, nil
+}
+
+func scanAuthProvider(row scanner, key masterKey) (*models.AuthProvider, error) {
+	var auth models.AuthProvider
+	var config []byte
+	err := row.Scan(&auth.AppDID, &auth.Provider.ProviderName, &auth.Provider.ProviderType, &auth.Provider.ProviderProtocol,
+		&auth.Provider.ProviderSchemaID, &config)
+	if err != nil {
+		return nil, sqlNotFound(err)
+	}
+	// the configs stored before encryption are plain JSON
+	if config, err = key.open(config); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(config, &auth.Config); err != nil {
+		return nil, err
+	}
+	return &auth, nil
+}
+
+func scanPolicy(row scanner) (*models.PolicySchemaResponse, error) {
+	var policy models.PolicySchemaResponse
+	var schema string
+	var deprecatedAt sql.NullString
+	if err := row.Scan(&policy.ID, &policy.Name, &policy.Version, &policy.Deprecated, &deprecatedAt, &schema); err != nil {
+		return nil, sqlNotFound(err)
+	}
+	var err error
+	if policy.DeprecatedAt, err = parseTimePtr(deprecatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(schema), &policy.Schema); err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+func scanIssuedPolicy(row scanner) (*models.ApplicationPolicyResponse, error) {
+	var policy models.ApplicationPolicyResponse
+	var subject string
+	err := row.Scan(&policy.ApplicationDID, &policy.SchemaID, &policy.PolicyType, &policy.IssuerDID, &policy.CredentialID, &subject)
+	if err != nil {
+		return nil, sqlNotFound(err)
+	}
+	if err := json.Unmarshal([]byte(subject), &policy.CredentialSubject); err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+func scanCredentialRecord(row scanner) (*models.CredentialRecord, error) {
+	var record models.CredentialRecord
+	var issuedAt string
+	var revokedAt sql.NullString
+	err := row.Scan(&record.CredentialID, &record.AppDID, &record.SubjectDID, &record.SchemaID, &issuedAt,
+		&record.Revoked, &revokedAt, &record.Reason)
+	if err != nil {
+		return nil, sqlNotFound(err)
+	}
+	if record.IssuedAt, err = parseTime(issuedAt); err != nil {
+		return nil, err
+	}
+	if record.RevokedAt, err = parseTimePtr(revokedAt); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+func scanAccessRequest(row scanner) (*models.AccessRequest, error) {
+	var request models.AccessRequest
+	var createdAt, updatedAt string
+	err := row.Scan(&request.RequestID, &request.AppDID, &request.UserDID, &request.RoleName, &request.Permission,
+		&request.Reason, &request.Status, &request.GrantedRole, &request.DecisionReason, &request.CredentialID,
+		&createdAt, &updatedAt)
+	if err != nil {
+		return nil, sqlNotFound(err)
+	}
+	if request.CreatedAt, err = parseTime(createdAt); err != nil {
+		return nil, err
+	}
+	if request.UpdatedAt, err = parseTime(updatedAt); err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+func scanAPIKey(row scanner) (*models.APIKey, error) {
+	var key models.APIKey
+	var scopes, createdAt string
+	var revokedAt sql.NullString
+	err := row.Scan(&key.KeyID, &key.Name, &scopes, &key.KeyHash, &createdAt, &key.Revoked, &revokedAt)
+	if err != nil {
+		return nil, sqlNotFound(err)
+	}
+	if err := json.Unmarshal([]byte(scopes), &key.Scopes); err != nil {
+		return nil, err
+	}
+	if key.CreatedAt, err = parseTime(createdAt); err != nil {
+		return nil, err
+	}
+	if key.RevokedAt, err = parseTimePtr(revokedAt); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func scanSigningKey(row scanner, master masterKey) (*models.SigningKey, error) {
+	var key models.SigningKey
+	var createdAt, activatesAt string
+	var retiredAt sql.NullString
+	err := row.Scan(&key.KeyID, &key.Algorithm, &key.PublicKey, &key.PrivateKey, &createdAt, &activatesAt, &retiredAt)
+	if err != nil {
+		return nil, sqlNotFound(err)
+	}
+	// the raw Ed25519 keys stored before encryption are shorter than any envelope
+	if len(key.PrivateKey) != ed25519.PrivateKeySize {
+		if key.PrivateKey, err = master.open(key.PrivateKey); err != nil {
+			return nil, err
+		}
+	}
+	if key.CreatedAt, err = parseTime(createdAt); err != nil {
+		return nil, err
+	}
+	if key.ActivatesAt, err = parseTime(activatesAt); err != nil {
+		return nil, err
+	}
+	if key.RetiredAt, err = parseTimePtr(retiredAt); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func scanRefreshToken(row scanner) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	var cnfKeyID, issuedAt, expiresAt string
+	var usedAt, revokedAt sql.NullString
+	err := row.Scan(&token.TokenHash, &token.FamilyID, &token.AppDID, &token.SubjectDID, &token.OAuthCredential,
+		&token.PolicyCredential, &cnfKeyID, &issuedAt, &expiresAt, &usedAt, &revokedAt)
+	if err != nil {
+		return nil, sqlNotFound(err)
+	}
+	token.Confirmation = parseConfirmation(cnfKeyID)
+	if token.IssuedAt, err = parseTime(issuedAt); err != nil {
+		return nil, err
+	}
+	if token.ExpiresAt, err = parseTime(expiresAt); err != nil {
+		return nil, err
+	}
+	if token.UsedAt, err = parseTimePtr(usedAt); err != nil {
+		return nil, err
+	}
+	if token.RevokedAt, err = parseTimePtr(revokedAt); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// confirmationKeyID is the kid a bound token is stored with, empty for an unbound token
+func confirmationKeyID(confirmation *models.Confirmation) string {
+	if confirmation == nil {
+		return ""
+	}
+	return confirmation.KeyID
+}
+
+func parseConfirmation(keyID string) *models.Confirmation {
+	if keyID == "" {
+		return nil
+	}
+	return &models.Confirmation{KeyID: keyID}
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(sqliteTimeLayout)
+}
+
+func formatTimePtr(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return formatTime(*t)
+}
+
+func parseTime(value string) (time.Time, error) {
+	return time.Parse(sqliteTimeLayout, value)
+}
+
+func parseTimePtr(value sql.NullString) (*time.Time, error) {
+	if !value.Valid {
+		return nil, nil
+	}
+	t, err := parseTime(value.String)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// sqlNotFound maps the missing row error to ErrNotFound
+func sqlNotFound(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,122 @@
+package store
+
+import (
+	"authonomy/models"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	BackendBadger = "badger"
+	BackendMemory = "memory"
+	BackendSQLite = "sqlite"
+)
+
+// ErrNotFound is returned when the requested record is not stored
+var ErrNotFound = errors.New("record not found")
+
+// Rekeyer is implemented by the stores that encrypt their records with db_encryption_key
+type Rekeyer interface {
+	// Rekey moves the encrypted records to the key derived from newSecret and returns their number
+	Rekey(newSecret string) (int, error)
+}
+
+// Store persists the applications, their providers and policies, and the access of their users
+type Store interface {
+	// ClearDB deletes all records
+	ClearDB() error
+	// Close releases the resources held by the store
+	Close() error
+
+	SetApp(app models.ApplicationResponse) error
+	GetApp(appID string) (*models.ApplicationResponse, error)
+	GetAllApps() ([]models.ApplicationResponse, error)
+	// DeleteApp deletes the app with its auth providers, attached policies, user access and access
+	// requests. The credential records and the audit log are kept.
+	DeleteApp(appDID string) error
+
+	// an app links any number of providers, one link per provider name
+	SetAuthProvider(auth models.AuthProvider) error
+	GetAuthProvider(appID, provider string) (*models.AuthProvider, error)
+	GetAuthProvidersByApp(appID string) ([]models.AuthProvider, error)
+	DeleteAuthProvider(appID, provider string) error
+	SetProviderSchema(prov models.ProviderSchema) error
+	GetProviderSchema(provider string) (*models.ProviderSchema, error)
+
+	SetPolicy(policy models.PolicySchemaResponse) error
+	GetPolicy(policyID string) (*models.PolicySchemaResponse, error)
+	GetAllPolicies() ([]models.PolicySchemaResponse, error)
+	// an app attaches any number of policies, one per schema
+	SetIssuedPolicy(policy models.ApplicationPolicyResponse) error
+	GetIssuedPolicy(appDID, schemaID string) (*models.ApplicationPolicyResponse, error)
+	GetIssuedPoliciesByApp(appDID string) ([]models.ApplicationPolicyResponse, error)
+	DeleteIssuedPolicy(appDID, schemaID string) error
+
+	SetCredentialRecord(record models.CredentialRecord) error
+	GetCredentialRecord(credentialID string) (*models.CredentialRecord, error)
+	GetCredentialRecordsByApp(appDID string) ([]models.CredentialRecord, error)
+
+	SetUserAccess(access models.UserAccess) error
+	GetUserAccess(appDID, userDID string) (*models.UserAccess, error)
+	AddAuditEvent(event models.AuditEvent) error
+	GetAuditEvents(appDID string) ([]models.AuditEvent, error)
+	SetAccessRequest(request models.AccessRequest) error
+	GetAccessRequest(requestID string) (*models.AccessRequest, error)
+	GetAccessRequestsByApp(appDID string) ([]models.AccessRequest, error)
+
+	SetAuthSession(session models.AuthSession) error
+	TakeAuthSession(state string) (*models.AuthSession, error)
+	SetLoginSession(session models.LoginSession) error
+	GetLoginSession(sessionID string) (*models.LoginSession, error)
+	DeleteLoginSession(sessionID string) error
+
+	SetAPIKey(key models.APIKey) error
+	GetAPIKey(keyID string) (*models.APIKey, error)
+	GetAllAPIKeys() ([]models.APIKey, error)
+
+	SetSigningKey(key models.SigningKey) error
+	GetSigningKey(keyID string) (*models.SigningKey, error)
+	GetAllSigningKeys() ([]models.SigningKey, error)
+
+	// opaque access tokens are kept until they expire, keyed by the hash of the token
+	SetAccessToken(token models.AccessToken) error
+	GetAccessToken(tokenHash string) (*models.AccessToken, error)
+	// revoked access tokens are denied by their jti until they expire
+	SetRevokedToken(token models.RevokedToken) error
+	GetRevokedToken(tokenID string) (*models.RevokedToken, error)
+	// refresh tokens are kept until they expire, used ones too so a reuse is detected
+	SetRefreshToken(token models.RefreshToken) error
+	GetRefreshToken(tokenHash string) (*models.RefreshToken, error)
+	// MarkRefreshTokenUsed sets the used time of an unused refresh token atomically. It reports false when the
+	// token was already used, so of two concurrent uses only one wins.
+	MarkRefreshTokenUsed(tokenHash string, usedAt time.Time) (bool, error)
+	GetRefreshTokensByFamily(familyID string) ([]models.RefreshToken, error)
+	// UseProof records the ID of a proof of possession until it expires. It reports false when the ID was
+	// already recorded, a replayed proof.
+	UseProof(proofID string, expiresAt time.Time) (bool, error)
+}
+
+var (
+	_ Store = (*BadgerStore)(nil)
+	_ Store = (*MemoryStore)(nil)
+	_ Store = (*SQLiteStore)(nil)
+
+	_ Rekeyer = (*BadgerStore)(nil)
+	_ Rekeyer = (*SQLiteStore)(nil)
+)
+
+// NewStore opens the store of the configured backend, the path and secret are only used by
+// the persistent backends
+func NewStore(backend, path, secret string) (Store, error) {
+	switch backend {
+	case BackendBadger, "":
+		return NewBadgerStore(path, secret)
+	case BackendMemory:
+		return NewMemoryStore(), nil
+	case BackendSQLite:
+		return NewSQLiteStore(path, secret)
+	default:
+		return nil, fmt.Errorf("unsupported store backend: %s", backend)
+	}
+}
@@ -0,0 +1,859 @@
+package store
+
+import (
+	"authonomy/models"
+	"bytes"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v3"
+)
+
+// eachStore runs the test against every backend, the persistent ones in a temporary directory
+func eachStore(t *testing.T, test func(t *testing.T, s Store)) {
+	backends := []struct {
+		name string
+		open func(dir string) (Store, error)
+	}{
+		{BackendMemory, func(string) (Store, error) { return NewMemoryStore(), nil }},
+		{BackendBadger, func(dir string) (Store, error) { return NewBadgerStore(filepath.Join(dir, "badger"), "secret") }},
+		{BackendSQLite, func(dir string) (Store, error) { return NewSQLiteStore(filepath.Join(dir, "authonomy.db"), "secret") }},
+	}
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			s, err := backend.open(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { s.Close() })
+			test(t, s)
+		})
+	}
+}
+
+func assertNotFound(t *testing.T, err error) {
+	t.Helper()
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func assertEqual(t *testing.T, got, want interface{}) {
+	t.Helper()
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+// now is the current time as the stores keep it
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Second)
+}
+
+func testApp(appDID string) models.ApplicationResponse {
+	return models.ApplicationResponse{
+		AppDID:     appDID,
+		AppName:    "app",
+		AppDetails: models.AppDetails{Description: "an application", ContactEmail: "owner@example.com"},
+		Status:     models.AppStatusActive,
+		SecretHash: "hash",
+	}
+}
+
+func testProvider(appDID, name string) models.AuthProvider {
+	return models.AuthProvider{
+		AppDID: appDID,
+		Provider: models.AvailableProvider{
+			ProviderName:     name,
+			ProviderType:     "social",
+			ProviderProtocol: "oidc",
+			ProviderSchemaID: "schema-" + name,
+		},
+		Config: models.OAuthConfig{ClientID: "client", RedirectURL: "http://localhost/callback", ClientSecret: "secret"},
+	}
+}
+
+func testPolicy(id string) models.PolicySchemaResponse {
+	return models.PolicySchemaResponse{ID: id, Name: "policy", Version: 1}
+}
+
+func testIssuedPolicy(appDID, schemaID string) models.ApplicationPolicyResponse {
+	return models.ApplicationPolicyResponse{
+		ApplicationDID: appDID,
+		SchemaID:       schemaID,
+		PolicyType:     models.PolicyTypeRBAC,
+		IssuerDID:      appDID,
+		CredentialID:   "credential-" + schemaID,
+	}
+}
+
+func TestApps(t *testing.T) {
+	eachStore(t, func(t *testing.T, s Store) {
+		_, err := s.GetApp("did:app")
+		assertNotFound(t, err)
+
+		app := testApp("did:app")
+		if err := s.SetApp(app); err != nil {
+			t.Fatal(err)
+		}
+		got, err := s.GetApp(app.AppDID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertEqual(t, *got, app)
+
+		suspendedAt := now()
+		app.Status = models.AppStatusSuspended
+		app.SuspendedAt = &suspendedAt
+		app.AccessTokenFormat = models.AccessTokenFormatOpaque
+		app.AccessTokenTTL = 60
+		if err := s.SetApp(app); err != nil {
+			t.Fatal(err)
+		}
+		got, err = s.GetApp(app.AppDID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertEqual(t, *got, app)
+
+		if err := s.SetApp(testApp("did:other")); err != nil {
+			t.Fatal(err)
+		}
+		apps, err := s.GetAllApps()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertEqual(t, len(apps), 2)
+
+		if err := s.DeleteApp(app.AppDID); err != nil {
+			t.Fatal(err)
+		}
+		_, err = s.GetApp(app.AppDID)
+		assertNotFound(t, err)
+		assertNotFound(t, s.DeleteApp(app.AppDID))
+	})
+}
+
+func TestDeleteAppCascade(t *testing.T) {
+	eachStore(t, func(t *testing.T, s Store) {
+		for _, appDID := range []string{"did:app", "did:other"} {
+			if err := s.SetApp(testApp(appDID)); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.SetAuthProvider(testProvider(appDID, "google")); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.SetPolicy(testPolicy("schema-" + appDID)); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.SetIssuedPolicy(testIssuedPolicy(appDID, "schema-"+appDID)); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.SetUserAccess(models.UserAccess{AppDID: appDID, UserDID: "did:user", UpdatedAt: now()}); err != nil {
+				t.Fatal(err)
+			}
+			err := s.SetAccessRequest(models.AccessRequest{RequestID: "request-" + appDID, AppDID: appDID, UserDID: "did:user",
+				RoleName: "admin", Status: models.AccessRequestPending, CreatedAt: now(), UpdatedAt: now()})
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = s.SetCredentialRecord(models.CredentialRecord{CredentialID: "credential-" + appDID, AppDID: appDID,
+				SubjectDID: "did:user", SchemaID: "schema-" + appDID, IssuedAt: now()})
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = s.AddAuditEvent(models.AuditEvent{ID: "event-" + appDID, AppDID: appDID, UserDID: "did:user",
+				Action: "grant", RoleName: "admin", Changed: true, Timestamp: now()})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if err := s.DeleteApp("did:app"); err != nil {
+			t.Fatal(err)
+		}
+		_, err := s.GetAuthProvider("did:app", "google")
+		assertNotFound(t, err)
+		_, err = s.GetIssuedPolicy("did:app", "schema-did:app")
+		assertNotFound(t, err)
+		_, err = s.GetUserAccess("did:app", "did:user")
+		assertNotFound(t, err)
+		_, err = s.GetAccessRequest("request-did:app")
+		assertNotFound(t, err)
+		// the policy schemas, credential records and the audit log outlive the app
+		if _, err := s.GetPolicy("schema-did:app"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.GetCredentialRecord("credential-did:app"); err != nil {
+			t.Fatal(err)
+		}
+		events, err := s.GetAuditEvents("did:app")
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertEqual(t, len(events), 1)
+
+		// the records of the other app are untouched
+		if _, err := s.GetAuthProvider("did:other", "google"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.GetIssuedPolicy("did:other", "schema-did:other"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.GetUserAccess("did:other", "did:user"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.GetAccessRequest("request-did:other"); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestAuthProviders(t *testing.T) {
+	eachStore(t, func(t *testing.T, s Store) {
+		if err := s.SetApp(testApp("did:app")); err != nil {
+			t.Fatal(err)
+		}
+		_, err := s.GetAuthProvider("did:app", "google")
+		assertNotFound(t, err)
+		for _, name := range []string{"google", "github"} {
+			if err := s.SetAuthProvider(testProvider("did:app", name)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		got, err := s.GetAuthProvider("did:app", "google")
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertEqual(t, *got, testProvider("did:app", "google"))
+		providers, err := s.GetAuthProvidersByApp("did:app")
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertEqual(t, len(providers), 2)
+
+		if err := s.DeleteAuthProvider("did:app", "google"); err != nil {
+			t.Fatal(err)
+		}
+		_, err = s.GetAuthProvider("did:app", "google")
+		assertNotFound(t, err)
+		assertNotFound(t, s.DeleteAuthProvider("did:app", "google"))
+
+		if err := s.SetProviderSchema(models.ProviderSchema{ProviderName: "google", SchemaID: "schema"}); err != nil {
+			t.Fatal(err)
+		}
+		schema, err := s.GetProviderSchema("google")
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertEqual(t, schema.SchemaID, "schema")
+		_, err = s.GetProviderSchema("github")
+		assertNotFound(t, err)
+	})
+}
+
+func TestPolicies(t *testing.T) {
+	eachStore(t, func(t *testing.T, s Store) {
+		_, err := s.GetPolicy("schema")
+		assertNotFound(t, err)
+		policy := testPolicy("schema")
+		if err := s.SetPolicy(policy); err != nil {
+			t.Fatal(err)
+		}
+		deprecatedAt := now()
+		policy.Deprecated = true
+		policy.DeprecatedAt = &deprecatedAt
+		if err := s.SetPolicy(policy); err != nil {
+			t.Fatal(err)
+		}
+		got, err := s.GetPolicy("schema")
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertEqual(t, *got, policy)
+		policies, err := s.GetAllPolicies()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertEqual(t, len(policies), 1)
+
+		if err := s.SetApp(testApp("did:app")); err != nil {
+			t.Fatal(err)
+		}
+		_, err = s.GetIssuedPolicy("did:app", "schema")
+		assertNotFound(t, err)
+		if err := s.SetIssuedPolicy(testIssuedPolicy("did:app", "schema")); err != nil {
+			t.Fatal(err)
+		}
+		issued, err := s.GetIssuedPolicy("did:app", "schema")
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertEqual(t, issued.CredentialID, "credential-schema")
+		issuedPolicies, err := s.GetIssuedPoliciesByApp("did:app")
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertEqual(t, len(issuedPolicies), 1)
+		if err := s.DeleteIssuedPolicy("did:app", "schema"); err != nil {
+			t.Fatal(err)
+		}
+		_, err = s.GetIssuedPolicy("did:app", "schema")
+		assertNotFound(t, err)
+	})
+}
+
+func TestAccess(t *testing.T) {
+	eachStore(t, func(t *testing.T, s Store) {
+		if err := s.SetApp(testApp("did:app")); err != nil {
+			t.Fatal(err)
+		}
+		_, err := s.GetCredentialRecord("credential")
+		assertNotFound(t, err)
+		revokedAt := now()
+		record := models.CredentialRecord{CredentialID: "credential", AppDID: "did:app", SubjectDID: "did:user",
+			SchemaID: "schema", IssuedAt: now(), Revoked: true, RevokedAt: &revokedAt, Reason: "left"}
+		if err := s.SetCredentialRecord(record); err != nil {
+			t.Fatal(err)
+		}
+		gotRecord, err := s.GetCredentialRecord("credential")
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertEqual(t, *gotRecord, record)
+		records, err := s.GetCredentialRecordsByApp("did:app")
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertEqual(t, len(records), 1)
+
+		_, err = s.GetUserAccess("did:app", "did:user")
+		assertNotFound(t, err)
+		access := models.UserAccess{AppDID: "did:app", UserDID: "did:user",
+			Roles: []models.Role{{RoleName: "admin"}}, PolicyCredentialID: "credential", UpdatedAt: now()}
+		if err := s.SetUserAccess(access); err != nil {
+			t.Fatal(err)
+		}
+		gotAccess, err := s.GetUserAccess("did:app", "did:user")
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertEqual(t, gotAccess.PolicyCredentialID, "credential")
+		assertEqual(t, gotAccess.Roles[0].RoleName, "admin")
+
+		for i, action := range []string{"grant", "revoke"} {
+			err := s.AddAuditEvent(models.AuditEvent{ID: action, AppDID: "did:app", UserDID: "did:user", Action: action,
+				Timestamp: now().Add(time.Duration(i) * time.Second)})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		events, err := s.GetAuditEvents("did:app")
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertEqual(t, len(events), 2)
+
+		_, err = s.GetAccessRequest("request")
+		assertNotFound(t, err)
+		request := models.AccessRequest{RequestID: "request", AppDID: "did:app", UserDID: "did:user", RoleName: "admin",
+			Status: models.AccessRequestPending, CreatedAt: now(), UpdatedAt: now()}
+		if err := s.SetAccessRequest(request); err != nil {
+			t.Fatal(err)
+		}
+		request.Status = models.AccessRequestApproved
+		request.GrantedRole = "admin"
+		if err := s.SetAccessRequest(request); err != nil {
+			t.Fatal(err)
+		}
+		gotRequest, err := s.GetAccessRequest("request")
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertEqual(t, *gotRequest, request)
+		requests, err := s.GetAccessRequestsByApp("did:app")
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertEqual(t, len(requests), 1)
+	})
+}
+
+func TestKeys(t *testing.T) {
+	eachStore(t, func(t *testing.T, s Store) {
+		_, err := s.GetAPIKey("key")
+		assertNotFound(t, err)
+		apiKey := models.APIKey{KeyID: "key", Name: "admin", Scopes: []string{models.ScopeAppsRead}, KeyHash: "hash", CreatedAt: now()}
+		if err := s.SetAPIKey(apiKey); err != nil {
+			t.Fatal(err)
+		}
+		gotAPIKey, err := s.GetAPIKey("key")
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertEqual(t, *gotAPIKey, apiKey)
+		apiKeys, err := s.GetAllAPIKeys()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertEqual(t, len(apiKeys), 1)
+
+		_, err = s.GetSigningKey("kid")
+		assertNotFound(t, err)
+		signingKey := models.SigningKey{KeyID: "kid", Algorithm: "EdDSA", PublicKey: []byte("public"),
+			PrivateKey: []byte("private"), CreatedAt: now()}
+		if err := s.SetSigningKey(signingKey); err != nil {
+			t.Fatal(err)
+		}
+		gotSigningKey, err := s.GetSigningKey("kid")
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertEqual(t, *gotSigningKey, signingKey)
+		signingKeys, err := s.GetAllSigningKeys()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertEqual(t, len(signingKeys), 1)
+	})
+}
+
+func TestSessions(t *testing.T) {
+	eachStore(t, func(t *testing.T, s Store) {
+		expiresAt := now().Add(time.Hour)
+		session := models.AuthSession{State: "state", AppDID: "did:app", Providers: []string{"google"}, Nonce: "nonce",
+			CodeVerifier: "verifier", ExpiresAt: expiresAt}
+		if err := s.SetAuthSession(session); err != nil {
+			t.Fatal(err)
+		}
+		got, err := s.TakeAuthSession("state")
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertEqual(t, *got, session)
+		// a sign-in attempt is consumed once
+		_, err = s.TakeAuthSession("state")
+		assertNotFound(t, err)
+
+		login := models.LoginSession{SessionID: "session", AppDID: "did:app", Provider: "google",
+			UserInfo: models.UserInfo{UserID: "user", Name: "User"}, ExpiresAt: expiresAt}
+		if err := s.SetLoginSession(login); err != nil {
+			t.Fatal(err)
+		}
+		gotLogin, err := s.GetLoginSession("session")
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertEqual(t, *gotLogin, login)
+		if err := s.DeleteLoginSession("session"); err != nil {
+			t.Fatal(err)
+		}
+		_, err = s.GetLoginSession("session")
+		assertNotFound(t, err)
+
+		expired := now().Add(-time.Second)
+		if err := s.SetAuthSession(models.AuthSession{State: "expired", ExpiresAt: expired}); err == nil {
+			t.Fatal("expired auth session was stored")
+		}
+		if err := s.SetLoginSession(models.LoginSession{SessionID: "expired", ExpiresAt: expired}); err == nil {
+			t.Fatal("expired login session was stored")
+		}
+	})
+}
+
+func TestTokens(t *testing.T) {
+	eachStore(t, func(t *testing.T, s Store) {
+		expiresAt := now().Add(time.Hour)
+		grant := models.TokenGrant{AppDID: "did:app", SubjectDID: "did:user", OAuthCredential: "oauth",
+			PolicyCredential: "policy", Confirmation: &models.Confirmation{KeyID: "did:key:user#key"}}
+
+		_, err := s.GetAccessToken("hash")
+		assertNotFound(t, err)
+		accessToken := models.AccessToken{TokenID: "jti", TokenHash: "hash", TokenGrant: grant, IssuedAt: now(), ExpiresAt: expiresAt}
+		if err := s.SetAccessToken(accessToken); err != nil {
+			t.Fatal(err)
+		}
+		gotAccessToken, err := s.GetAccessToken("hash")
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertEqual(t, *gotAccessToken, accessToken)
+
+		_, err = s.GetRevokedToken("jti")
+		assertNotFound(t, err)
+		revoked := models.RevokedToken{TokenID: "jti", AppDID: "did:app", RevokedAt: now(), ExpiresAt: expiresAt}
+		if err := s.SetRevokedToken(revoked); err != nil {
+			t.Fatal(err)
+		}
+		gotRevoked, err := s.GetRevokedToken("jti")
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertEqual(t, *gotRevoked, revoked)
+
+		_, err = s.GetRefreshToken("refresh-1")
+		assertNotFound(t, err)
+		for _, hash := range []string{"refresh-1", "refresh-2"} {
+			err := s.SetRefreshToken(models.RefreshToken{TokenHash: hash, FamilyID: "family", TokenGrant: grant,
+				IssuedAt: now(), ExpiresAt: expiresAt})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		usedAt := now()
+		refreshToken := models.RefreshToken{TokenHash: "refresh-1", FamilyID: "family", TokenGrant: grant,
+			IssuedAt: now(), ExpiresAt: expiresAt, UsedAt: &usedAt}
+		if err := s.SetRefreshToken(refreshToken); err != nil {
+			t.Fatal(err)
+		}
+		gotRefreshToken, err := s.GetRefreshToken("refresh-1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertEqual(t, *gotRefreshToken, refreshToken)
+		family, err := s.GetRefreshTokensByFamily("family")
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertEqual(t, len(family), 2)
+	})
+}
+
+func TestMarkRefreshTokenUsed(t *testing.T) {
+	eachStore(t, func(t *testing.T, s Store) {
+		_, err := s.MarkRefreshTokenUsed("refresh", now())
+		assertNotFound(t, err)
+		refreshToken := models.RefreshToken{TokenHash: "refresh", FamilyID: "family",
+			TokenGrant: models.TokenGrant{AppDID: "did:app"}, IssuedAt: now(), ExpiresAt: now().Add(time.Hour)}
+		if err := s.SetRefreshToken(refreshToken); err != nil {
+			t.Fatal(err)
+		}
+
+		// of the concurrent uses exactly one marks the token
+		const uses = 8
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		marked := 0
+		for i := 0; i < uses; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				ok, err := s.MarkRefreshTokenUsed("refresh", now())
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if ok {
+					mu.Lock()
+					marked++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+		assertEqual(t, marked, 1)
+
+		got, err := s.GetRefreshToken("refresh")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.UsedAt == nil {
+			t.Fatal("refresh token is not marked used")
+		}
+		ok, err := s.MarkRefreshTokenUsed("refresh", now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertEqual(t, ok, false)
+	})
+}
+
+func TestUseProof(t *testing.T) {
+	eachStore(t, func(t *testing.T, s Store) {
+		if _, err := s.UseProof("thumbprint.jti", now().Add(-time.Second)); err == nil {
+			t.Fatal("expired proof was recorded")
+		}
+		expiresAt := now().Add(time.Minute)
+		used, err := s.UseProof("thumbprint.jti", expiresAt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertEqual(t, used, true)
+		used, err = s.UseProof("thumbprint.jti", expiresAt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertEqual(t, used, false)
+		// the same jti of another key is another proof
+		used, err = s.UseProof("other.jti", expiresAt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertEqual(t, used, true)
+	})
+}
+
+func TestExpiry(t *testing.T) {
+	eachStore(t, func(t *testing.T, s Store) {
+		// Badger expires records by the second
+		expiresAt := time.Now().Add(time.Second)
+		grant := models.TokenGrant{AppDID: "did:app", OAuthCredential: "oauth", PolicyCredential: "policy"}
+		if err := s.SetAuthSession(models.AuthSession{State: "state", ExpiresAt: expiresAt}); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.SetLoginSession(models.LoginSession{SessionID: "session", ExpiresAt: expiresAt}); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.SetAccessToken(models.AccessToken{TokenID: "jti", TokenHash: "hash", TokenGrant: grant,
+			IssuedAt: now(), ExpiresAt: expiresAt}); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.SetRevokedToken(models.RevokedToken{TokenID: "jti", RevokedAt: now(), ExpiresAt: expiresAt}); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.SetRefreshToken(models.RefreshToken{TokenHash: "refresh", FamilyID: "family", TokenGrant: grant,
+			IssuedAt: now(), ExpiresAt: expiresAt}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.UseProof("proof", expiresAt); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.GetLoginSession("session"); err != nil {
+			t.Fatal(err)
+		}
+
+		time.Sleep(2 * time.Second)
+		_, err := s.TakeAuthSession("state")
+		assertNotFound(t, err)
+		_, err = s.GetLoginSession("session")
+		assertNotFound(t, err)
+		_, err = s.GetAccessToken("hash")
+		assertNotFound(t, err)
+		_, err = s.GetRevokedToken("jti")
+		assertNotFound(t, err)
+		_, err = s.GetRefreshToken("refresh")
+		assertNotFound(t, err)
+		family, err := s.GetRefreshTokensByFamily("family")
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertEqual(t, len(family), 0)
+		// an expired proof ID is forgotten, the proof itself is no longer accepted
+		used, err := s.UseProof("proof", time.Now().Add(time.Minute))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertEqual(t, used, true)
+	})
+}
+
+func TestClearDB(t *testing.T) {
+	eachStore(t, func(t *testing.T, s Store) {
+		if err := s.SetApp(testApp("did:app")); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.SetPolicy(testPolicy("schema")); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.ClearDB(); err != nil {
+			t.Fatal(err)
+		}
+		_, err := s.GetApp("did:app")
+		assertNotFound(t, err)
+		_, err = s.GetPolicy("schema")
+		assertNotFound(t, err)
+	})
+}
+
+func TestSQLiteSecretsAtRest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "authonomy.db")
+	s, err := NewSQLiteStore(path, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { s.Close() }()
+	if _, err := NewSQLiteStore(filepath.Join(t.TempDir(), "plain.db"), ""); err == nil {
+		t.Fatal("sqlite store opened without an encryption key")
+	}
+
+	if err := s.SetApp(testApp("did:app")); err != nil {
+		t.Fatal(err)
+	}
+	provider := testProvider("did:app", "google")
+	provider.Config.ClientSecret = "client-secret-value"
+	if err := s.SetAuthProvider(provider); err != nil {
+		t.Fatal(err)
+	}
+	privateKey := ed25519.NewKeyFromSeed(bytes.Repeat([]byte{1}, ed25519.SeedSize))
+	signingKey := models.SigningKey{KeyID: "kid", Algorithm: "EdDSA", PublicKey: []byte("public"),
+		PrivateKey: privateKey, CreatedAt: now()}
+	if err := s.SetSigningKey(signingKey); err != nil {
+		t.Fatal(err)
+	}
+	// the provider stored before encryption is read as plain JSON
+	_, err = s.db.Exec(`INSERT INTO auth_providers (app_did, provider_name, provider_type, provider_protocol, provider_schema_id, config)
+		VALUES ('did:app', 'legacy', 'social', 'oidc', 'schema', '{"client_secret":"legacy-secret"}')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertSealed := func(s *SQLiteStore) {
+		t.Helper()
+		var config, storedKey []byte
+		if err := s.db.QueryRow(`SELECT config FROM auth_providers WHERE provider_name = 'google'`).Scan(&config); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.db.QueryRow(`SELECT private_key FROM signing_keys`).Scan(&storedKey); err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Contains(config, []byte("client-secret-value")) || bytes.Contains(storedKey, privateKey) {
+			t.Fatal("secrets are stored in plaintext")
+		}
+		got, err := s.GetAuthProvider("did:app", "google")
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertEqual(t, *got, provider)
+		gotKey, err := s.GetSigningKey("kid")
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertEqual(t, *gotKey, signingKey)
+		legacy, err := s.GetAuthProvider("did:app", "legacy")
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertEqual(t, legacy.Config.ClientSecret, "legacy-secret")
+	}
+	assertSealed(s)
+
+	count, err := s.Rekey("new-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, count, 3)
+	s.Close()
+	if s, err = NewSQLiteStore(path, "secret"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetSigningKey("kid"); err == nil {
+		t.Fatal("signing key was decrypted with the old key")
+	}
+	s.Close()
+	if s, err = NewSQLiteStore(path, "new-secret"); err != nil {
+		t.Fatal(err)
+	}
+	assertSealed(s)
+}
+
+func TestBadgerRekey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "badger")
+	s, err := NewBadgerStore(path, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { s.Close() }()
+
+	app := testApp("did:app")
+	if err := s.SetApp(app); err != nil {
+		t.Fatal(err)
+	}
+	provider := testProvider("did:app", "google")
+	provider.Config.ClientSecret = "client-secret-value"
+	if err := s.SetAuthProvider(provider); err != nil {
+		t.Fatal(err)
+	}
+	session := models.LoginSession{SessionID: "session", AppDID: "did:app", Provider: "google", ExpiresAt: now().Add(time.Hour)}
+	if err := s.SetLoginSession(session); err != nil {
+		t.Fatal(err)
+	}
+	// the app stored before encryption is plain JSON
+	legacy := testApp("did:legacy")
+	legacyJSON, err := json.Marshal(legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.db.Update(func(txn *badger.Txn) error {
+		return txn.Set([]byte(app_prefix+legacy.AppDID), legacyJSON)
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	assertReadable := func(s *BadgerStore) {
+		t.Helper()
+		got, err := s.GetApp("did:app")
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertEqual(t, *got, app)
+		gotLegacy, err := s.GetApp("did:legacy")
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertEqual(t, *gotLegacy, legacy)
+		gotProvider, err := s.GetAuthProvider("did:app", "google")
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertEqual(t, *gotProvider, provider)
+		gotSession, err := s.GetLoginSession("session")
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertEqual(t, *gotSession, session)
+	}
+	assertReadable(s)
+
+	count, err := s.Rekey("new-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, count, 4)
+	// the rekeyed store keeps reading its records, a second run has nothing left to move
+	assertReadable(s)
+	if count, err = s.Rekey("new-secret"); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, count, 0)
+
+	// every record is sealed with the new key, the session keeps its expiry
+	if err := s.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			val, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			if envelopeKeyID(val) != s.key.id || bytes.Contains(val, []byte("client-secret-value")) {
+				t.Errorf("%s is not sealed with the new key", it.Item().Key())
+			}
+			if string(it.Item().Key()) == login_session_prefix+"session" && it.Item().ExpiresAt() == 0 {
+				t.Error("rekeyed session lost its expiry")
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	// the store fails to open with the old key, as it reads the records to migrate
+	if old, err := NewBadgerStore(path, "secret"); err == nil {
+		_, err := old.GetApp("did:app")
+		old.Close()
+		if err == nil {
+			t.Fatal("app was decrypted with the old key")
+		}
+	}
+	if s, err = NewBadgerStore(path, "new-secret"); err != nil {
+		t.Fatal(err)
+	}
+	assertReadable(s)
+}